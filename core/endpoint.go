@@ -1,6 +1,9 @@
 package core
 
-import "net/http"
+import (
+	"net/http"
+	"time"
+)
 
 // Endpoint represents an API endpoint with middlewares.
 type Endpoint struct {
@@ -8,6 +11,7 @@ type Endpoint struct {
 	Method      string
 	Middlewares []Middleware
 	Handler     http.HandlerFunc // Optional handler for the endpoint.
+	Timeout     time.Duration    // Optional request timeout, zero disables.
 }
 
 // NewEndpoint creates a new Endpoint with the given details.
@@ -43,3 +47,18 @@ func (e *Endpoint) WithHandler(handler http.HandlerFunc) *Endpoint {
 	newEndpoint.Handler = handler
 	return &newEndpoint
 }
+
+// WithTimeout sets the request timeout for the endpoint. The timeout is set as
+// a deadline on the request context and a REQUEST_TIMEOUT error is returned to
+// the client if it is exceeded. It returns a new endpoint.
+//
+// Parameters:
+//   - timeout: The request timeout for the endpoint.
+//
+// Returns:
+//   - Endpoint: A new endpoint.
+func (e *Endpoint) WithTimeout(timeout time.Duration) *Endpoint {
+	newEndpoint := *e
+	newEndpoint.Timeout = timeout
+	return &newEndpoint
+}
@@ -2,6 +2,7 @@ package core
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -161,6 +162,40 @@ func (e *EventEmitter) Emit(event *Event) {
 	}
 }
 
+// EmitSync emits an event to all registered listeners in the calling
+// goroutine, in the order they were registered. A listener that panics fails
+// the delivery: the panic is recovered and returned as an error after the
+// remaining listeners have run.
+//
+// Parameters:
+//   - event: The event to emit.
+//
+// Returns:
+//   - error: An error for each listener that panicked, or nil.
+func (e *EventEmitter) EmitSync(event *Event) error {
+	e.mu.RLock()
+	listeners := e.listeners[event.Type]
+	e.mu.RUnlock()
+	var errs []error
+	for _, l := range listeners {
+		if err := runCallbackSync(event, l.callback); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// runCallbackSync runs a callback and returns its panic as an error.
+func runCallbackSync(event *Event, cb EventCallback) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("event listener panicked: %v", recovered)
+		}
+	}()
+	cb(event)
+	return nil
+}
+
 // runCallback runs a callback with an optional timeout.
 func runCallback(event *Event, cb EventCallback, timeout *time.Duration) {
 	if timeout == nil {
@@ -23,6 +23,7 @@ const (
 	EventShutDownStarted  = "shutdown_started"
 	EventShutDown         = "shutdown"
 	EventShutDownError    = "shutdown_error"
+	EventRequestTimeout   = "request_timeout"
 )
 
 // HTTPServer represents an HTTP server.
@@ -38,7 +39,8 @@ type Logger interface {
 
 // DefaultHTTPServer returns the default HTTP server implementation. It sets
 // default request read and write timeouts of 10 seconds, idle timeout of 60
-// seconds, and a max header size of 64KB.
+// seconds, and a max header size of 64KB. Endpoints with their own timeout
+// extend the write timeout to cover it.
 //
 // Parameters:
 //   - serverHandler: HTTP server handler.
@@ -244,8 +246,11 @@ func (s *ServerHandler) multiplexEndpoint(
 	}
 
 	multiplexed[endpoint.URL][endpoint.Method] = s.serverPanicHandler(
-		ApplyMiddlewares(
-			emptyOrCustomHandler(endpoint), endpoint.Middlewares...,
+		s.timeoutHandler(
+			ApplyMiddlewares(
+				emptyOrCustomHandler(endpoint), endpoint.Middlewares...,
+			),
+			endpoint.Timeout,
 		),
 	)
 }
@@ -0,0 +1,43 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// WriteJSON writes the given data as a JSON response with the given status
+// code.
+//
+// Parameters:
+//   - w: The response writer.
+//   - statusCode: The HTTP status code of the response.
+//   - data: The data to encode as JSON.
+//
+// Returns:
+//   - error: An error if the data cannot be encoded or written.
+func WriteJSON(w http.ResponseWriter, statusCode int, data any) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_, err = w.Write(body)
+	return err
+}
+
+// WriteAPIError writes the given API error as a JSON response with the given
+// status code.
+//
+// Parameters:
+//   - w: The response writer.
+//   - statusCode: The HTTP status code of the response.
+//   - apiError: The API error to write.
+//
+// Returns:
+//   - error: An error if the API error cannot be encoded or written.
+func WriteAPIError(
+	w http.ResponseWriter, statusCode int, apiError *APIError,
+) error {
+	return WriteJSON(w, statusCode, apiError)
+}
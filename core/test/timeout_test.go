@@ -0,0 +1,111 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pakkasys/fluidapi/core"
+	"github.com/stretchr/testify/assert"
+)
+
+// serverHandler returns the HTTP handler of the default server for the given
+// endpoints.
+func serverHandler(endpoints []core.Endpoint) http.Handler {
+	serverHandler := core.NewHTTPServerHandler(core.NewEventEmitter(), nil)
+	server := core.DefaultHTTPServer(serverHandler, 0, endpoints)
+	return server.(*http.Server).Handler
+}
+
+// TestTimeout_Exceeded tests that a REQUEST_TIMEOUT error is returned when the
+// handler exceeds the endpoint timeout.
+func TestTimeout_Exceeded(t *testing.T) {
+	handlerDone := make(chan error, 1)
+	endpoint := core.NewEndpoint("/slow", http.MethodGet, nil).
+		WithHandler(func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+			_, err := w.Write([]byte("late"))
+			handlerDone <- err
+		}).
+		WithTimeout(10 * time.Millisecond)
+
+	rec := httptest.NewRecorder()
+	serverHandler([]core.Endpoint{*endpoint}).ServeHTTP(
+		rec, httptest.NewRequest(http.MethodGet, "/slow", nil),
+	)
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	var apiError core.APIError
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &apiError))
+	assert.Equal(t, core.RequestTimeoutError.ID, apiError.ID)
+	assert.Equal(t, http.ErrHandlerTimeout, <-handlerDone)
+}
+
+// TestTimeout_Canceled tests that no timeout error is written when the
+// request is canceled by the client before the timeout.
+func TestTimeout_Canceled(t *testing.T) {
+	handlerDone := make(chan error, 1)
+	endpoint := core.NewEndpoint("/slow", http.MethodGet, nil).
+		WithHandler(func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+			_, err := w.Write([]byte("late"))
+			handlerDone <- err
+		}).
+		WithTimeout(time.Second)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	rec := httptest.NewRecorder()
+	serverHandler([]core.Endpoint{*endpoint}).ServeHTTP(
+		rec,
+		httptest.NewRequest(http.MethodGet, "/slow", nil).WithContext(ctx),
+	)
+
+	assert.False(t, rec.Flushed)
+	assert.Empty(t, rec.Body.String())
+	assert.Equal(t, http.ErrHandlerTimeout, <-handlerDone)
+}
+
+// TestTimeout_NotExceeded tests that the handler response is passed through
+// when the handler finishes in time.
+func TestTimeout_NotExceeded(t *testing.T) {
+	endpoint := core.NewEndpoint("/fast", http.MethodGet, nil).
+		WithHandler(func(w http.ResponseWriter, r *http.Request) {
+			_, hasDeadline := r.Context().Deadline()
+			assert.True(t, hasDeadline)
+			w.Header().Set("X-Test", "ok")
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte("done"))
+		}).
+		WithTimeout(time.Second)
+
+	rec := httptest.NewRecorder()
+	serverHandler([]core.Endpoint{*endpoint}).ServeHTTP(
+		rec, httptest.NewRequest(http.MethodGet, "/fast", nil),
+	)
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "ok", rec.Header().Get("X-Test"))
+	assert.Equal(t, "done", rec.Body.String())
+}
+
+// TestTimeout_Panic tests that a panic in a handler with a timeout is
+// recovered by the server.
+func TestTimeout_Panic(t *testing.T) {
+	endpoint := core.NewEndpoint("/panic", http.MethodGet, nil).
+		WithHandler(func(w http.ResponseWriter, r *http.Request) {
+			panic("handler panic")
+		}).
+		WithTimeout(time.Second)
+
+	rec := httptest.NewRecorder()
+	serverHandler([]core.Endpoint{*endpoint}).ServeHTTP(
+		rec, httptest.NewRequest(http.MethodGet, "/panic", nil),
+	)
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+}
@@ -0,0 +1,186 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// writeDeadlineGrace is the extra time given on top of an endpoint timeout for
+// writing the response before the connection write deadline is reached.
+const writeDeadlineGrace = 5 * time.Second
+
+// RequestTimeoutErrorData is the data for the RequestTimeoutError error.
+type RequestTimeoutErrorData struct {
+	Timeout string `json:"timeout"`
+}
+
+// RequestTimeoutError is returned when an endpoint exceeds its timeout.
+var RequestTimeoutError = NewAPIError("REQUEST_TIMEOUT")
+
+// timeoutHandler returns an HTTP handler that sets a deadline on the request
+// context. If the deadline is exceeded before the handler has written a
+// response, a RequestTimeoutError is written instead and further writes from
+// the handler are discarded. The connection write deadline is extended to
+// cover the timeout, so that the server-wide write timeout does not cut the
+// endpoint short. If the request is canceled before the deadline, nothing is
+// written. A non-positive timeout disables the handler.
+func (s *ServerHandler) timeoutHandler(
+	next http.Handler, timeout time.Duration,
+) http.Handler {
+	if timeout <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		// Not all response writers support deadlines, so the error is ignored.
+		_ = http.NewResponseController(w).SetWriteDeadline(
+			time.Now().Add(timeout + writeDeadlineGrace),
+		)
+
+		tw := newTimeoutWriter(w)
+		done := make(chan struct{})
+		panicChan := make(chan any, 1)
+		go func() {
+			defer func() {
+				if p := recover(); p != nil {
+					panicChan <- p
+				}
+			}()
+			next.ServeHTTP(tw, r.WithContext(ctx))
+			close(done)
+		}()
+
+		select {
+		case p := <-panicChan:
+			// Propagate the panic to the serving goroutine.
+			panic(p)
+		case <-done:
+			return
+		case <-ctx.Done():
+			if ctx.Err() != context.DeadlineExceeded {
+				// The client went away, so there is no one to respond to.
+				tw.discard()
+				return
+			}
+			tw.timeOut(timeout)
+			s.emitOrLogEvent(
+				EventRequestTimeout,
+				fmt.Sprintf(
+					"Request timeout: %s (%v)", r.URL.Path, r.Method,
+				),
+				[]string{r.URL.Path, r.Method},
+			)
+		}
+	})
+}
+
+// timeoutWriter is a response writer that stops passing writes through once
+// the request has timed out. It keeps its own header map so that the handler
+// and the timeout path never share one.
+type timeoutWriter struct {
+	w           http.ResponseWriter
+	header      http.Header
+	mu          sync.Mutex
+	wroteHeader bool
+	timedOut    bool
+}
+
+// newTimeoutWriter creates a new timeoutWriter.
+func newTimeoutWriter(w http.ResponseWriter) *timeoutWriter {
+	return &timeoutWriter{
+		w:      w,
+		header: make(http.Header),
+	}
+}
+
+// Header returns the header map of the response.
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+// Write writes the data to the response unless the request has timed out.
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if !tw.wroteHeader {
+		tw.writeHeaderLocked(http.StatusOK)
+	}
+	return tw.w.Write(b)
+}
+
+// WriteHeader writes the status code unless the request has timed out.
+func (tw *timeoutWriter) WriteHeader(statusCode int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.wroteHeader {
+		return
+	}
+	tw.writeHeaderLocked(statusCode)
+}
+
+// Flush flushes the response unless the request has timed out.
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return
+	}
+	if !tw.wroteHeader {
+		tw.writeHeaderLocked(http.StatusOK)
+	}
+	if flusher, ok := tw.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap returns the underlying response writer. It is used by
+// http.ResponseController.
+func (tw *timeoutWriter) Unwrap() http.ResponseWriter {
+	return tw.w
+}
+
+// writeHeaderLocked copies the headers and writes the status code. The caller
+// must hold the lock.
+func (tw *timeoutWriter) writeHeaderLocked(statusCode int) {
+	dst := tw.w.Header()
+	for key, values := range tw.header {
+		dst[key] = values
+	}
+	tw.w.WriteHeader(statusCode)
+	tw.wroteHeader = true
+}
+
+// discard marks the writer as timed out without writing a response, so that
+// later writes of the handler are discarded.
+func (tw *timeoutWriter) discard() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.timedOut = true
+}
+
+// timeOut marks the writer as timed out. If the handler has not written a
+// response yet, a RequestTimeoutError is written.
+func (tw *timeoutWriter) timeOut(timeout time.Duration) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.timedOut = true
+	if tw.wroteHeader {
+		return
+	}
+	tw.wroteHeader = true
+	_ = WriteAPIError(
+		tw.w,
+		http.StatusServiceUnavailable,
+		RequestTimeoutError.WithData(
+			RequestTimeoutErrorData{Timeout: timeout.String()},
+		),
+	)
+}
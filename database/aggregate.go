@@ -0,0 +1,321 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Aggregate is an aggregate function of a projection.
+type Aggregate string
+
+// Aggregates.
+const (
+	AggregateCount Aggregate = "COUNT"
+	AggregateSum   Aggregate = "SUM"
+	AggregateAvg   Aggregate = "AVG"
+	AggregateMin   Aggregate = "MIN"
+	AggregateMax   Aggregate = "MAX"
+)
+
+// NewAggregate creates an aggregate projection.
+//
+// Parameters:
+//   - aggregate: The aggregate function.
+//   - table: The table of the column.
+//   - column: The column to aggregate, or "*" for COUNT(*).
+//   - alias: The name of the result column.
+//
+// Returns:
+//   - Projection: The aggregate projection.
+func NewAggregate(
+	aggregate Aggregate, table string, column string, alias string,
+) Projection {
+	return Projection{
+		Table:     table,
+		Column:    column,
+		Alias:     alias,
+		Aggregate: aggregate,
+	}
+}
+
+// Having is a condition on an aggregate of a group, e.g. COUNT(*) > 1.
+type Having struct {
+	Aggregate Aggregate
+	Table     string
+	Column    string
+	Distinct  bool
+	Predicate Predicate
+	Value     any
+}
+
+// Havings is a list of having conditions.
+type Havings []Having
+
+// CheckGrouping checks that the aggregate options of the get options are
+// consistent. Aggregates must be known and have a column, "*" is only allowed
+// for COUNT, havings must use comparison predicates, and if the options have
+// aggregates or groups, every other projection must be in GroupBy.
+//
+// Returns:
+//   - error: An error if the options are inconsistent.
+func (o *GetOptions) CheckGrouping() error {
+	grouped := len(o.GroupBy) > 0 || len(o.Having) > 0
+	for _, projection := range o.Projections {
+		if projection.Aggregate != "" {
+			grouped = true
+			if err := checkAggregate(
+				projection.Aggregate, projection.Column,
+			); err != nil {
+				return fmt.Errorf("CheckGrouping: %w", err)
+			}
+		}
+	}
+	for _, having := range o.Having {
+		if err := checkHaving(having); err != nil {
+			return fmt.Errorf("CheckGrouping: having: %w", err)
+		}
+	}
+	if !grouped {
+		return nil
+	}
+	for _, projection := range o.Projections {
+		if projection.Aggregate != "" || o.isGroupedBy(projection) {
+			continue
+		}
+		return fmt.Errorf(
+			"CheckGrouping: projection %s is neither aggregated nor grouped",
+			projection.Name(),
+		)
+	}
+	return nil
+}
+
+// BuildAggregate renders an aggregate function call for the dialect.
+//
+// Parameters:
+//   - dialect: The expression dialect.
+//   - aggregate: The aggregate function.
+//   - distinct: Whether to aggregate only distinct values.
+//   - table: The table of the column.
+//   - column: The column, or "*" for COUNT(*).
+//
+// Returns:
+//   - string: The SQL of the aggregate.
+//   - error: An error if the aggregate is invalid.
+func BuildAggregate(
+	dialect ExprDialect,
+	aggregate Aggregate,
+	distinct bool,
+	table string,
+	column string,
+) (string, error) {
+	if err := checkAggregate(aggregate, column); err != nil {
+		return "", fmt.Errorf("BuildAggregate: %w", err)
+	}
+	argument := "*"
+	if column != "*" {
+		argument = dialect.QuoteColumn(table, column)
+	}
+	if distinct {
+		argument = "DISTINCT " + argument
+	}
+	return fmt.Sprintf("%s(%s)", aggregate, argument), nil
+}
+
+// BuildProjection renders a projection for the dialect, including its
+// aggregate and alias.
+//
+// Parameters:
+//   - dialect: The expression dialect.
+//   - projection: The projection.
+//
+// Returns:
+//   - string: The SQL of the projection.
+//   - error: An error if the aggregate is invalid.
+func BuildProjection(
+	dialect ExprDialect, projection Projection,
+) (string, error) {
+	sql := dialect.QuoteColumn(projection.Table, projection.Column)
+	if projection.Aggregate != "" {
+		var err error
+		sql, err = BuildAggregate(
+			dialect,
+			projection.Aggregate,
+			projection.Distinct,
+			projection.Table,
+			projection.Column,
+		)
+		if err != nil {
+			return "", err
+		}
+	}
+	if projection.Aggregate != "" || projection.Alias != "" {
+		sql += " AS " + dialect.QuoteColumn("", projection.Name())
+	}
+	return sql, nil
+}
+
+// BuildHavings renders having conditions combined with AND. The predicates
+// must be comparisons, i.e. =, !=, <, <=, > or >=.
+//
+// Parameters:
+//   - dialect: The expression dialect.
+//   - havings: The having conditions.
+//   - params: The statement parameters so far.
+//
+// Returns:
+//   - string: The SQL of the condition.
+//   - []any: The statement parameters including those of the conditions.
+//   - error: An error if a condition cannot be rendered.
+func BuildHavings(
+	dialect ExprDialect, havings Havings, params []any,
+) (string, []any, error) {
+	conditions := make([]string, len(havings))
+	for i, having := range havings {
+		if !isComparison(having.Predicate) {
+			return "", nil, fmt.Errorf(
+				"BuildHavings: invalid predicate: %s", having.Predicate,
+			)
+		}
+		aggregate, err := BuildAggregate(
+			dialect,
+			having.Aggregate,
+			having.Distinct,
+			having.Table,
+			having.Column,
+		)
+		if err != nil {
+			return "", nil, err
+		}
+		value, newParams, err := BuildValue(dialect, having.Value, params)
+		if err != nil {
+			return "", nil, err
+		}
+		params = newParams
+		conditions[i] = fmt.Sprintf(
+			"%s %s %s", aggregate, having.Predicate, value,
+		)
+	}
+	return strings.Join(conditions, " AND "), params, nil
+}
+
+// GetAggregates retrieves aggregated or otherwise projected rows into result
+// structs. The options must have projections, which are scanned with the
+// ScanProjection method of the results. The rows are selected from From if it
+// is set, e.g. a CTE, and otherwise from the table of the entity, in which
+// case soft deleted rows are excluded unless the options include deleted
+// rows.
+//
+// Parameters:
+//   - preparer: The database connection or transaction to use.
+//   - entity: An entity that provides the table name to query.
+//   - options: The get options with projections, groups and havings.
+//   - factoryFn: A function that returns a new result.
+//   - queryBuilder: The SQL query builder for constructing the query.
+//   - errorChecker: Optional error checker to translate SQL driver errors into
+//     custom errors or skip them.
+//
+// Returns:
+//   - []T: The results.
+//   - error: An error if the options are invalid or the query fails.
+func GetAggregates[T ProjectionScanner](
+	preparer Preparer,
+	entity TableNamer,
+	options *GetOptions,
+	factoryFn func() T,
+	queryBuilder QueryBuilder,
+	errorChecker ErrorChecker,
+) ([]T, error) {
+	if preparer == nil {
+		return nil, fmt.Errorf("GetAggregates: preparer is nil")
+	}
+	if entity == nil {
+		return nil, fmt.Errorf("GetAggregates: entity is nil")
+	}
+	if options == nil || len(options.Projections) == 0 {
+		return nil, fmt.Errorf("GetAggregates: options have no projections")
+	}
+	if queryBuilder == nil {
+		return nil, fmt.Errorf("GetAggregates: queryBuilder is nil")
+	}
+	if err := options.CheckGrouping(); err != nil {
+		return nil, fmt.Errorf("GetAggregates: %w", err)
+	}
+	if err := checkGetOptions(queryBuilder, options); err != nil {
+		return nil, fmt.Errorf("GetAggregates: %w", err)
+	}
+
+	query, params := queryBuilder.Get(
+		options.Table(entity.TableName()), options.withoutDeleted(entity),
+	)
+	rows, stmt, err := doQuery(preparer, query, params)
+	if err != nil {
+		if errorChecker == nil {
+			return nil, err
+		}
+		return nil, errorChecker.Check(err)
+	}
+	defer rows.Close()
+	defer stmt.Close()
+
+	results := []T{}
+	for rows.Next() {
+		result := factoryFn()
+		if err := result.ScanProjection(rows, options.Projections); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// isGroupedBy reports whether the projection column is in GroupBy.
+func (o *GetOptions) isGroupedBy(projection Projection) bool {
+	for _, group := range o.GroupBy {
+		if group.Table == projection.Table &&
+			group.Column == projection.Column {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAggregate checks that the aggregate is known and has a valid column.
+func checkAggregate(aggregate Aggregate, column string) error {
+	switch aggregate {
+	case AggregateCount:
+	case AggregateSum, AggregateAvg, AggregateMin, AggregateMax:
+		if column == "*" {
+			return fmt.Errorf("%s does not support *", aggregate)
+		}
+	default:
+		return fmt.Errorf("unknown aggregate: %s", aggregate)
+	}
+	if column == "" {
+		return fmt.Errorf("%s has no column", aggregate)
+	}
+	return nil
+}
+
+// checkHaving checks the aggregate and predicate of a having condition.
+func checkHaving(having Having) error {
+	if err := checkAggregate(having.Aggregate, having.Column); err != nil {
+		return err
+	}
+	if !isComparison(having.Predicate) {
+		return fmt.Errorf("invalid predicate: %s", having.Predicate)
+	}
+	return nil
+}
+
+// isComparison reports whether the predicate is a comparison.
+func isComparison(predicate Predicate) bool {
+	switch predicate {
+	case Greater, GreaterOrEqual, Equal, NotEqual, Less, LessOrEqual:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,412 @@
+package database
+
+import (
+	"context"
+	"fmt"
+)
+
+// MaxPostgresParams is the maximum number of parameters of a Postgres
+// statement.
+const MaxPostgresParams = 65535
+
+// BatchOptions configures how batch inserts and upserts are split into
+// chunks. A chunk has at most MaxRows rows and at most MaxParams parameters.
+// If both are zero, all rows are written in one statement.
+type BatchOptions struct {
+	MaxRows   int // Maximum number of rows per statement.
+	MaxParams int // Maximum number of parameters per statement.
+}
+
+// InsertedIDsReporter can be implemented by query builders whose driver
+// reports the last insert ID of multi-row inserts in a known way, e.g. the ID
+// of the first row for MySQL.
+type InsertedIDsReporter interface {
+	// InsertedIDs returns the IDs of the rows inserted by a multi-row insert
+	// from the last insert ID reported by the driver.
+	InsertedIDs(lastInsertID int64, rows int) []int64
+}
+
+// FirstInsertedIDs returns the consecutive IDs of rows inserted by a multi-row
+// insert whose driver reports the ID of the first row.
+//
+// Parameters:
+//   - lastInsertID: The ID reported by the driver.
+//   - rows: The number of inserted rows.
+//
+// Returns:
+//   - []int64: The inserted IDs.
+func FirstInsertedIDs(lastInsertID int64, rows int) []int64 {
+	ids := make([]int64, rows)
+	for i := range ids {
+		ids[i] = lastInsertID + int64(i)
+	}
+	return ids
+}
+
+// LastInsertedIDs returns the consecutive IDs of rows inserted by a multi-row
+// insert whose driver reports the ID of the last row.
+//
+// Parameters:
+//   - lastInsertID: The ID reported by the driver.
+//   - rows: The number of inserted rows.
+//
+// Returns:
+//   - []int64: The inserted IDs.
+func LastInsertedIDs(lastInsertID int64, rows int) []int64 {
+	return FirstInsertedIDs(lastInsertID-int64(rows)+1, rows)
+}
+
+// ChunkResult is the result of a single chunk of a batch.
+type ChunkResult struct {
+	Rows         int   // Number of rows in the chunk.
+	RowsAffected int64 // Rows affected as reported by the driver.
+	// LastInsertID and InsertedIDs are only set by InsertBatch if the query
+	// builder implements InsertedIDsReporter, since not all drivers support
+	// LastInsertId and upserted rows may have been updated, not inserted.
+	LastInsertID int64
+	InsertedIDs  []int64
+}
+
+// BatchResult is the result of a chunked batch.
+type BatchResult struct {
+	Chunks       []ChunkResult
+	RowsAffected int64 // Total rows affected of all chunks.
+}
+
+// InsertedIDs returns the inserted IDs of all chunks in row order, or nil if
+// the query builder does not report inserted IDs or the batch is an upsert.
+//
+// Returns:
+//   - []int64: The inserted IDs.
+func (r *BatchResult) InsertedIDs() []int64 {
+	var ids []int64
+	for _, chunk := range r.Chunks {
+		if chunk.InsertedIDs == nil {
+			return nil
+		}
+		ids = append(ids, chunk.InsertedIDs...)
+	}
+	return ids
+}
+
+// InsertBatch inserts the entities in chunks limited by the batch options,
+// one statement per chunk. If a chunk fails, the result of the preceding
+// chunks is returned with the error, so use InsertBatchTx or pass a
+// transaction to make the batch atomic.
+//
+// Parameters:
+//   - preparer: The database connection or transaction to use.
+//   - entities: The entities to insert, all of the same table.
+//   - options: The chunking options, or nil for a single chunk.
+//   - queryBuilder: The SQL query builder for constructing the queries.
+//   - errorChecker: Optional error checker to translate SQL driver errors into
+//     custom errors or skip them.
+//
+// Returns:
+//   - *BatchResult: The results of the executed chunks.
+//   - error: An error if a chunk fails.
+func (d *MutateDBOps[Entity]) InsertBatch(
+	preparer Preparer,
+	entities []Mutator,
+	options *BatchOptions,
+	queryBuilder QueryBuilder,
+	errorChecker ErrorChecker,
+) (*BatchResult, error) {
+	if preparer == nil {
+		return nil, fmt.Errorf("InsertBatch: preparer is nil")
+	}
+	if queryBuilder == nil {
+		return nil, fmt.Errorf("InsertBatch: queryBuilder is nil")
+	}
+	reporter, _ := queryBuilder.(InsertedIDsReporter)
+	result, err := execBatch(
+		preparer,
+		entities,
+		options,
+		reporter,
+		errorChecker,
+		0,
+		queryBuilder.InsertMany,
+	)
+	if err != nil {
+		return result, fmt.Errorf("InsertBatch: %w", err)
+	}
+	return result, nil
+}
+
+// InsertBatchTx runs InsertBatch in a transaction, so that either all chunks
+// are inserted or none.
+//
+// Parameters:
+//   - ctx: The context for the transaction.
+//   - db: The database connection to begin the transaction on.
+//   - entities: The entities to insert, all of the same table.
+//   - options: The chunking options, or nil for a single chunk.
+//   - queryBuilder: The SQL query builder for constructing the queries.
+//   - errorChecker: Optional error checker to translate SQL driver errors into
+//     custom errors or skip them.
+//
+// Returns:
+//   - *BatchResult: The results of the chunks.
+//   - error: An error if the transaction or a chunk fails.
+func (d *MutateDBOps[Entity]) InsertBatchTx(
+	ctx context.Context,
+	db DB,
+	entities []Mutator,
+	options *BatchOptions,
+	queryBuilder QueryBuilder,
+	errorChecker ErrorChecker,
+) (*BatchResult, error) {
+	if db == nil {
+		return nil, fmt.Errorf("InsertBatchTx: db is nil")
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return Transaction(
+		ctx,
+		tx,
+		func(ctx context.Context, tx Tx) (*BatchResult, error) {
+			return d.InsertBatch(
+				tx, entities, options, queryBuilder, errorChecker,
+			)
+		},
+	)
+}
+
+// UpsertBatch upserts the entities in chunks limited by the batch options,
+// one statement per chunk. The parameters of the update clause count towards
+// MaxParams. Inserted IDs are not reported, since upserted rows may have been
+// updated instead. If a chunk fails, the result of the preceding chunks is
+// returned with the error, so use UpsertBatchTx or pass a transaction to make
+// the batch atomic.
+//
+// Parameters:
+//   - preparer: The database connection or transaction to use.
+//   - entities: The entities to upsert, all of the same table.
+//   - updateProjections: Columns and values to update if a conflict occurs.
+//   - options: The chunking options, or nil for a single chunk.
+//   - queryBuilder: The SQL query builder for constructing the queries.
+//   - errorChecker: Optional error checker to translate SQL driver errors into
+//     custom errors or skip them.
+//
+// Returns:
+//   - *BatchResult: The results of the executed chunks.
+//   - error: An error if a chunk fails.
+func (d *MutateDBOps[Entity]) UpsertBatch(
+	preparer Preparer,
+	entities []Mutator,
+	updateProjections []Projection,
+	options *BatchOptions,
+	queryBuilder QueryBuilder,
+	errorChecker ErrorChecker,
+) (*BatchResult, error) {
+	if preparer == nil {
+		return nil, fmt.Errorf("UpsertBatch: preparer is nil")
+	}
+	if queryBuilder == nil {
+		return nil, fmt.Errorf("UpsertBatch: queryBuilder is nil")
+	}
+	if err := checkUpsertProjections(updateProjections); err != nil {
+		return nil, fmt.Errorf("UpsertBatch: %w", err)
+	}
+	buildFn := func(
+		table string, valuesFuncs []InsertedValuesFn,
+	) (string, []any) {
+		return queryBuilder.UpsertMany(table, valuesFuncs, updateProjections)
+	}
+	result, err := execBatch(
+		preparer,
+		entities,
+		options,
+		nil,
+		errorChecker,
+		statementParams(entities, options, buildFn),
+		buildFn,
+	)
+	if err != nil {
+		return result, fmt.Errorf("UpsertBatch: %w", err)
+	}
+	return result, nil
+}
+
+// UpsertBatchTx runs UpsertBatch in a transaction, so that either all chunks
+// are upserted or none.
+//
+// Parameters:
+//   - ctx: The context for the transaction.
+//   - db: The database connection to begin the transaction on.
+//   - entities: The entities to upsert, all of the same table.
+//   - updateProjections: Columns and values to update if a conflict occurs.
+//   - options: The chunking options, or nil for a single chunk.
+//   - queryBuilder: The SQL query builder for constructing the queries.
+//   - errorChecker: Optional error checker to translate SQL driver errors into
+//     custom errors or skip them.
+//
+// Returns:
+//   - *BatchResult: The results of the chunks.
+//   - error: An error if the transaction or a chunk fails.
+func (d *MutateDBOps[Entity]) UpsertBatchTx(
+	ctx context.Context,
+	db DB,
+	entities []Mutator,
+	updateProjections []Projection,
+	options *BatchOptions,
+	queryBuilder QueryBuilder,
+	errorChecker ErrorChecker,
+) (*BatchResult, error) {
+	if db == nil {
+		return nil, fmt.Errorf("UpsertBatchTx: db is nil")
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return Transaction(
+		ctx,
+		tx,
+		func(ctx context.Context, tx Tx) (*BatchResult, error) {
+			return d.UpsertBatch(
+				tx,
+				entities,
+				updateProjections,
+				options,
+				queryBuilder,
+				errorChecker,
+			)
+		},
+	)
+}
+
+// ChunkSize returns the number of rows per chunk for rows with the given
+// number of parameters. A chunk has at least one row.
+//
+// Parameters:
+//   - paramsPerRow: The number of parameters of a row.
+//
+// Returns:
+//   - int: The number of rows per chunk, or 0 for no limit.
+func (o *BatchOptions) ChunkSize(paramsPerRow int) int {
+	return o.chunkSize(paramsPerRow, 0)
+}
+
+// chunkSize returns the number of rows per chunk for statements with the
+// given number of parameters per row and per statement.
+func (o *BatchOptions) chunkSize(paramsPerRow int, statementParams int) int {
+	if o == nil {
+		return 0
+	}
+	size := o.MaxRows
+	if o.MaxParams > 0 && paramsPerRow > 0 {
+		byParams := max((o.MaxParams-statementParams)/paramsPerRow, 1)
+		if size <= 0 || byParams < size {
+			size = byParams
+		}
+	}
+	return size
+}
+
+// statementParams returns the number of parameters that the statement
+// builder adds to the parameters of the rows, measured with a statement of
+// the first entity. It is only measured if the options limit parameters.
+func statementParams(
+	entities []Mutator,
+	options *BatchOptions,
+	buildFn func(table string, valuesFuncs []InsertedValuesFn) (string, []any),
+) int {
+	if options == nil || options.MaxParams <= 0 || len(entities) == 0 {
+		return 0
+	}
+	_, values := entities[0].InsertedValues()
+	_, params := buildFn(
+		entities[0].TableName(),
+		[]InsertedValuesFn{entities[0].InsertedValues},
+	)
+	return max(len(params)-len(values), 0)
+}
+
+// execBatch executes the chunks of the entities with the given statement
+// builder. Inserted IDs are reported if the reporter is not nil.
+func execBatch(
+	preparer Preparer,
+	entities []Mutator,
+	options *BatchOptions,
+	reporter InsertedIDsReporter,
+	errorChecker ErrorChecker,
+	statementParams int,
+	buildFn func(table string, valuesFuncs []InsertedValuesFn) (string, []any),
+) (*BatchResult, error) {
+	result := &BatchResult{Chunks: []ChunkResult{}}
+	if len(entities) == 0 {
+		return result, nil
+	}
+	table := entities[0].TableName()
+	_, values := entities[0].InsertedValues()
+	size := options.chunkSize(len(values), statementParams)
+	if size <= 0 {
+		size = len(entities)
+	}
+
+	for start := 0; start < len(entities); start += size {
+		chunk := entities[start:min(start+size, len(entities))]
+		valuesFuncs := make([]InsertedValuesFn, len(chunk))
+		for i, entity := range chunk {
+			valuesFuncs[i] = entity.InsertedValues
+		}
+		query, params := buildFn(table, valuesFuncs)
+		execResult, err := doExec(preparer, query, params)
+		if err != nil {
+			return result, fmt.Errorf(
+				"chunk at row %d: %w", start, checkError(err, errorChecker),
+			)
+		}
+		chunkResult, err := newChunkResult(execResult, len(chunk), reporter)
+		if err != nil {
+			return result, fmt.Errorf(
+				"chunk at row %d: %w", start, checkError(err, errorChecker),
+			)
+		}
+		result.Chunks = append(result.Chunks, chunkResult)
+		result.RowsAffected += chunkResult.RowsAffected
+	}
+	return result, nil
+}
+
+// newChunkResult creates the result of a chunk from the driver result.
+func newChunkResult(
+	execResult Result, rows int, reporter InsertedIDsReporter,
+) (ChunkResult, error) {
+	chunkResult := ChunkResult{Rows: rows}
+	if execResult == nil {
+		return chunkResult, nil
+	}
+	var err error
+	if chunkResult.RowsAffected, err = execResult.RowsAffected(); err != nil {
+		return chunkResult, err
+	}
+	if reporter == nil {
+		return chunkResult, nil
+	}
+	if chunkResult.LastInsertID, err = execResult.LastInsertId(); err != nil {
+		return chunkResult, err
+	}
+	chunkResult.InsertedIDs = reporter.InsertedIDs(
+		chunkResult.LastInsertID, rows,
+	)
+	return chunkResult, nil
+}
+
+// checkUpsertProjections checks that the update projections of an upsert are
+// valid.
+func checkUpsertProjections(updateProjections []Projection) error {
+	if len(updateProjections) == 0 {
+		return fmt.Errorf("must provide update projections")
+	}
+	if len(updateProjections[0].Alias) == 0 {
+		return fmt.Errorf(
+			"update projections must include an alias for the upserted table",
+		)
+	}
+	return nil
+}
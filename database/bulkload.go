@@ -0,0 +1,433 @@
+package database
+
+import (
+	"bufio"
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"iter"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pakkasys/fluidapi/core"
+)
+
+// Bulk load events. The event data is a BulkLoadProgress.
+const (
+	EventBulkLoadProgress  core.EventType = "bulk_load_progress"
+	EventBulkLoadCompleted core.EventType = "bulk_load_completed"
+)
+
+// DefaultBulkLoadProgressInterval is the default number of rows between
+// progress events.
+const DefaultBulkLoadProgressInterval = 10000
+
+// DefaultBulkLoadChunkRows is the default number of rows per statement of the
+// multi-row insert fallback.
+const DefaultBulkLoadChunkRows = 1000
+
+// BulkLoadMethod is the method used to load rows.
+type BulkLoadMethod string
+
+// Bulk load methods.
+const (
+	BulkLoadCopy     BulkLoadMethod = "copy"      // COPY FROM STDIN.
+	BulkLoadLoadData BulkLoadMethod = "load_data" // LOAD DATA LOCAL INFILE.
+	BulkLoadInsert   BulkLoadMethod = "insert"    // Chunked multi-row inserts.
+)
+
+// CopyDialect is implemented by query builders whose driver supports
+// COPY FROM STDIN through prepared statements, like lib/pq: the statement is
+// executed once per row with the row values and once without values to flush
+// the rows.
+type CopyDialect interface {
+	// CopyIn returns the statement starting a COPY of the columns into the
+	// table, e.g. pq.CopyIn(table, columns...).
+	CopyIn(table string, columns []string) string
+}
+
+// LoadDataDialect is implemented by query builders whose driver supports
+// LOAD DATA LOCAL INFILE from a reader, like the MySQL driver with
+// mysql.RegisterReaderHandler. The reader contains the rows in the default
+// LOAD DATA format: tab separated fields, newline terminated lines, backslash
+// escapes and \N for NULL.
+type LoadDataDialect interface {
+	// LoadData registers the reader with the driver and returns the
+	// LOAD DATA statement reading the columns of the table from it, and a
+	// function unregistering the reader.
+	LoadData(
+		table string, columns []string, reader io.Reader,
+	) (query string, unregister func())
+}
+
+// BulkLoadOptions configures a bulk load.
+type BulkLoadOptions struct {
+	// Batch limits the statements of the multi-row insert fallback. If nil,
+	// DefaultBulkLoadChunkRows rows are inserted per statement.
+	Batch *BatchOptions
+	// EventEmitter receives progress events, if set.
+	EventEmitter *core.EventEmitter
+	// ProgressInterval is the number of rows between progress events. If
+	// zero, DefaultBulkLoadProgressInterval is used.
+	ProgressInterval int64
+}
+
+// BulkLoadProgress is the data of bulk load events.
+type BulkLoadProgress struct {
+	Table  string
+	Method BulkLoadMethod
+	Rows   int64 // Rows sent so far.
+}
+
+// BulkLoadResult is the result of a bulk load.
+type BulkLoadResult struct {
+	Method BulkLoadMethod
+	Rows   int64 // Number of loaded rows.
+}
+
+// BulkLoad streams the entities into their table using the fastest method of
+// the query builder: COPY if it implements CopyDialect, LOAD DATA if it
+// implements LoadDataDialect, and chunked multi-row inserts otherwise. The
+// entities are consumed one at a time, so they do not need to fit in memory.
+// All entities must be of the same table and insert the same columns. A
+// failed load may have loaded some rows, so run it in a transaction to make
+// it atomic.
+//
+// Parameters:
+//   - ctx: The context of the load. Cancelling it aborts the load.
+//   - preparer: The database connection or transaction to use.
+//   - entities: The entities to load, or an error to abort the load.
+//   - options: The options of the load, or nil for defaults.
+//   - queryBuilder: The SQL query builder for constructing the queries.
+//   - errorChecker: Optional error checker to translate SQL driver errors into
+//     custom errors or skip them.
+//
+// Returns:
+//   - *BulkLoadResult: The method used and the number of loaded rows.
+//   - error: An error if the entities or the load fail.
+func (d *MutateDBOps[Entity]) BulkLoad(
+	ctx context.Context,
+	preparer Preparer,
+	entities iter.Seq2[Mutator, error],
+	options *BulkLoadOptions,
+	queryBuilder QueryBuilder,
+	errorChecker ErrorChecker,
+) (*BulkLoadResult, error) {
+	if preparer == nil {
+		return nil, fmt.Errorf("BulkLoad: preparer is nil")
+	}
+	if queryBuilder == nil {
+		return nil, fmt.Errorf("BulkLoad: queryBuilder is nil")
+	}
+	if options == nil {
+		options = &BulkLoadOptions{}
+	}
+
+	progress := &BulkLoadProgress{Method: bulkLoadMethod(queryBuilder)}
+	result := &BulkLoadResult{Method: progress.Method}
+	var loader bulkLoader
+	var columns []string
+	abort := func(err error) (*BulkLoadResult, error) {
+		if loader != nil {
+			loader.abort(err)
+		}
+		return result, fmt.Errorf("BulkLoad: %w", err)
+	}
+
+	for entity, err := range entities {
+		if err != nil {
+			return abort(err)
+		}
+		if err := ctx.Err(); err != nil {
+			return abort(err)
+		}
+		entityColumns, values := entity.InsertedValues()
+		if loader == nil {
+			columns = entityColumns
+			progress.Table = entity.TableName()
+			loader, err = newBulkLoader(
+				preparer, progress, columns, options, queryBuilder,
+			)
+			if err != nil {
+				return abort(checkError(err, errorChecker))
+			}
+		} else if !slices.Equal(entityColumns, columns) {
+			return abort(fmt.Errorf(
+				"row %d has different columns", progress.Rows,
+			))
+		}
+		if err := loader.add(entity, values); err != nil {
+			return abort(checkError(err, errorChecker))
+		}
+		progress.Rows++
+		if progress.Rows%options.progressInterval() == 0 {
+			emitBulkLoad(options, EventBulkLoadProgress, progress)
+		}
+	}
+
+	if loader != nil {
+		if err := loader.close(); err != nil {
+			return result, fmt.Errorf(
+				"BulkLoad: %w", checkError(err, errorChecker),
+			)
+		}
+	}
+	result.Rows = progress.Rows
+	emitBulkLoad(options, EventBulkLoadCompleted, progress)
+	return result, nil
+}
+
+// progressInterval returns the number of rows between progress events.
+func (o *BulkLoadOptions) progressInterval() int64 {
+	if o.ProgressInterval <= 0 {
+		return DefaultBulkLoadProgressInterval
+	}
+	return o.ProgressInterval
+}
+
+// emitBulkLoad emits a bulk load event if the options have an event emitter.
+func emitBulkLoad(
+	options *BulkLoadOptions,
+	eventType core.EventType,
+	progress *BulkLoadProgress,
+) {
+	if options.EventEmitter == nil {
+		return
+	}
+	data := *progress
+	options.EventEmitter.Emit(
+		core.NewEvent(
+			eventType,
+			fmt.Sprintf("Loaded %d rows into %s", data.Rows, data.Table),
+		).WithData(&data),
+	)
+}
+
+// bulkLoadMethod returns the fastest method of the query builder.
+func bulkLoadMethod(queryBuilder QueryBuilder) BulkLoadMethod {
+	switch queryBuilder.(type) {
+	case CopyDialect:
+		return BulkLoadCopy
+	case LoadDataDialect:
+		return BulkLoadLoadData
+	default:
+		return BulkLoadInsert
+	}
+}
+
+// bulkLoader loads rows with a bulk load method.
+type bulkLoader interface {
+	// add adds a row to the load.
+	add(entity Mutator, values []any) error
+	// close finishes the load.
+	close() error
+	// abort aborts the load after an error.
+	abort(err error)
+}
+
+// newBulkLoader creates the loader of the method of the progress.
+func newBulkLoader(
+	preparer Preparer,
+	progress *BulkLoadProgress,
+	columns []string,
+	options *BulkLoadOptions,
+	queryBuilder QueryBuilder,
+) (bulkLoader, error) {
+	switch progress.Method {
+	case BulkLoadCopy:
+		dialect := queryBuilder.(CopyDialect)
+		stmt, err := preparer.Prepare(dialect.CopyIn(progress.Table, columns))
+		if err != nil {
+			return nil, err
+		}
+		return &copyLoader{stmt: stmt}, nil
+	case BulkLoadLoadData:
+		return newLoadDataLoader(
+			preparer, queryBuilder.(LoadDataDialect), progress.Table, columns,
+		), nil
+	default:
+		size := options.Batch.ChunkSize(len(columns))
+		if size <= 0 {
+			size = DefaultBulkLoadChunkRows
+		}
+		return &insertLoader{
+			preparer:     preparer,
+			queryBuilder: queryBuilder,
+			table:        progress.Table,
+			size:         size,
+		}, nil
+	}
+}
+
+// copyLoader loads rows with COPY FROM STDIN.
+type copyLoader struct {
+	stmt Stmt
+}
+
+func (l *copyLoader) add(_ Mutator, values []any) error {
+	_, err := l.stmt.Exec(values...)
+	return err
+}
+
+func (l *copyLoader) close() error {
+	_, err := l.stmt.Exec()
+	if closeErr := l.stmt.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (l *copyLoader) abort(error) {
+	l.stmt.Close()
+}
+
+// loadDataLoader loads rows with LOAD DATA LOCAL INFILE. The rows are written
+// to a pipe read by the statement, which runs in its own goroutine.
+type loadDataLoader struct {
+	pipe   *io.PipeWriter
+	writer *bufio.Writer
+	done   chan error
+}
+
+// newLoadDataLoader starts the LOAD DATA statement.
+func newLoadDataLoader(
+	preparer Preparer,
+	dialect LoadDataDialect,
+	table string,
+	columns []string,
+) *loadDataLoader {
+	reader, pipe := io.Pipe()
+	loader := &loadDataLoader{
+		pipe:   pipe,
+		writer: bufio.NewWriter(pipe),
+		done:   make(chan error, 1),
+	}
+	query, unregister := dialect.LoadData(table, columns, reader)
+	go func() {
+		defer unregister()
+		_, err := doExec(preparer, query, nil)
+		// Fail pending writes if the statement ends early.
+		reader.CloseWithError(err)
+		loader.done <- err
+	}()
+	return loader
+}
+
+func (l *loadDataLoader) add(_ Mutator, values []any) error {
+	for i, value := range values {
+		field, err := loadDataField(value)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			l.writer.WriteByte('\t')
+		}
+		l.writer.WriteString(field)
+	}
+	return l.writer.WriteByte('\n')
+}
+
+func (l *loadDataLoader) close() error {
+	if err := l.writer.Flush(); err != nil {
+		l.pipe.CloseWithError(err)
+		<-l.done
+		return err
+	}
+	l.pipe.Close()
+	return <-l.done
+}
+
+func (l *loadDataLoader) abort(err error) {
+	l.pipe.CloseWithError(err)
+	<-l.done
+}
+
+// loadDataEscaper escapes the special characters of LOAD DATA fields.
+var loadDataEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"\t", `\t`,
+	"\n", `\n`,
+	"\r", `\r`,
+	"\x00", `\0`,
+)
+
+// loadDataField formats a value as a LOAD DATA field.
+func loadDataField(value any) (string, error) {
+	pointer := reflect.ValueOf(value)
+	if pointer.Kind() == reflect.Pointer && pointer.IsNil() {
+		return `\N`, nil
+	}
+	switch v := value.(type) {
+	case nil:
+		return `\N`, nil
+	case string:
+		return loadDataEscaper.Replace(v), nil
+	case []byte:
+		if v == nil {
+			return `\N`, nil
+		}
+		return loadDataEscaper.Replace(string(v)), nil
+	case bool:
+		if v {
+			return "1", nil
+		}
+		return "0", nil
+	case time.Time:
+		// LOAD DATA fields have no time zone, so times are loaded in UTC.
+		return v.UTC().Format("2006-01-02 15:04:05.999999"), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	case driver.Valuer:
+		driverValue, err := v.Value()
+		if err != nil {
+			return "", err
+		}
+		return loadDataField(driverValue)
+	}
+	if pointer.Kind() == reflect.Pointer {
+		return loadDataField(pointer.Elem().Interface())
+	}
+	return loadDataEscaper.Replace(fmt.Sprint(value)), nil
+}
+
+// insertLoader loads rows with chunked multi-row inserts.
+type insertLoader struct {
+	preparer     Preparer
+	queryBuilder QueryBuilder
+	table        string
+	size         int
+	chunk        []InsertedValuesFn
+}
+
+func (l *insertLoader) add(entity Mutator, _ []any) error {
+	l.chunk = append(l.chunk, entity.InsertedValues)
+	if len(l.chunk) < l.size {
+		return nil
+	}
+	return l.flush()
+}
+
+func (l *insertLoader) close() error {
+	if len(l.chunk) == 0 {
+		return nil
+	}
+	return l.flush()
+}
+
+func (l *insertLoader) abort(error) {
+	l.chunk = nil
+}
+
+// flush inserts the buffered rows.
+func (l *insertLoader) flush() error {
+	query, params := l.queryBuilder.InsertMany(l.table, l.chunk)
+	l.chunk = l.chunk[:0]
+	_, err := doExec(l.preparer, query, params)
+	return err
+}
@@ -14,6 +14,10 @@ const (
 	NotIn          Predicate = "NOT IN"
 	Like           Predicate = "LIKE"
 	NotLike        Predicate = "NOT LIKE"
+	// Exists and NotExists take a subquery as the selector value and no
+	// column.
+	Exists    Predicate = "EXISTS"
+	NotExists Predicate = "NOT EXISTS"
 )
 
 // OrderDirection is used to specify the order of the result set.
@@ -25,11 +29,23 @@ const (
 	OrderDesc OrderDirection = "DESC"
 )
 
-// Order is used to specify the order of the result set.
+// NullsOrder is used to specify where NULL values are placed in the result
+// set.
+type NullsOrder string
+
+// Nulls orders.
+const (
+	NullsFirst NullsOrder = "NULLS FIRST"
+	NullsLast  NullsOrder = "NULLS LAST"
+)
+
+// Order is used to specify the order of the result set. An empty Nulls uses
+// the database default placement of NULL values.
 type Order struct {
 	Table     string
 	Field     string
 	Direction OrderDirection
+	Nulls     NullsOrder
 }
 
 // Orders is a list of orders
@@ -41,11 +57,15 @@ type ColumnSelector struct {
 	Column string
 }
 
-// Projection represents a projected column in a query.
+// Projection represents a projected column in a query. If Aggregate is set,
+// the projection is the aggregate of the column, e.g. SUM(amount). COUNT can
+// use "*" as the column.
 type Projection struct {
-	Table  string
-	Column string
-	Alias  string
+	Table     string
+	Column    string
+	Alias     string
+	Aggregate Aggregate
+	Distinct  bool // Aggregate only distinct values.
 }
 
 // Projections is a list of projections
@@ -215,14 +235,31 @@ const (
 	JoinTypeLeft  JoinType = "LEFT"
 	JoinTypeRight JoinType = "RIGHT"
 	JoinTypeFull  JoinType = "FULL"
+	JoinTypeCross JoinType = "CROSS"
 )
 
-// Join represents a database join clause.
+// Join represents a database join clause. The join is made on the
+// OnLeft = OnRight column pair, the additional column pairs in On and the
+// selectors, all combined with AND. If Alias is set, columns of the joined
+// table must be referenced by the alias, which allows self-joins. The target
+// can be a subquery instead of a table, which must then have an alias and can
+// be LATERAL to reference columns of the preceding tables.
 type Join struct {
-	Type    JoinType
-	Table   string
-	OnLeft  ColumnSelector
-	OnRight ColumnSelector
+	Type      JoinType
+	Table     string
+	OnLeft    ColumnSelector
+	OnRight   ColumnSelector
+	Alias     string
+	On        []JoinCondition
+	Selectors Selectors
+	Subquery  *Subquery
+	Lateral   bool
+}
+
+// JoinCondition is a Left = Right column pair of a join condition.
+type JoinCondition struct {
+	Left  ColumnSelector
+	Right ColumnSelector
 }
 
 // Joins is a list of joins
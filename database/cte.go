@@ -0,0 +1,204 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SelectQuery is a query that can be rendered as a SELECT statement, such as
+// a Subquery or a SetQuery.
+type SelectQuery interface {
+	Expr
+	// BuildSelect renders the SELECT statement without parentheses.
+	BuildSelect(dialect ExprDialect, params []any) (string, []any, error)
+}
+
+// SetOperation combines the rows of queries.
+type SetOperation string
+
+// Set operations.
+const (
+	Union    SetOperation = "UNION"     // Rows of all queries, deduplicated.
+	UnionAll SetOperation = "UNION ALL" // Rows of all queries.
+)
+
+// SetQuery combines the rows of queries with a set operation.
+type SetQuery struct {
+	Operation SetOperation
+	Queries   []*Subquery
+}
+
+// NewUnion creates a UNION of the queries.
+//
+// Parameters:
+//   - queries: The queries to combine.
+//
+// Returns:
+//   - *SetQuery: The new set query.
+func NewUnion(queries ...*Subquery) *SetQuery {
+	return &SetQuery{Operation: Union, Queries: queries}
+}
+
+// NewUnionAll creates a UNION ALL of the queries.
+//
+// Parameters:
+//   - queries: The queries to combine.
+//
+// Returns:
+//   - *SetQuery: The new set query.
+func NewUnionAll(queries ...*Subquery) *SetQuery {
+	return &SetQuery{Operation: UnionAll, Queries: queries}
+}
+
+// BuildExpr renders the set query in parentheses.
+func (s *SetQuery) BuildExpr(
+	dialect ExprDialect, params []any,
+) (string, []any, error) {
+	sql, params, err := s.BuildSelect(dialect, params)
+	if err != nil {
+		return "", nil, err
+	}
+	return "(" + sql + ")", params, nil
+}
+
+// BuildSelect renders the queries combined with the set operation.
+func (s *SetQuery) BuildSelect(
+	dialect ExprDialect, params []any,
+) (string, []any, error) {
+	if len(s.Queries) == 0 {
+		return "", nil, fmt.Errorf("BuildSelect: set query has no queries")
+	}
+	if s.Operation != Union && s.Operation != UnionAll {
+		return "", nil, fmt.Errorf(
+			"BuildSelect: invalid set operation: %s", s.Operation,
+		)
+	}
+	parts := make([]string, len(s.Queries))
+	for i, query := range s.Queries {
+		var err error
+		parts[i], params, err = query.BuildSelect(dialect, params)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	return strings.Join(parts, " "+string(s.Operation)+" "), params, nil
+}
+
+// CTE is a common table expression. A recursive CTE can reference itself in
+// the recursive term of its query, which is typically a UNION ALL of an
+// anchor query and the recursive query.
+type CTE struct {
+	Name      string
+	Columns   []string // Optional column names.
+	Query     SelectQuery
+	Recursive bool
+}
+
+// BuildWith renders a WITH clause of the CTEs. The clause is WITH RECURSIVE
+// if any of the CTEs is recursive.
+//
+// Example:
+//
+//	// Category tree starting from category 1.
+//	CTE{
+//	    Name:      "tree",
+//	    Recursive: true,
+//	    Query: NewUnionAll(
+//	        NewSubquery("category", &GetOptions{Selectors: Selectors{
+//	            {Table: "category", Column: "id", Predicate: Equal, Value: 1},
+//	        }}),
+//	        NewSubquery("category", &GetOptions{Joins: Joins{{
+//	            Type:    JoinTypeInner,
+//	            Table:   "tree",
+//	            OnLeft:  ColumnSelector{Table: "tree", Column: "id"},
+//	            OnRight: ColumnSelector{Table: "category", Column: "parent_id"},
+//	        }}}),
+//	    ),
+//	}
+//
+// Parameters:
+//   - dialect: The expression dialect.
+//   - ctes: The CTEs to render.
+//   - params: The statement parameters so far.
+//
+// Returns:
+//   - string: The SQL of the WITH clause, or an empty string if there are no
+//     CTEs.
+//   - []any: The statement parameters including those of the CTEs.
+//   - error: An error if a CTE cannot be rendered.
+func BuildWith(
+	dialect ExprDialect, ctes []CTE, params []any,
+) (string, []any, error) {
+	if len(ctes) == 0 {
+		return "", params, nil
+	}
+	recursive := false
+	parts := make([]string, len(ctes))
+	for i, cte := range ctes {
+		if cte.Name == "" || cte.Query == nil {
+			return "", nil, fmt.Errorf("BuildWith: CTE has no name or query")
+		}
+		recursive = recursive || cte.Recursive
+		sql, newParams, err := cte.Query.BuildSelect(dialect, params)
+		if err != nil {
+			return "", nil, err
+		}
+		params = newParams
+
+		name := dialect.QuoteColumn("", cte.Name)
+		if len(cte.Columns) > 0 {
+			columns := make([]string, len(cte.Columns))
+			for j, column := range cte.Columns {
+				columns[j] = dialect.QuoteColumn("", column)
+			}
+			name += " (" + strings.Join(columns, ", ") + ")"
+		}
+		parts[i] = fmt.Sprintf("%s AS (%s)", name, sql)
+	}
+	with := "WITH "
+	if recursive {
+		with = "WITH RECURSIVE "
+	}
+	return with + strings.Join(parts, ", "), params, nil
+}
+
+// BuildCompound renders the compound part of a query, which follows the
+// SELECT statement of the query itself, e.g. " UNION ALL SELECT ...".
+//
+// Parameters:
+//   - dialect: The expression dialect.
+//   - compound: The compound queries, or nil.
+//   - params: The statement parameters so far.
+//
+// Returns:
+//   - string: The SQL of the compound part, or an empty string if there is
+//     no compound.
+//   - []any: The statement parameters including those of the queries.
+//   - error: An error if a query cannot be rendered.
+func BuildCompound(
+	dialect ExprDialect, compound *SetQuery, params []any,
+) (string, []any, error) {
+	if compound == nil {
+		return "", params, nil
+	}
+	sql, params, err := compound.BuildSelect(dialect, params)
+	if err != nil {
+		return "", nil, err
+	}
+	return " " + string(compound.Operation) + " " + sql, params, nil
+}
+
+// checkWith checks that the CTEs and the compound queries of the get options
+// can be rendered by the query builder.
+func checkWith(queryBuilder QueryBuilder, options *GetOptions) error {
+	for _, cte := range options.With {
+		err := checkSelectQuery(queryBuilder, cte.Query, FeatureWith)
+		if err != nil {
+			return err
+		}
+	}
+	if options.Compound == nil {
+		return nil
+	}
+	return checkSelectQuery(queryBuilder, options.Compound, FeatureCompound)
+}
@@ -1,6 +1,8 @@
 package database
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 )
 
@@ -43,9 +45,17 @@ func (d *ReadDBOps[Entity]) Get(
 	if queryBuilder == nil {
 		return zero, fmt.Errorf("Get: queryBuilder is nil")
 	}
+	if err := checkGetOptions(queryBuilder, options); err != nil {
+		return zero, fmt.Errorf("Get: %w", err)
+	}
 
-	query, params := queryBuilder.Get(factoryFn().TableName(), options)
-	entity, err := querySingle(preparer, query, params, factoryFn)
+	options = options.withoutDeleted(factoryFn())
+	query, params := queryBuilder.Get(
+		options.Table(factoryFn().TableName()), options,
+	)
+	entity, err := querySingle(
+		preparer, query, params, factoryFn, options.Projections,
+	)
 	if err != nil {
 		if errorChecker == nil {
 			return zero, err
@@ -56,7 +66,9 @@ func (d *ReadDBOps[Entity]) Get(
 }
 
 // GetMany retrieves multiple entities of type T from the database that match
-// the given options.
+// the given options. The entities are selected from the table of the entity,
+// or from options.From, e.g. a CTE of the options. Use Iterate for results
+// too large to hold in memory.
 //
 // Parameters:
 //   - preparer: The database connection or transaction to use.
@@ -88,9 +100,17 @@ func (d *ReadDBOps[Entity]) GetMany(
 	if queryBuilder == nil {
 		return nil, fmt.Errorf("GetMany: queryBuilder is nil")
 	}
+	if err := checkGetOptions(queryBuilder, options); err != nil {
+		return nil, fmt.Errorf("GetMany: %w", err)
+	}
 
-	query, params := queryBuilder.Get(factoryFn().TableName(), options)
-	entities, err := queryMultiple(preparer, query, params, factoryFn)
+	options = options.withoutDeleted(factoryFn())
+	query, params := queryBuilder.Get(
+		options.Table(factoryFn().TableName()), options,
+	)
+	entities, err := queryMultiple(
+		preparer, query, params, factoryFn, options.Projections,
+	)
 	if err != nil {
 		if errorChecker == nil {
 			return nil, err
@@ -134,21 +154,144 @@ func (d *ReadDBOps[Entity]) Count(
 		return 0, fmt.Errorf("Count: queryBuilder is nil")
 	}
 
-	table := factoryFn().TableName()
-	query, params := queryBuilder.Count(table, options)
-	stmt, err := preparer.Prepare(query)
+	if err := checkJoins(queryBuilder, options.Joins); err != nil {
+		return 0, fmt.Errorf("Count: %w", err)
+	}
+	if err := checkSubqueries(queryBuilder, options.Selectors); err != nil {
+		return 0, fmt.Errorf("Count: %w", err)
+	}
+
+	entity := factoryFn()
+	query, params := queryBuilder.Count(
+		entity.TableName(), options.withoutDeleted(entity),
+	)
+	return queryCount(preparer, query, params, errorChecker)
+}
+
+// ListResult holds a list of entities and the total count of matching
+// records.
+type ListResult[Entity Getter] struct {
+	Entities  []Entity
+	Total     int
+	Estimated bool // Whether the total is an estimate.
+}
+
+// List retrieves the entities matching the given options together with the
+// total count of matching records, ignoring the page. With CountEstimated the
+// total is taken from the table statistics if the query builder implements
+// EstimatedCounter and there are no selectors, joins or excluded soft deleted
+// rows, otherwise an exact count is used. To read both from the same
+// snapshot, pass a transaction as the preparer or use ListTx.
+//
+// Parameters:
+//   - preparer: The database connection or transaction to use.
+//   - options: Filter and query options for the query.
+//   - countMode: How to determine the total count.
+//   - factoryFn: A function that returns a new instance of T.
+//   - queryBuilder: The SQL query builder for constructing the query.
+//   - errorChecker: Optional error checker to translate SQL driver errors into
+//     custom errors or skip them.
+//
+// Returns:
+//   - *ListResult[Entity]: The entities and the total count.
+//   - error: An error if either query fails.
+func (d *ReadDBOps[Entity]) List(
+	preparer Preparer,
+	options *GetOptions,
+	countMode CountMode,
+	factoryFn func() Entity,
+	queryBuilder QueryBuilder,
+	errorChecker ErrorChecker,
+) (*ListResult[Entity], error) {
+	if options == nil {
+		return nil, fmt.Errorf("List: options is nil")
+	}
+
+	entities, err := d.GetMany(
+		preparer, options, factoryFn, queryBuilder, errorChecker,
+	)
 	if err != nil {
-		return 0, errorChecker.Check(err)
+		return nil, err
 	}
-	defer stmt.Close()
-	var count int
-	if err := stmt.QueryRow(params...).Scan(&count); err != nil {
-		if errorChecker == nil {
-			return 0, err
+	result := &ListResult[Entity]{Entities: entities}
+
+	switch countMode {
+	case CountNone:
+		return result, nil
+	case CountEstimated:
+		counter, ok := queryBuilder.(EstimatedCounter)
+		if ok && len(options.Selectors) == 0 && len(options.Joins) == 0 &&
+			!excludesDeleted(factoryFn(), options.IncludeDeleted) {
+			query, params := counter.EstimatedCount(factoryFn().TableName())
+			total, err := queryCount(preparer, query, params, errorChecker)
+			if err != nil {
+				return nil, err
+			}
+			result.Total = total
+			result.Estimated = true
+			return result, nil
 		}
-		return 0, errorChecker.Check(err)
 	}
-	return count, nil
+
+	total, err := d.Count(
+		preparer,
+		&CountOptions{
+			Selectors:      options.Selectors,
+			Joins:          options.Joins,
+			IncludeDeleted: options.IncludeDeleted,
+		},
+		factoryFn,
+		queryBuilder,
+		errorChecker,
+	)
+	if err != nil {
+		return nil, err
+	}
+	result.Total = total
+	return result, nil
+}
+
+// ListTx runs List in a read-only transaction, so that the entities and the
+// total count are read from the same snapshot.
+//
+// Parameters:
+//   - ctx: The context for the transaction.
+//   - db: The database connection to begin the transaction on.
+//   - options: Filter and query options for the query.
+//   - countMode: How to determine the total count.
+//   - factoryFn: A function that returns a new instance of T.
+//   - queryBuilder: The SQL query builder for constructing the query.
+//   - errorChecker: Optional error checker to translate SQL driver errors into
+//     custom errors or skip them.
+//
+// Returns:
+//   - *ListResult[Entity]: The entities and the total count.
+//   - error: An error if the transaction or either query fails.
+func (d *ReadDBOps[Entity]) ListTx(
+	ctx context.Context,
+	db DB,
+	options *GetOptions,
+	countMode CountMode,
+	factoryFn func() Entity,
+	queryBuilder QueryBuilder,
+	errorChecker ErrorChecker,
+) (*ListResult[Entity], error) {
+	if db == nil {
+		return nil, fmt.Errorf("ListTx: db is nil")
+	}
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	return Transaction(
+		ctx,
+		tx,
+		func(ctx context.Context, tx Tx) (*ListResult[Entity], error) {
+			return d.List(
+				tx, options, countMode, factoryFn, queryBuilder, errorChecker,
+			)
+		},
+	)
 }
 
 // ReadDBOps provides methods to perform database write operations.
@@ -194,7 +337,9 @@ func (d *MutateDBOps[Entity]) Insert(
 	return checkInsertResult(result, err, errorChecker)
 }
 
-// InsertMany inserts multiple entities in one batch operation.
+// InsertMany inserts multiple entities in one batch operation. Use
+// InsertBatch for batches that may exceed the statement size or parameter
+// limits of the database.
 //
 // Parameters:
 //   - preparer: The database connection or transaction to use.
@@ -235,7 +380,8 @@ func (d *MutateDBOps[Entity]) InsertMany(
 
 // UpsertMany performs an "insert or update" (upsert) for multiple entities in
 // one operation. This is useful for bulk inserts that should update on key
-// conflicts (if supported by the DB).
+// conflicts (if supported by the DB). Use UpsertBatch for batches that may
+// exceed the statement size or parameter limits of the database.
 //
 // Parameters:
 //   - preparer: The database connection or transaction to use.
@@ -262,11 +408,8 @@ func (d *MutateDBOps[Entity]) UpsertMany(
 	if len(mutators) == 0 {
 		return 0, fmt.Errorf("UpsertMany: must provide entities to upsert")
 	}
-	if len(updateProjections) == 0 {
-		return 0, fmt.Errorf("UpsertMany: must provide update projections")
-	}
-	if len(updateProjections[0].Alias) == 0 {
-		return 0, fmt.Errorf("UpsertMany: update projections must include an alias for the upserted table")
+	if err := checkUpsertProjections(updateProjections); err != nil {
+		return 0, fmt.Errorf("UpsertMany: %w", err)
 	}
 
 	// Prepare batch values for upsert
@@ -282,6 +425,9 @@ func (d *MutateDBOps[Entity]) UpsertMany(
 }
 
 // Update applies the given field updates to all records matching the selectors.
+// Update values can be expressions such as Increment or Func(FuncNow) if the
+// query builder implements ExprDialect. Soft deleted records of entities
+// implementing SoftDeleter are not updated.
 //
 // Parameters:
 //   - preparer: The database connection or transaction to use.
@@ -314,15 +460,23 @@ func (*MutateDBOps[Entity]) Update(
 	if queryBuilder == nil {
 		return 0, fmt.Errorf("Update: queryBuilder is nil")
 	}
+	if err := checkUpdateExprs(queryBuilder, updates); err != nil {
+		return 0, fmt.Errorf("Update: %w", err)
+	}
 
 	query, args := queryBuilder.UpdateQuery(
-		tableNamer.TableName(), updates, selectors,
+		tableNamer.TableName(),
+		updates,
+		excludeDeleted(tableNamer, tableNamer.TableName(), selectors),
 	)
 	result, err := doExec(preparer, query, args)
 	return checkUpdateResult(result, err, errorChecker)
 }
 
 // Delete removes records from the database table matching the given selectors.
+// Records of entities implementing SoftDeleter are soft deleted instead by
+// setting the soft delete column to the current time, which does not support
+// the limit and orders of the options. Use HardDelete to remove them.
 //
 // Parameters:
 //   - preparer: The database connection or transaction to use.
@@ -356,20 +510,32 @@ func (*MutateDBOps[Entity]) Delete(
 	if queryBuilder == nil {
 		return 0, fmt.Errorf("Delete: queryBuilder is nil")
 	}
+	if softDeleter, ok := entity.(SoftDeleter); ok {
+		if opts.Limit > 0 || len(opts.Orders) > 0 {
+			return 0, fmt.Errorf(
+				"Delete: soft delete does not support limit and orders",
+			)
+		}
+		return softDelete(
+			preparer,
+			entity,
+			softDeleter,
+			selectors,
+			queryBuilder,
+			errorChecker,
+		)
+	}
 
 	query, params := queryBuilder.Delete(
 		entity.TableName(), selectors, opts,
 	)
 	result, err := doExec(preparer, query, params)
 	if err != nil {
-		return 0, errorChecker.Check(err)
+		return 0, checkError(err, errorChecker)
 	}
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		if errorChecker == nil {
-			return 0, err
-		}
-		return 0, errorChecker.Check(err)
+		return 0, checkError(err, errorChecker)
 	}
 	return rowsAffected, nil
 }
@@ -529,7 +695,8 @@ func RowToEntity[T Getter](row Row, factoryFn func() T) (T, error) {
 	return entity, nil
 }
 
-// RowsToEntities scans all rows into a slice of entities of type T.
+// RowsToEntities scans all rows into a slice of entities of type T. Use
+// IterateRows to scan the rows one at a time.
 //
 // Parameters:
 //   - rows: The Rows to scan.
@@ -559,15 +726,15 @@ func checkInsertResult(
 	result Result, err error, errorChecker ErrorChecker,
 ) (int64, error) {
 	// Use the error checker to translate errors (e.g., duplicate key).
-	if err != nil && errorChecker != nil {
-		return 0, errorChecker.Check(err)
+	if err != nil {
+		return 0, checkError(err, errorChecker)
 	}
 	if result == nil {
 		return 0, nil // No result (no ID available).
 	}
 	id, err := result.LastInsertId()
 	if err != nil {
-		return 0, errorChecker.Check(err)
+		return 0, checkError(err, errorChecker)
 	}
 	return id, nil
 }
@@ -578,22 +745,34 @@ func checkUpdateResult(
 	result Result, err error, errorChecker ErrorChecker,
 ) (int64, error) {
 	// Use the error checker to translate errors (e.g., duplicate key).
-	if err != nil && errorChecker != nil {
-		return 0, errorChecker.Check(err)
+	if err != nil {
+		return 0, checkError(err, errorChecker)
 	}
 	if result == nil {
 		return 0, nil
 	}
 	count, err := result.RowsAffected()
 	if err != nil {
-		return 0, errorChecker.Check(err)
+		return 0, checkError(err, errorChecker)
 	}
 	return count, nil
 }
 
+// checkError translates the error with the error checker if there is one.
+func checkError(err error, errorChecker ErrorChecker) error {
+	if errorChecker == nil {
+		return err
+	}
+	return errorChecker.Check(err)
+}
+
 // queryMultiple queries and scans multiple entities of type T.
 func queryMultiple[T Getter](
-	preparer Preparer, query string, params []any, factoryFn func() T,
+	preparer Preparer,
+	query string,
+	params []any,
+	factoryFn func() T,
+	projections Projections,
 ) ([]T, error) {
 	rows, stmt, err := doQuery(preparer, query, params)
 	if err != nil {
@@ -601,12 +780,44 @@ func queryMultiple[T Getter](
 	}
 	defer rows.Close()
 	defer stmt.Close()
-	return RowsToEntities(rows, factoryFn)
+	results := []T{}
+	for rows.Next() {
+		entity := factoryFn()
+		if err := scanEntity(entity, rows, projections); err != nil {
+			return nil, err
+		}
+		results = append(results, entity)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// queryCount queries a single count value, e.g. of Count or of an estimated
+// count.
+func queryCount(
+	preparer Preparer, query string, params []any, errorChecker ErrorChecker,
+) (int, error) {
+	stmt, err := preparer.Prepare(query)
+	if err != nil {
+		return 0, checkError(err, errorChecker)
+	}
+	defer stmt.Close()
+	var count int
+	if err := stmt.QueryRow(params...).Scan(&count); err != nil {
+		return 0, checkError(err, errorChecker)
+	}
+	return count, nil
 }
 
 // querySingle queries and scans a single entity of type T.
 func querySingle[T Getter](
-	preparer Preparer, query string, params []any, factoryFn func() T,
+	preparer Preparer,
+	query string,
+	params []any,
+	factoryFn func() T,
+	projections Projections,
 ) (T, error) {
 	var zero T
 	stmt, err := preparer.Prepare(query)
@@ -615,7 +826,15 @@ func querySingle[T Getter](
 	}
 	defer stmt.Close()
 	// Use QueryRow since we expect at most one result.
-	return RowToEntity(stmt.QueryRow(params...), factoryFn)
+	row := stmt.QueryRow(params...)
+	entity := factoryFn()
+	if err := scanEntity(entity, row, projections); err != nil {
+		return zero, err
+	}
+	if err := row.Err(); err != nil {
+		return zero, err
+	}
+	return entity, nil
 }
 
 // doExec is a helper to execute an SQL query without error checking.
@@ -0,0 +1,413 @@
+package database
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Function is a server-side SQL function usable in expressions. Functions are
+// a closed set, so that dialects can map them to their own SQL and no
+// arbitrary SQL can be injected through function names.
+type Function string
+
+// Functions.
+const (
+	FuncNow         Function = "NOW"          // Current timestamp.
+	FuncCurrentDate Function = "CURRENT_DATE" // Current date.
+	FuncLower       Function = "LOWER"        // Lower case string.
+	FuncUpper       Function = "UPPER"        // Upper case string.
+	FuncCoalesce    Function = "COALESCE"     // First non-NULL argument.
+)
+
+// ExprDialect is implemented by query builders that support expressions. It
+// provides the dialect specific parts of expression SQL.
+type ExprDialect interface {
+	// QuoteColumn returns the quoted column reference. The table is optional.
+	QuoteColumn(table string, column string) string
+	// Placeholder returns the placeholder of the parameter at the given
+	// 1-based position in the statement.
+	Placeholder(position int) string
+	// Function returns the SQL of the function call with the given rendered
+	// arguments, or false if the dialect does not support the function.
+	Function(fn Function, args []string) (string, bool)
+}
+
+// Expr is an SQL expression. An expression can be used as the value of an
+// Update, in which case the column is set to the result of the expression.
+// Literal values inside expressions are always passed as parameters.
+type Expr interface {
+	// BuildExpr renders the expression for the dialect. The parameters of
+	// the expression are appended to the given statement parameters.
+	BuildExpr(dialect ExprDialect, params []any) (string, []any, error)
+}
+
+// ColumnExpr is a reference to a column.
+type ColumnExpr struct {
+	Table  string
+	Column string
+}
+
+// Column returns an expression referencing a column of the updated table.
+//
+// Parameters:
+//   - column: The column name.
+//
+// Returns:
+//   - ColumnExpr: The column reference.
+func Column(column string) ColumnExpr {
+	return ColumnExpr{Column: column}
+}
+
+// BuildExpr renders the column reference.
+func (e ColumnExpr) BuildExpr(
+	dialect ExprDialect, params []any,
+) (string, []any, error) {
+	if e.Column == "" {
+		return "", nil, fmt.Errorf("BuildExpr: column is empty")
+	}
+	return dialect.QuoteColumn(e.Table, e.Column), params, nil
+}
+
+// ArithmeticExpr adds or subtracts a value from an expression.
+type ArithmeticExpr struct {
+	Left     any
+	Operator string // "+" or "-".
+	Right    any
+}
+
+// Increment returns an expression incrementing the column by the amount,
+// e.g. "views = views + 1".
+//
+// Parameters:
+//   - column: The column to increment.
+//   - amount: The amount to add.
+//
+// Returns:
+//   - ArithmeticExpr: The increment expression.
+func Increment(column string, amount any) ArithmeticExpr {
+	return ArithmeticExpr{Left: Column(column), Operator: "+", Right: amount}
+}
+
+// Decrement returns an expression decrementing the column by the amount.
+//
+// Parameters:
+//   - column: The column to decrement.
+//   - amount: The amount to subtract.
+//
+// Returns:
+//   - ArithmeticExpr: The decrement expression.
+func Decrement(column string, amount any) ArithmeticExpr {
+	return ArithmeticExpr{Left: Column(column), Operator: "-", Right: amount}
+}
+
+// BuildExpr renders the arithmetic expression. Operands that are expressions
+// other than column references are wrapped in parentheses, so that nested
+// expressions keep their grouping, e.g. "x - (y + 1)".
+func (e ArithmeticExpr) BuildExpr(
+	dialect ExprDialect, params []any,
+) (string, []any, error) {
+	if e.Operator != "+" && e.Operator != "-" {
+		return "", nil, fmt.Errorf(
+			"BuildExpr: invalid operator: %s", e.Operator,
+		)
+	}
+	left, params, err := buildOperand(dialect, e.Left, params)
+	if err != nil {
+		return "", nil, err
+	}
+	right, params, err := buildOperand(dialect, e.Right, params)
+	if err != nil {
+		return "", nil, err
+	}
+	return fmt.Sprintf("%s %s %s", left, e.Operator, right), params, nil
+}
+
+// buildOperand renders an operand of an arithmetic expression, wrapping
+// expressions other than column references in parentheses.
+func buildOperand(
+	dialect ExprDialect, operand any, params []any,
+) (string, []any, error) {
+	sql, params, err := BuildValue(dialect, operand, params)
+	if err != nil {
+		return "", nil, err
+	}
+	switch operand.(type) {
+	case ColumnExpr, *ColumnExpr:
+		return sql, params, nil
+	case Expr:
+		return "(" + sql + ")", params, nil
+	default:
+		return sql, params, nil
+	}
+}
+
+// FuncExpr is a call of a server-side function.
+type FuncExpr struct {
+	Function Function
+	Args     []any
+}
+
+// Func returns an expression calling a server-side function, e.g.
+// Func(FuncNow) to use the database clock.
+//
+// Parameters:
+//   - fn: The function.
+//   - args: The arguments, which can be values or expressions.
+//
+// Returns:
+//   - FuncExpr: The function call.
+func Func(fn Function, args ...any) FuncExpr {
+	return FuncExpr{Function: fn, Args: args}
+}
+
+// BuildExpr renders the function call.
+func (e FuncExpr) BuildExpr(
+	dialect ExprDialect, params []any,
+) (string, []any, error) {
+	args := make([]string, len(e.Args))
+	for i, arg := range e.Args {
+		var err error
+		args[i], params, err = BuildValue(dialect, arg, params)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	sql, ok := dialect.Function(e.Function, args)
+	if !ok {
+		return "", nil, fmt.Errorf(
+			"BuildExpr: function not supported: %s", e.Function,
+		)
+	}
+	return sql, params, nil
+}
+
+// When is a branch of a CASE expression. The branch is taken if all
+// selectors match.
+type When struct {
+	Selectors Selectors
+	Then      any
+}
+
+// CaseExpr is a CASE expression.
+type CaseExpr struct {
+	Whens []When
+	Else  any // Optional, NULL if nil.
+}
+
+// Case returns a CASE expression.
+//
+// Parameters:
+//   - whens: The branches of the expression.
+//
+// Returns:
+//   - CaseExpr: The CASE expression.
+func Case(whens ...When) CaseExpr {
+	return CaseExpr{Whens: whens}
+}
+
+// WithElse returns a copy of the CASE expression with the given else value.
+//
+// Parameters:
+//   - value: The value if no branch is taken.
+//
+// Returns:
+//   - CaseExpr: The new CASE expression.
+func (e CaseExpr) WithElse(value any) CaseExpr {
+	e.Else = value
+	return e
+}
+
+// BuildExpr renders the CASE expression.
+func (e CaseExpr) BuildExpr(
+	dialect ExprDialect, params []any,
+) (string, []any, error) {
+	if len(e.Whens) == 0 {
+		return "", nil, fmt.Errorf("BuildExpr: CASE has no branches")
+	}
+	var builder strings.Builder
+	builder.WriteString("CASE")
+	for _, when := range e.Whens {
+		condition, newParams, err := BuildSelectors(
+			dialect, when.Selectors, params,
+		)
+		if err != nil {
+			return "", nil, err
+		}
+		then, newParams, err := BuildValue(dialect, when.Then, newParams)
+		if err != nil {
+			return "", nil, err
+		}
+		params = newParams
+		fmt.Fprintf(&builder, " WHEN %s THEN %s", condition, then)
+	}
+	if e.Else != nil {
+		elseSQL, newParams, err := BuildValue(dialect, e.Else, params)
+		if err != nil {
+			return "", nil, err
+		}
+		params = newParams
+		fmt.Fprintf(&builder, " ELSE %s", elseSQL)
+	}
+	builder.WriteString(" END")
+	return builder.String(), params, nil
+}
+
+// BuildValue renders a value for the dialect. Expressions are rendered with
+// BuildExpr and other values become parameters.
+//
+// Parameters:
+//   - dialect: The expression dialect.
+//   - value: The value or expression.
+//   - params: The statement parameters so far.
+//
+// Returns:
+//   - string: The SQL of the value.
+//   - []any: The statement parameters including those of the value.
+//   - error: An error if the expression cannot be rendered.
+func BuildValue(
+	dialect ExprDialect, value any, params []any,
+) (string, []any, error) {
+	if expr, ok := value.(Expr); ok {
+		return expr.BuildExpr(dialect, params)
+	}
+	params = append(params, value)
+	return dialect.Placeholder(len(params)), params, nil
+}
+
+// BuildSelectors renders selectors combined with AND. IN and NOT IN values
+// must be slices or subqueries with a single projection, EXISTS and NOT
+// EXISTS values must be subqueries, and Equal and NotEqual with a nil value
+// render IS NULL and IS NOT NULL. Values can be column references, which
+// correlate the selectors of a subquery with the outer query.
+//
+// Parameters:
+//   - dialect: The expression dialect.
+//   - selectors: The selectors to render.
+//   - params: The statement parameters so far.
+//
+// Returns:
+//   - string: The SQL of the condition.
+//   - []any: The statement parameters including those of the selectors.
+//   - error: An error if a selector cannot be rendered.
+func BuildSelectors(
+	dialect ExprDialect, selectors Selectors, params []any,
+) (string, []any, error) {
+	if len(selectors) == 0 {
+		return "", nil, fmt.Errorf("BuildSelectors: no selectors")
+	}
+	conditions := make([]string, len(selectors))
+	for i, selector := range selectors {
+		var err error
+		conditions[i], params, err = buildSelector(dialect, selector, params)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	return strings.Join(conditions, " AND "), params, nil
+}
+
+// buildSelector renders a single selector.
+func buildSelector(
+	dialect ExprDialect, selector Selector, params []any,
+) (string, []any, error) {
+	subquery, isSubquery := selector.Value.(*Subquery)
+	if selector.Predicate == Exists || selector.Predicate == NotExists {
+		if !isSubquery {
+			return "", nil, fmt.Errorf(
+				"BuildSelectors: %s value must be a subquery",
+				selector.Predicate,
+			)
+		}
+		sql, params, err := subquery.BuildExpr(dialect, params)
+		if err != nil {
+			return "", nil, err
+		}
+		return fmt.Sprintf("%s %s", selector.Predicate, sql), params, nil
+	}
+
+	column := dialect.QuoteColumn(selector.Table, selector.Column)
+	switch {
+	case selector.Value == nil && selector.Predicate == Equal:
+		return column + " IS NULL", params, nil
+	case selector.Value == nil && selector.Predicate == NotEqual:
+		return column + " IS NOT NULL", params, nil
+	case selector.Predicate == In || selector.Predicate == NotIn:
+		if isSubquery {
+			if len(subquery.Options.Projections) != 1 {
+				return "", nil, fmt.Errorf(
+					"BuildSelectors: %s subquery of %s must have one projection",
+					selector.Predicate,
+					selector.Column,
+				)
+			}
+			sql, params, err := subquery.BuildExpr(dialect, params)
+			if err != nil {
+				return "", nil, err
+			}
+			return fmt.Sprintf(
+				"%s %s %s", column, selector.Predicate, sql,
+			), params, nil
+		}
+		list := reflect.ValueOf(selector.Value)
+		if list.Kind() != reflect.Slice || list.Len() == 0 {
+			return "", nil, fmt.Errorf(
+				"BuildSelectors: %s value of %s must be a non-empty slice",
+				selector.Predicate,
+				selector.Column,
+			)
+		}
+		placeholders := make([]string, list.Len())
+		for j := range placeholders {
+			var err error
+			placeholders[j], params, err = BuildValue(
+				dialect, list.Index(j).Interface(), params,
+			)
+			if err != nil {
+				return "", nil, err
+			}
+		}
+		return fmt.Sprintf(
+			"%s %s (%s)",
+			column,
+			selector.Predicate,
+			strings.Join(placeholders, ", "),
+		), params, nil
+	case !isComparison(selector.Predicate) &&
+		selector.Predicate != Like && selector.Predicate != NotLike:
+		return "", nil, fmt.Errorf(
+			"BuildSelectors: invalid predicate: %s", selector.Predicate,
+		)
+	default:
+		value, params, err := BuildValue(dialect, selector.Value, params)
+		if err != nil {
+			return "", nil, err
+		}
+		return fmt.Sprintf(
+			"%s %s %s", column, selector.Predicate, value,
+		), params, nil
+	}
+}
+
+// checkUpdateExprs checks that the expressions of the updates can be rendered
+// by the query builder.
+func checkUpdateExprs(queryBuilder QueryBuilder, updates []Update) error {
+	dialect, supported := queryBuilder.(ExprDialect)
+	var params []any
+	for _, update := range updates {
+		if _, ok := update.Value.(Expr); !ok {
+			continue
+		}
+		if !supported {
+			return fmt.Errorf(
+				"query builder does not support update expressions",
+			)
+		}
+		_, newParams, err := BuildValue(dialect, update.Value, params)
+		if err != nil {
+			return fmt.Errorf("update of %s: %w", update.Field, err)
+		}
+		params = newParams
+	}
+	return nil
+}
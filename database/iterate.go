@@ -0,0 +1,129 @@
+package database
+
+import (
+	"fmt"
+	"iter"
+)
+
+// IterateRows returns an iterator that scans the rows into entities one at a
+// time, so that large results do not have to fit in memory. The rows are
+// closed when the iteration ends, including when the loop exits early. If a
+// scan fails, the error is yielded and the iteration ends. The iterator can
+// only be used once.
+//
+// Parameters:
+//   - rows: The Rows to scan.
+//   - factoryFn: A function that returns a new instance of T.
+//
+// Returns:
+//   - iter.Seq2[T, error]: The iterator of the entities.
+func IterateRows[T Getter](
+	rows Rows, factoryFn func() T,
+) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		defer rows.Close()
+		yieldRows(rows, factoryFn, nil, nil, yield)
+	}
+}
+
+// Iterate returns an iterator over the entities matching the options. The
+// query is executed when the iteration starts, and the statement and rows are
+// closed when it ends, including when the loop exits early. Errors are yielded
+// with a zero entity and end the iteration. Each iteration executes the query
+// again.
+//
+// Example:
+//
+//	for user, err := range users.Iterate(db, options, newUser, qb, nil) {
+//	    if err != nil {
+//	        return err
+//	    }
+//	    ...
+//	}
+//
+// Parameters:
+//   - preparer: The database connection or transaction to use.
+//   - options: Filter and query options for the query.
+//   - factoryFn: A function that returns a new instance of T.
+//   - queryBuilder: The SQL query builder for constructing the query.
+//   - errorChecker: Optional error checker to translate SQL driver errors into
+//     custom errors or skip them.
+//
+// Returns:
+//   - iter.Seq2[Entity, error]: The iterator of the entities.
+func (d *ReadDBOps[Entity]) Iterate(
+	preparer Preparer,
+	options *GetOptions,
+	factoryFn func() Entity,
+	queryBuilder QueryBuilder,
+	errorChecker ErrorChecker,
+) iter.Seq2[Entity, error] {
+	return func(yield func(Entity, error) bool) {
+		var zero Entity
+		if err := checkIterateArgs(
+			preparer, options, factoryFn, queryBuilder,
+		); err != nil {
+			yield(zero, fmt.Errorf("Iterate: %w", err))
+			return
+		}
+		options := options.withoutDeleted(factoryFn())
+		query, params := queryBuilder.Get(
+			options.Table(factoryFn().TableName()), options,
+		)
+		rows, stmt, err := doQuery(preparer, query, params)
+		if err != nil {
+			yield(zero, checkError(err, errorChecker))
+			return
+		}
+		defer stmt.Close()
+		defer rows.Close()
+		yieldRows(rows, factoryFn, options.Projections, errorChecker, yield)
+	}
+}
+
+// yieldRows scans the rows into entities and yields them until the rows end,
+// an error occurs or yield returns false.
+func yieldRows[T Getter](
+	rows Rows,
+	factoryFn func() T,
+	projections Projections,
+	errorChecker ErrorChecker,
+	yield func(T, error) bool,
+) {
+	var zero T
+	for rows.Next() {
+		entity := factoryFn()
+		if err := scanEntity(entity, rows, projections); err != nil {
+			yield(zero, err)
+			return
+		}
+		if !yield(entity, nil) {
+			return
+		}
+	}
+	if err := rows.Err(); err != nil {
+		yield(zero, checkError(err, errorChecker))
+	}
+}
+
+// checkIterateArgs checks the arguments of Iterate.
+func checkIterateArgs[Entity Getter](
+	preparer Preparer,
+	options *GetOptions,
+	factoryFn func() Entity,
+	queryBuilder QueryBuilder,
+) error {
+	if preparer == nil {
+		return fmt.Errorf("preparer is nil")
+	}
+	if options == nil {
+		return fmt.Errorf("options is nil")
+	}
+	if factoryFn == nil {
+		return fmt.Errorf("factoryFn is nil")
+	}
+	if queryBuilder == nil {
+		return fmt.Errorf("queryBuilder is nil")
+	}
+	return checkGetOptions(queryBuilder, options)
+}
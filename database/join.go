@@ -0,0 +1,229 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+// JoinDialect is implemented by query builders that render joins with
+// BuildJoin.
+type JoinDialect interface {
+	ExprDialect
+	// QuoteTable returns the quoted table name or alias.
+	QuoteTable(table string) string
+	// SupportsJoin reports whether the dialect supports the join type, as a
+	// LATERAL join if lateral is set.
+	SupportsJoin(joinType JoinType, lateral bool) bool
+}
+
+// UnsupportedJoinError is returned when a dialect does not support a join.
+type UnsupportedJoinError struct {
+	Type    JoinType
+	Lateral bool
+}
+
+// Error returns the error message.
+func (e *UnsupportedJoinError) Error() string {
+	if e.Lateral {
+		return fmt.Sprintf("unsupported join type: %s LATERAL", e.Type)
+	}
+	return fmt.Sprintf("unsupported join type: %s", e.Type)
+}
+
+// NewJoin creates a new join of the table.
+//
+// Parameters:
+//   - joinType: The join type.
+//   - table: The table to join.
+//
+// Returns:
+//   - Join: The new join.
+func NewJoin(joinType JoinType, table string) Join {
+	return Join{
+		Type:  joinType,
+		Table: table,
+	}
+}
+
+// WithAlias returns a copy of the join with the given alias.
+//
+// Parameters:
+//   - alias: The alias of the joined table.
+//
+// Returns:
+//   - Join: The new join.
+func (j Join) WithAlias(alias string) Join {
+	j.Alias = alias
+	return j
+}
+
+// WithOn returns a copy of the join with an additional left = right
+// condition.
+//
+// Parameters:
+//   - left: The left column.
+//   - right: The right column.
+//
+// Returns:
+//   - Join: The new join.
+func (j Join) WithOn(left ColumnSelector, right ColumnSelector) Join {
+	j.On = append(append([]JoinCondition{}, j.On...), JoinCondition{
+		Left:  left,
+		Right: right,
+	})
+	return j
+}
+
+// WithSelectors returns a copy of the join with additional selectors in its
+// condition, e.g. to join only rows that are not deleted.
+//
+// Parameters:
+//   - selectors: The selectors to add.
+//
+// Returns:
+//   - Join: The new join.
+func (j Join) WithSelectors(selectors ...Selector) Join {
+	j.Selectors = append(append(Selectors{}, j.Selectors...), selectors...)
+	return j
+}
+
+// Conditions returns the column pairs of the join condition, including the
+// OnLeft = OnRight pair if it is set.
+//
+// Returns:
+//   - []JoinCondition: The column pairs.
+func (j Join) Conditions() []JoinCondition {
+	var conditions []JoinCondition
+	if j.OnLeft.Column != "" || j.OnRight.Column != "" {
+		conditions = append(conditions, JoinCondition{
+			Left:  j.OnLeft,
+			Right: j.OnRight,
+		})
+	}
+	return append(conditions, j.On...)
+}
+
+// BuildJoin renders a join for the dialect.
+//
+// Parameters:
+//   - dialect: The join dialect.
+//   - join: The join to render.
+//   - params: The statement parameters so far.
+//
+// Returns:
+//   - string: The SQL of the join.
+//   - []any: The statement parameters including those of the join.
+//   - error: An UnsupportedJoinError if the dialect does not support the
+//     join, or an error if the join is invalid.
+func BuildJoin(
+	dialect JoinDialect, join Join, params []any,
+) (string, []any, error) {
+	if !dialect.SupportsJoin(join.Type, join.Lateral) {
+		return "", nil, &UnsupportedJoinError{
+			Type:    join.Type,
+			Lateral: join.Lateral,
+		}
+	}
+	if join.Lateral && join.Subquery == nil {
+		return "", nil, fmt.Errorf("BuildJoin: LATERAL join has no subquery")
+	}
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "%s JOIN ", join.Type)
+	if join.Lateral {
+		builder.WriteString("LATERAL ")
+	}
+	if join.Subquery != nil {
+		if join.Alias == "" {
+			return "", nil, fmt.Errorf("BuildJoin: subquery has no alias")
+		}
+		sql, newParams, err := join.Subquery.BuildExpr(dialect, params)
+		if err != nil {
+			return "", nil, err
+		}
+		params = newParams
+		builder.WriteString(sql)
+	} else {
+		if join.Table == "" {
+			return "", nil, fmt.Errorf("BuildJoin: join has no table")
+		}
+		builder.WriteString(dialect.QuoteTable(join.Table))
+	}
+	if join.Alias != "" {
+		builder.WriteString(" AS " + dialect.QuoteTable(join.Alias))
+	}
+
+	conditions := join.Conditions()
+	if join.Type == JoinTypeCross {
+		if len(conditions) > 0 || len(join.Selectors) > 0 {
+			return "", nil, fmt.Errorf("BuildJoin: CROSS join has conditions")
+		}
+		return builder.String(), params, nil
+	}
+
+	parts := make([]string, 0, len(conditions)+1)
+	for _, condition := range conditions {
+		parts = append(parts, fmt.Sprintf(
+			"%s = %s",
+			dialect.QuoteColumn(condition.Left.Table, condition.Left.Column),
+			dialect.QuoteColumn(condition.Right.Table, condition.Right.Column),
+		))
+	}
+	if len(join.Selectors) > 0 {
+		sql, newParams, err := BuildSelectors(dialect, join.Selectors, params)
+		if err != nil {
+			return "", nil, err
+		}
+		params = newParams
+		parts = append(parts, sql)
+	}
+	switch {
+	case len(parts) > 0:
+		builder.WriteString(" ON " + strings.Join(parts, " AND "))
+	case join.Lateral:
+		builder.WriteString(" ON TRUE")
+	default:
+		return "", nil, fmt.Errorf("BuildJoin: join has no conditions")
+	}
+	return builder.String(), params, nil
+}
+
+// checkJoins checks that the joins can be rendered by the query builder.
+// Joins on a single column pair are supported by all query builders.
+func checkJoins(queryBuilder QueryBuilder, joins Joins) error {
+	dialect, supported := queryBuilder.(JoinDialect)
+	for _, join := range joins {
+		switch {
+		case supported:
+			if !dialect.SupportsJoin(join.Type, join.Lateral) {
+				return &UnsupportedJoinError{
+					Type:    join.Type,
+					Lateral: join.Lateral,
+				}
+			}
+		case join.Type == JoinTypeCross || join.Lateral:
+			return &UnsupportedJoinError{
+				Type:    join.Type,
+				Lateral: join.Lateral,
+			}
+		case join.Alias != "" || len(join.On) > 0 ||
+			len(join.Selectors) > 0 || join.Subquery != nil:
+			return fmt.Errorf(
+				"query builder does not support join aliases, " +
+					"conditions or subqueries",
+			)
+		}
+		if join.Subquery != nil {
+			err := checkSelectQuery(
+				queryBuilder, join.Subquery, FeatureSubquery,
+			)
+			if err != nil {
+				return err
+			}
+		}
+		if err := checkSubqueries(queryBuilder, join.Selectors); err != nil {
+			return err
+		}
+	}
+	return nil
+}
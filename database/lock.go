@@ -0,0 +1,175 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LockStrength is the strength of a row lock.
+type LockStrength string
+
+// Lock strengths.
+const (
+	LockForUpdate      LockStrength = "FOR UPDATE"
+	LockForNoKeyUpdate LockStrength = "FOR NO KEY UPDATE"
+	LockForShare       LockStrength = "FOR SHARE"
+	LockForKeyShare    LockStrength = "FOR KEY SHARE"
+)
+
+// LockWait controls what happens when a row is already locked. The zero
+// value waits for the lock.
+type LockWait string
+
+// Lock wait policies.
+const (
+	LockNoWait     LockWait = "NOWAIT"      // Fail if a row is locked.
+	LockSkipLocked LockWait = "SKIP LOCKED" // Skip locked rows.
+)
+
+// Locking is the row locking of a get query.
+type Locking struct {
+	Strength LockStrength
+	Wait     LockWait
+	// Of limits the lock to the given tables or aliases of a join.
+	Of []string
+}
+
+// NewLocking creates a new locking with the given strength.
+//
+// Parameters:
+//   - strength: The lock strength.
+//
+// Returns:
+//   - *Locking: The new locking.
+func NewLocking(strength LockStrength) *Locking {
+	return &Locking{Strength: strength}
+}
+
+// WithWait returns a copy of the locking with the given wait policy.
+//
+// Parameters:
+//   - wait: The wait policy.
+//
+// Returns:
+//   - *Locking: The new locking.
+func (l *Locking) WithWait(wait LockWait) *Locking {
+	locking := *l
+	locking.Wait = wait
+	return &locking
+}
+
+// WithOf returns a copy of the locking that locks only rows of the tables.
+//
+// Parameters:
+//   - tables: The tables or aliases to lock.
+//
+// Returns:
+//   - *Locking: The new locking.
+func (l *Locking) WithOf(tables ...string) *Locking {
+	locking := *l
+	locking.Of = tables
+	return &locking
+}
+
+// LockDialect is implemented by query builders that render row locks with
+// BuildLock.
+type LockDialect interface {
+	// QuoteTable returns the quoted table name or alias.
+	QuoteTable(table string) string
+	// SupportsLock reports whether the dialect supports the lock strength
+	// with the wait policy.
+	SupportsLock(strength LockStrength, wait LockWait) bool
+}
+
+// UnsupportedLockError is returned when a dialect does not support a lock.
+type UnsupportedLockError struct {
+	Strength LockStrength
+	Wait     LockWait
+}
+
+// Error returns the error message.
+func (e *UnsupportedLockError) Error() string {
+	if e.Wait != "" {
+		return fmt.Sprintf("unsupported lock: %s %s", e.Strength, e.Wait)
+	}
+	return fmt.Sprintf("unsupported lock: %s", e.Strength)
+}
+
+// LockClause returns the locking of the get options. The Lock flag is
+// treated as FOR UPDATE if there is no Locking.
+//
+// Returns:
+//   - *Locking: The locking, or nil if rows are not locked.
+func (o *GetOptions) LockClause() *Locking {
+	if o.Locking != nil {
+		return o.Locking
+	}
+	if o.Lock {
+		return NewLocking(LockForUpdate)
+	}
+	return nil
+}
+
+// BuildLock renders the locking clause for the dialect.
+//
+// Parameters:
+//   - dialect: The lock dialect.
+//   - locking: The locking, or nil.
+//
+// Returns:
+//   - string: The SQL of the locking clause, or an empty string if locking is
+//     nil.
+//   - error: An UnsupportedLockError if the dialect does not support the
+//     lock, or an error if the lock is invalid.
+func BuildLock(dialect LockDialect, locking *Locking) (string, error) {
+	if locking == nil {
+		return "", nil
+	}
+	switch locking.Strength {
+	case LockForUpdate, LockForNoKeyUpdate, LockForShare, LockForKeyShare:
+	default:
+		return "", fmt.Errorf(
+			"BuildLock: invalid lock strength: %s", locking.Strength,
+		)
+	}
+	switch locking.Wait {
+	case "", LockNoWait, LockSkipLocked:
+	default:
+		return "", fmt.Errorf(
+			"BuildLock: invalid lock wait policy: %s", locking.Wait,
+		)
+	}
+	if !dialect.SupportsLock(locking.Strength, locking.Wait) {
+		return "", &UnsupportedLockError{
+			Strength: locking.Strength,
+			Wait:     locking.Wait,
+		}
+	}
+
+	sql := string(locking.Strength)
+	if len(locking.Of) > 0 {
+		tables := make([]string, len(locking.Of))
+		for i, table := range locking.Of {
+			tables[i] = dialect.QuoteTable(table)
+		}
+		sql += " OF " + strings.Join(tables, ", ")
+	}
+	if locking.Wait != "" {
+		sql += " " + string(locking.Wait)
+	}
+	return sql, nil
+}
+
+// checkLocking checks that the locking of the get options can be rendered by
+// the query builder. The Lock flag is supported by all query builders.
+func checkLocking(queryBuilder QueryBuilder, options *GetOptions) error {
+	if options.Locking == nil {
+		return nil
+	}
+	dialect, ok := queryBuilder.(LockDialect)
+	if !ok {
+		return fmt.Errorf("query builder does not support lock modes")
+	}
+	_, err := BuildLock(dialect, options.Locking)
+	return err
+}
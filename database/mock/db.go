@@ -168,3 +168,24 @@ func (m *MockResult) RowsAffected() (int64, error) {
 	args := m.Called()
 	return args.Get(0).(int64), args.Error(1)
 }
+
+// ExpectExec sets up the preparation and execution of a statement on the
+// mock of a preparer, e.g. &db.Mock. The statement returns a result with the
+// given rows affected and last insert ID.
+//
+// Parameters:
+//   - preparer: The mock of the preparer.
+//   - query: The expected query.
+//   - rowsAffected: The rows affected of the result.
+//   - lastInsertID: The last insert ID of the result.
+func ExpectExec(
+	preparer *mock.Mock, query string, rowsAffected int64, lastInsertID int64,
+) {
+	stmt := &MockStmt{}
+	result := &MockResult{}
+	preparer.On("Prepare", query).Return(stmt, nil).Once()
+	stmt.On("Exec", mock.Anything).Return(result, nil)
+	stmt.On("Close").Return(nil)
+	result.On("RowsAffected").Return(rowsAffected, nil)
+	result.On("LastInsertId").Return(lastInsertID, nil)
+}
@@ -0,0 +1,140 @@
+package mock
+
+import (
+	"github.com/pakkasys/fluidapi/database"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockQueryBuilder is a mock implementation of the QueryBuilder interface. It
+// also implements the ExprDialect and LockDialect interfaces.
+type MockQueryBuilder struct {
+	mock.Mock
+}
+
+var _ database.QueryBuilder = (*MockQueryBuilder)(nil)
+var _ database.ExprDialect = (*MockQueryBuilder)(nil)
+var _ database.LockDialect = (*MockQueryBuilder)(nil)
+
+func (m *MockQueryBuilder) Insert(
+	table string, insertedValuesFunc database.InsertedValuesFn,
+) (string, []any) {
+	args := m.Called(table, insertedValuesFunc)
+	return args.String(0), args.Get(1).([]any)
+}
+
+func (m *MockQueryBuilder) InsertMany(
+	table string, valuesFuncs []database.InsertedValuesFn,
+) (string, []any) {
+	args := m.Called(table, valuesFuncs)
+	return args.String(0), args.Get(1).([]any)
+}
+
+func (m *MockQueryBuilder) UpsertMany(
+	table string,
+	valuesFuncs []database.InsertedValuesFn,
+	updateProjections []database.Projection,
+) (string, []any) {
+	args := m.Called(table, valuesFuncs, updateProjections)
+	return args.String(0), args.Get(1).([]any)
+}
+
+func (m *MockQueryBuilder) Get(
+	table string, options *database.GetOptions,
+) (string, []any) {
+	args := m.Called(table, options)
+	return args.String(0), args.Get(1).([]any)
+}
+
+func (m *MockQueryBuilder) Count(
+	table string, options *database.CountOptions,
+) (string, []any) {
+	args := m.Called(table, options)
+	return args.String(0), args.Get(1).([]any)
+}
+
+func (m *MockQueryBuilder) UpdateQuery(
+	table string, updates []database.Update, selectors []database.Selector,
+) (string, []any) {
+	args := m.Called(table, updates, selectors)
+	return args.String(0), args.Get(1).([]any)
+}
+
+func (m *MockQueryBuilder) Delete(
+	table string,
+	selectors []database.Selector,
+	opts *database.DeleteOptions,
+) (string, []any) {
+	args := m.Called(table, selectors, opts)
+	return args.String(0), args.Get(1).([]any)
+}
+
+func (m *MockQueryBuilder) CreateDatabaseQuery(
+	dbName string, ifNotExists bool, charset string, collate string,
+) (string, []any, error) {
+	args := m.Called(dbName, ifNotExists, charset, collate)
+	return args.String(0), args.Get(1).([]any), args.Error(2)
+}
+
+func (m *MockQueryBuilder) CreateTableQuery(
+	tableName string,
+	ifNotExists bool,
+	columns []database.ColumnDefinition,
+	constraints []string,
+	options database.TableOptions,
+) (string, []any, error) {
+	args := m.Called(tableName, ifNotExists, columns, constraints, options)
+	return args.String(0), args.Get(1).([]any), args.Error(2)
+}
+
+func (m *MockQueryBuilder) UseDatabaseQuery(
+	dbName string,
+) (string, []any, error) {
+	args := m.Called(dbName)
+	return args.String(0), args.Get(1).([]any), args.Error(2)
+}
+
+func (m *MockQueryBuilder) SetVariableQuery(
+	variable string, value string,
+) (string, []any, error) {
+	args := m.Called(variable, value)
+	return args.String(0), args.Get(1).([]any), args.Error(2)
+}
+
+func (m *MockQueryBuilder) AdvisoryLock(
+	lockName string, timeout int,
+) (string, []any, error) {
+	args := m.Called(lockName, timeout)
+	return args.String(0), args.Get(1).([]any), args.Error(2)
+}
+
+func (m *MockQueryBuilder) AdvisoryUnlock(
+	lockName string,
+) (string, []any, error) {
+	args := m.Called(lockName)
+	return args.String(0), args.Get(1).([]any), args.Error(2)
+}
+
+func (m *MockQueryBuilder) QuoteColumn(table string, column string) string {
+	return m.Called(table, column).String(0)
+}
+
+func (m *MockQueryBuilder) Placeholder(position int) string {
+	return m.Called(position).String(0)
+}
+
+func (m *MockQueryBuilder) Function(
+	fn database.Function, args []string,
+) (string, bool) {
+	called := m.Called(fn, args)
+	return called.String(0), called.Bool(1)
+}
+
+func (m *MockQueryBuilder) QuoteTable(table string) string {
+	return m.Called(table).String(0)
+}
+
+func (m *MockQueryBuilder) SupportsLock(
+	strength database.LockStrength, wait database.LockWait,
+) bool {
+	return m.Called(strength, wait).Bool(0)
+}
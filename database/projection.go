@@ -0,0 +1,82 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ProjectionScanner can be implemented by entities that can scan rows
+// containing only some of their columns. If the get options of a query have
+// projections, ScanProjection is used instead of ScanRow.
+type ProjectionScanner interface {
+	// ScanProjection should populate the entity from a row containing the
+	// projected columns, in the order of the projections.
+	ScanProjection(row Row, projections Projections) error
+}
+
+// Name returns the name of the projected column in the result set, which is
+// the alias if set and otherwise the column name. Aggregates without an alias
+// are named after the aggregate and the column, e.g. "sum_amount" or "count".
+//
+// Returns:
+//   - string: The name of the projected column.
+func (p Projection) Name() string {
+	if p.Alias != "" {
+		return p.Alias
+	}
+	if p.Aggregate == "" {
+		return p.Column
+	}
+	name := strings.ToLower(string(p.Aggregate))
+	if p.Column == "" || p.Column == "*" {
+		return name
+	}
+	return name + "_" + p.Column
+}
+
+// ScanProjections scans a row containing the projected columns into the scan
+// targets of the projections. It is a helper for implementing
+// ProjectionScanner.
+//
+// Example:
+//
+//	func (u *User) ScanProjection(row Row, projections Projections) error {
+//	    return ScanProjections(row, projections, map[string]any{
+//	        "id":   &u.ID,
+//	        "name": &u.Name,
+//	    })
+//	}
+//
+// Parameters:
+//   - row: The row to scan.
+//   - projections: The projections of the query.
+//   - targets: The scan targets by projected column name.
+//
+// Returns:
+//   - error: An error if a projection has no target or the scan fails.
+func ScanProjections(
+	row Row, projections Projections, targets map[string]any,
+) error {
+	dest := make([]any, len(projections))
+	for i, projection := range projections {
+		target, ok := targets[projection.Name()]
+		if !ok {
+			return fmt.Errorf(
+				"ScanProjections: no scan target for column %s",
+				projection.Name(),
+			)
+		}
+		dest[i] = target
+	}
+	return row.Scan(dest...)
+}
+
+// scanEntity scans the row into the entity. If there are projections and the
+// entity implements ProjectionScanner, only the projected columns are scanned.
+func scanEntity[T Getter](entity T, row Row, projections Projections) error {
+	if scanner, ok := any(entity).(ProjectionScanner); ok &&
+		len(projections) > 0 {
+		return scanner.ScanProjection(row, projections)
+	}
+	return entity.ScanRow(row)
+}
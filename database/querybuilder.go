@@ -2,12 +2,62 @@ package database
 
 // GetOptions is used for get queries.
 type GetOptions struct {
+	// With are common table expressions preceding the query.
+	With []CTE
+	// From is an optional table or CTE name to select from instead of the
+	// table of the entity.
+	From        string
 	Selectors   Selectors
 	Orders      Orders
 	Page        *Page
 	Joins       Joins
 	Projections Projections
-	Lock        bool
+	// GroupBy groups the rows by the columns. Projections that are not
+	// aggregates must be grouped by.
+	GroupBy []ColumnSelector
+	// Having filters the groups by aggregate conditions.
+	Having Havings
+	// Lock locks the selected rows FOR UPDATE. Locking takes precedence.
+	Lock bool
+	// Locking is the row locking of the query, rendered with BuildLock.
+	Locking *Locking
+	// Seek is an optional keyset pagination position. Query builders add the
+	// groups from Seek.Selectors to the WHERE clause and order the rows by
+	// SeekOrders.
+	Seek *Seek
+	// Compound combines the query with other queries, e.g. with UNION ALL.
+	// Orders and Page apply to the combined result.
+	Compound *SetQuery
+	// IncludeDeleted includes soft deleted rows of entities implementing
+	// SoftDeleter, which are excluded by default.
+	IncludeDeleted bool
+}
+
+// Table returns the table to select from, which is From if set and
+// otherwise the given entity table.
+//
+// Parameters:
+//   - entityTable: The table of the entity.
+//
+// Returns:
+//   - string: The table to select from.
+func (o *GetOptions) Table(entityTable string) string {
+	if o.From != "" {
+		return o.From
+	}
+	return entityTable
+}
+
+// SeekOrders returns the orders to use in the query. For backward seeks the
+// orders are reversed, so the caller must reverse the returned rows.
+//
+// Returns:
+//   - Orders: The orders to use in the query.
+func (o *GetOptions) SeekOrders() Orders {
+	if o.Seek != nil && o.Seek.Backward {
+		return o.Orders.Reverse()
+	}
+	return o.Orders
 }
 
 // CountOptions is used for count queries.
@@ -15,8 +65,21 @@ type CountOptions struct {
 	Selectors Selectors
 	Page      *Page
 	Joins     Joins
+	// IncludeDeleted includes soft deleted rows of entities implementing
+	// SoftDeleter, which are excluded by default.
+	IncludeDeleted bool
 }
 
+// CountMode selects how the total count of a list is determined.
+type CountMode string
+
+// Count modes.
+const (
+	CountExact     CountMode = "exact"     // SELECT COUNT(*) with selectors.
+	CountEstimated CountMode = "estimated" // Table statistics if available.
+	CountNone      CountMode = "none"      // No count.
+)
+
 // DeleteOptions is used for delete queries.
 type DeleteOptions struct {
 	Limit  int
@@ -59,10 +122,14 @@ type QueryBuilder interface {
 	// UpsertMany builds an UPSERT (insert or update) statement for multiple rows.
 	UpsertMany(table string, valuesFuncs []InsertedValuesFn, updateProjections []Projection) (query string, params []any)
 	// Get builds a SELECT statement with optional filtering, ordering, and limits.
+	// Aggregates, groups and havings are rendered with BuildProjection and
+	// BuildHavings, joins with BuildJoin, and CTEs and compound queries with
+	// BuildWith and BuildCompound.
 	Get(table string, options *GetOptions) (query string, params []any)
 	// Count builds a SELECT COUNT(*) statement with optional filters.
 	Count(table string, options *CountOptions) (query string, params []any)
 	// UpdateQuery builds an UPDATE statement for given selectors and update fields.
+	// Update values implementing Expr are rendered with BuildValue.
 	UpdateQuery(table string, updates []Update, selectors []Selector) (query string, params []any)
 	// Delete builds a DELETE statement for given selectors.
 	Delete(table string, selectors []Selector, opts *DeleteOptions) (query string, params []any)
@@ -79,3 +146,10 @@ type QueryBuilder interface {
 	// AdvisoryUnlock builds an advisory unlock statement.
 	AdvisoryUnlock(lockName string) (string, []any, error)
 }
+
+// EstimatedCounter can be implemented by query builders whose database keeps
+// table statistics, to provide a fast estimate of the table row count.
+type EstimatedCounter interface {
+	// EstimatedCount builds a statement returning the estimated row count.
+	EstimatedCount(table string) (query string, params []any)
+}
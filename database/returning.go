@@ -0,0 +1,453 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ReturningDialect is implemented by query builders of databases that support
+// RETURNING clauses, e.g. Postgres and SQLite. The statements return the
+// projected columns of the inserted, updated or deleted rows, or all columns
+// if there are no projections. The clause can be rendered with
+// BuildReturning.
+type ReturningDialect interface {
+	// InsertReturning builds an INSERT statement for a single row with a
+	// RETURNING clause.
+	InsertReturning(table string, insertedValuesFunc InsertedValuesFn, projections Projections) (query string, params []any)
+	// UpdateReturning builds an UPDATE statement with a RETURNING clause.
+	UpdateReturning(table string, updates []Update, selectors []Selector, projections Projections) (query string, params []any)
+	// DeleteReturning builds a DELETE statement with a RETURNING clause.
+	DeleteReturning(table string, selectors []Selector, projections Projections) (query string, params []any)
+}
+
+// Returning configures the rows returned by ReturningDBOps.
+type Returning struct {
+	// Projections are the returned columns. If empty, all columns are
+	// returned and scanned with ScanRow.
+	Projections Projections
+	// KeyColumn is the primary key column of the table. It is required to
+	// emulate RETURNING with query builders that do not implement
+	// ReturningDialect.
+	KeyColumn string
+}
+
+// BuildReturning renders a RETURNING clause for the dialect.
+//
+// Parameters:
+//   - dialect: The expression dialect.
+//   - projections: The returned columns, or none for all columns.
+//
+// Returns:
+//   - string: The SQL of the clause.
+//   - error: An error if a projection cannot be rendered.
+func BuildReturning(
+	dialect ExprDialect, projections Projections,
+) (string, error) {
+	if len(projections) == 0 {
+		return "RETURNING *", nil
+	}
+	columns := make([]string, len(projections))
+	for i, projection := range projections {
+		var err error
+		if columns[i], err = BuildProjection(dialect, projection); err != nil {
+			return "", fmt.Errorf("BuildReturning: %w", err)
+		}
+	}
+	return "RETURNING " + strings.Join(columns, ", "), nil
+}
+
+// ReturningDBOps provides write operations that return the persisted
+// entities. If the query builder implements ReturningDialect, the statements
+// use RETURNING. Otherwise RETURNING is emulated by selecting the rows by
+// their key column within the transaction, e.g. for MySQL, so the preparer
+// must then be a transaction.
+type ReturningDBOps[Entity CRUDEntity] struct{}
+
+// NewReturningDBOps creates a new ReturningDBOps instance.
+func NewReturningDBOps[Entity CRUDEntity]() *ReturningDBOps[Entity] {
+	return &ReturningDBOps[Entity]{}
+}
+
+// Insert inserts the entity and returns the persisted entity, including
+// database generated values such as IDs and defaults. When emulated, the row
+// is selected by the key value of the entity if it has one, e.g. a UUID, and
+// otherwise by the last insert ID.
+//
+// Parameters:
+//   - preparer: The database connection or transaction to use.
+//   - entity: The entity to insert.
+//   - returning: The returned columns and the key column.
+//   - factoryFn: A function that returns a new instance of the entity.
+//   - queryBuilder: The SQL query builder for constructing the queries.
+//   - errorChecker: Optional error checker to translate SQL driver errors into
+//     custom errors or skip them.
+//
+// Returns:
+//   - Entity: The persisted entity.
+//   - error: An error if the insert or the selection fails.
+func (d *ReturningDBOps[Entity]) Insert(
+	preparer Preparer,
+	entity Entity,
+	returning *Returning,
+	factoryFn func() Entity,
+	queryBuilder QueryBuilder,
+	errorChecker ErrorChecker,
+) (Entity, error) {
+	var zero Entity
+	if err := checkReturningArgs(preparer, returning, queryBuilder); err != nil {
+		return zero, fmt.Errorf("Insert: %w", err)
+	}
+
+	if dialect, ok := queryBuilder.(ReturningDialect); ok {
+		query, params := dialect.InsertReturning(
+			entity.TableName(), entity.InsertedValues, returning.Projections,
+		)
+		inserted, err := querySingle(
+			preparer, query, params, factoryFn, returning.Projections,
+		)
+		if err != nil {
+			return zero, checkError(err, errorChecker)
+		}
+		return inserted, nil
+	}
+
+	if err := checkEmulation(preparer, returning); err != nil {
+		return zero, fmt.Errorf("Insert: %w", err)
+	}
+	query, params := queryBuilder.Insert(
+		entity.TableName(), entity.InsertedValues,
+	)
+	result, err := doExec(preparer, query, params)
+	if err != nil {
+		return zero, checkError(err, errorChecker)
+	}
+	key, ok := insertedKey(entity, returning.KeyColumn)
+	if !ok {
+		if key, err = result.LastInsertId(); err != nil {
+			return zero, checkError(err, errorChecker)
+		}
+	}
+	inserted, err := d.selectReturning(
+		preparer,
+		entity.TableName(),
+		[]Selector{keySelector(entity, returning, Equal, key)},
+		returning,
+		factoryFn,
+		queryBuilder,
+		false,
+	)
+	if err != nil {
+		return zero, checkError(err, errorChecker)
+	}
+	if len(inserted) != 1 {
+		return zero, fmt.Errorf("Insert: inserted row not found")
+	}
+	return inserted[0], nil
+}
+
+// Update applies the updates to the rows matching the selectors and returns
+// the updated entities. When emulated, the keys of the matching rows are
+// selected and locked first, so that the rows can be selected after the
+// update even if it changes the selected columns. Soft deleted rows of
+// entities implementing SoftDeleter are not updated.
+//
+// Parameters:
+//   - preparer: The database connection or transaction to use.
+//   - tableNamer: An entity or struct that provides the target table name.
+//   - selectors: Conditions to match target records.
+//   - updates: The field updates.
+//   - returning: The returned columns and the key column.
+//   - factoryFn: A function that returns a new instance of the entity.
+//   - queryBuilder: The SQL query builder for constructing the queries.
+//   - errorChecker: Optional error checker to translate SQL driver errors into
+//     custom errors or skip them.
+//
+// Returns:
+//   - []Entity: The updated entities.
+//   - error: An error if the update or the selection fails.
+func (d *ReturningDBOps[Entity]) Update(
+	preparer Preparer,
+	tableNamer TableNamer,
+	selectors []Selector,
+	updates []Update,
+	returning *Returning,
+	factoryFn func() Entity,
+	queryBuilder QueryBuilder,
+	errorChecker ErrorChecker,
+) ([]Entity, error) {
+	if err := checkReturningArgs(preparer, returning, queryBuilder); err != nil {
+		return nil, fmt.Errorf("Update: %w", err)
+	}
+	if tableNamer == nil {
+		return nil, fmt.Errorf("Update: tableNamer is nil")
+	}
+	if len(updates) == 0 {
+		return []Entity{}, nil
+	}
+	if err := checkUpdateExprs(queryBuilder, updates); err != nil {
+		return nil, fmt.Errorf("Update: %w", err)
+	}
+	selectors = excludeDeleted(tableNamer, tableNamer.TableName(), selectors)
+
+	if dialect, ok := queryBuilder.(ReturningDialect); ok {
+		query, params := dialect.UpdateReturning(
+			tableNamer.TableName(), updates, selectors, returning.Projections,
+		)
+		updated, err := queryMultiple(
+			preparer, query, params, factoryFn, returning.Projections,
+		)
+		if err != nil {
+			return nil, checkError(err, errorChecker)
+		}
+		return updated, nil
+	}
+
+	if err := checkEmulation(preparer, returning); err != nil {
+		return nil, fmt.Errorf("Update: %w", err)
+	}
+	keys, err := selectKeys(
+		preparer, tableNamer.TableName(), selectors, returning, queryBuilder,
+	)
+	if err != nil {
+		return nil, checkError(err, errorChecker)
+	}
+	if len(keys) == 0 {
+		return []Entity{}, nil
+	}
+	query, params := queryBuilder.UpdateQuery(
+		tableNamer.TableName(), updates, selectors,
+	)
+	if _, err := doExec(preparer, query, params); err != nil {
+		return nil, checkError(err, errorChecker)
+	}
+	updated, err := d.selectReturning(
+		preparer,
+		tableNamer.TableName(),
+		[]Selector{keySelector(tableNamer, returning, In, keys)},
+		returning,
+		factoryFn,
+		queryBuilder,
+		false,
+	)
+	if err != nil {
+		return nil, checkError(err, errorChecker)
+	}
+	return updated, nil
+}
+
+// Delete deletes the rows matching the selectors and returns the deleted
+// entities. When emulated, the keys of the rows are selected and locked, and
+// the rows with those keys are selected and deleted. Rows of entities
+// implementing SoftDeleter are soft deleted with Update instead, using the
+// database clock if the query builder implements ExprDialect.
+//
+// Parameters:
+//   - preparer: The database connection or transaction to use.
+//   - tableNamer: An entity or struct that provides the target table name.
+//   - selectors: Conditions to match target records.
+//   - returning: The returned columns and the key column.
+//   - factoryFn: A function that returns a new instance of the entity.
+//   - queryBuilder: The SQL query builder for constructing the queries.
+//   - errorChecker: Optional error checker to translate SQL driver errors into
+//     custom errors or skip them.
+//
+// Returns:
+//   - []Entity: The deleted entities.
+//   - error: An error if the delete or the selection fails.
+func (d *ReturningDBOps[Entity]) Delete(
+	preparer Preparer,
+	tableNamer TableNamer,
+	selectors []Selector,
+	returning *Returning,
+	factoryFn func() Entity,
+	queryBuilder QueryBuilder,
+	errorChecker ErrorChecker,
+) ([]Entity, error) {
+	if err := checkReturningArgs(preparer, returning, queryBuilder); err != nil {
+		return nil, fmt.Errorf("Delete: %w", err)
+	}
+	if tableNamer == nil {
+		return nil, fmt.Errorf("Delete: tableNamer is nil")
+	}
+	if softDeleter, ok := tableNamer.(SoftDeleter); ok {
+		return d.Update(
+			preparer,
+			tableNamer,
+			selectors,
+			[]Update{NewUpdate(
+				softDeleter.SoftDeleteColumn(), deletedAt(queryBuilder),
+			)},
+			returning,
+			factoryFn,
+			queryBuilder,
+			errorChecker,
+		)
+	}
+
+	if dialect, ok := queryBuilder.(ReturningDialect); ok {
+		query, params := dialect.DeleteReturning(
+			tableNamer.TableName(), selectors, returning.Projections,
+		)
+		deleted, err := queryMultiple(
+			preparer, query, params, factoryFn, returning.Projections,
+		)
+		if err != nil {
+			return nil, checkError(err, errorChecker)
+		}
+		return deleted, nil
+	}
+
+	if err := checkEmulation(preparer, returning); err != nil {
+		return nil, fmt.Errorf("Delete: %w", err)
+	}
+	keys, err := selectKeys(
+		preparer, tableNamer.TableName(), selectors, returning, queryBuilder,
+	)
+	if err != nil {
+		return nil, checkError(err, errorChecker)
+	}
+	if len(keys) == 0 {
+		return []Entity{}, nil
+	}
+	keySelectors := []Selector{keySelector(tableNamer, returning, In, keys)}
+	deleted, err := d.selectReturning(
+		preparer,
+		tableNamer.TableName(),
+		keySelectors,
+		returning,
+		factoryFn,
+		queryBuilder,
+		false,
+	)
+	if err != nil {
+		return nil, checkError(err, errorChecker)
+	}
+	query, params := queryBuilder.Delete(
+		tableNamer.TableName(), keySelectors, &DeleteOptions{},
+	)
+	if _, err := doExec(preparer, query, params); err != nil {
+		return nil, checkError(err, errorChecker)
+	}
+	return deleted, nil
+}
+
+// selectReturning selects the returned entities of an emulated statement
+// from the table of the statement.
+func (d *ReturningDBOps[Entity]) selectReturning(
+	preparer Preparer,
+	table string,
+	selectors []Selector,
+	returning *Returning,
+	factoryFn func() Entity,
+	queryBuilder QueryBuilder,
+	lock bool,
+) ([]Entity, error) {
+	query, params := queryBuilder.Get(table, &GetOptions{
+		Selectors:   selectors,
+		Projections: returning.Projections,
+		Lock:        lock,
+	})
+	return queryMultiple(
+		preparer, query, params, factoryFn, returning.Projections,
+	)
+}
+
+// keyRow scans the key column of a row.
+type keyRow struct {
+	table string
+	key   any
+}
+
+func (r *keyRow) TableName() string {
+	return r.table
+}
+
+func (r *keyRow) ScanRow(row Row) error {
+	return row.Scan(&r.key)
+}
+
+// selectKeys selects and locks the keys of the rows matching the selectors.
+func selectKeys(
+	preparer Preparer,
+	table string,
+	selectors []Selector,
+	returning *Returning,
+	queryBuilder QueryBuilder,
+) ([]any, error) {
+	query, params := queryBuilder.Get(table, &GetOptions{
+		Selectors: selectors,
+		Projections: Projections{
+			{Table: table, Column: returning.KeyColumn},
+		},
+		Lock: true,
+	})
+	rows, err := queryMultiple(
+		preparer,
+		query,
+		params,
+		func() *keyRow { return &keyRow{table: table} },
+		nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]any, len(rows))
+	for i, row := range rows {
+		keys[i] = row.key
+	}
+	return keys, nil
+}
+
+// insertedKey returns the value of the key column of the entity, if it is
+// inserted.
+func insertedKey(entity Mutator, keyColumn string) (any, bool) {
+	columns, values := entity.InsertedValues()
+	for i, column := range columns {
+		if column == keyColumn && i < len(values) {
+			return values[i], true
+		}
+	}
+	return nil, false
+}
+
+// keySelector returns a selector on the key column.
+func keySelector(
+	tableNamer TableNamer,
+	returning *Returning,
+	predicate Predicate,
+	value any,
+) Selector {
+	return Selector{
+		Table:     tableNamer.TableName(),
+		Column:    returning.KeyColumn,
+		Predicate: predicate,
+		Value:     value,
+	}
+}
+
+// checkReturningArgs checks the common arguments of ReturningDBOps.
+func checkReturningArgs(
+	preparer Preparer, returning *Returning, queryBuilder QueryBuilder,
+) error {
+	if preparer == nil {
+		return fmt.Errorf("preparer is nil")
+	}
+	if returning == nil {
+		return fmt.Errorf("returning is nil")
+	}
+	if queryBuilder == nil {
+		return fmt.Errorf("queryBuilder is nil")
+	}
+	return nil
+}
+
+// checkEmulation checks that RETURNING can be emulated.
+func checkEmulation(preparer Preparer, returning *Returning) error {
+	if returning.KeyColumn == "" {
+		return fmt.Errorf("emulated RETURNING requires a key column")
+	}
+	if _, ok := preparer.(Tx); !ok {
+		return fmt.Errorf("emulated RETURNING requires a transaction")
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package database
+
+import (
+	"fmt"
+)
+
+// Seek is used for keyset pagination. It selects the rows that come after the
+// given values in the order of the query, or before them if Backward is set.
+// The values are the order column values of the last row of the previous
+// page, in the order of the orders.
+type Seek struct {
+	Values   []any
+	Backward bool
+}
+
+// NewSeek creates a new seek with the given values.
+//
+// Parameters:
+//   - values: The order column values to seek from.
+//   - backward: Whether to seek backward.
+//
+// Returns:
+//   - *Seek: The new seek.
+func NewSeek(values []any, backward bool) *Seek {
+	return &Seek{
+		Values:   values,
+		Backward: backward,
+	}
+}
+
+// Selectors returns the seek condition for the given orders as groups of
+// selectors. The selectors within a group must be combined with AND and the
+// groups with OR. For orders (a ASC, b DESC) and values (1, 2) the groups are:
+//
+//	(a > 1) OR (a = 1 AND b < 2)
+//
+// The order columns must not contain NULL values, so orders with a nulls
+// order and nil values are rejected.
+//
+// Parameters:
+//   - orders: The orders of the query.
+//
+// Returns:
+//   - []Selectors: The groups of selectors.
+//   - error: An error if the seek does not match the orders.
+func (s *Seek) Selectors(orders Orders) ([]Selectors, error) {
+	if len(orders) == 0 {
+		return nil, fmt.Errorf("Selectors: seek requires orders")
+	}
+	if len(s.Values) != len(orders) {
+		return nil, fmt.Errorf(
+			"Selectors: seek has %d values for %d orders",
+			len(s.Values),
+			len(orders),
+		)
+	}
+
+	groups := make([]Selectors, 0, len(orders))
+	for i, order := range orders {
+		if order.Nulls != "" {
+			return nil, fmt.Errorf(
+				"Selectors: seek does not support nulls order on %s",
+				order.Field,
+			)
+		}
+		if s.Values[i] == nil {
+			return nil, fmt.Errorf(
+				"Selectors: seek value for %s is nil", order.Field,
+			)
+		}
+		group := make(Selectors, 0, i+1)
+		for j := 0; j < i; j++ {
+			group = append(group, Selector{
+				Table:     orders[j].Table,
+				Column:    orders[j].Field,
+				Predicate: Equal,
+				Value:     s.Values[j],
+			})
+		}
+		predicate := Greater
+		if (order.Direction == OrderDesc) != s.Backward {
+			predicate = Less
+		}
+		group = append(group, Selector{
+			Table:     order.Table,
+			Column:    order.Field,
+			Predicate: predicate,
+			Value:     s.Values[i],
+		})
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+// Reverse returns the orders with their directions reversed. It is used for
+// backward seeks, whose rows are read in reverse order.
+//
+// Returns:
+//   - Orders: The reversed orders.
+func (o Orders) Reverse() Orders {
+	reversed := make(Orders, len(o))
+	for i, order := range o {
+		reversed[i] = order
+		if order.Direction == OrderDesc {
+			reversed[i].Direction = OrderAsc
+		} else {
+			reversed[i].Direction = OrderDesc
+		}
+	}
+	return reversed
+}
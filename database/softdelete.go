@@ -0,0 +1,198 @@
+package database
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// SoftDeleter is implemented by entities whose rows are soft deleted by
+// setting a marker column, e.g. "deleted_at", instead of being removed. Reads
+// of ReadDBOps exclude rows whose marker is set unless the options include
+// deleted rows, updates only change rows that are not deleted, and Delete sets
+// the marker to the current time, taken from the database clock if the query
+// builder implements ExprDialect. HardDelete removes rows and Restore clears
+// the marker.
+type SoftDeleter interface {
+	// SoftDeleteColumn returns the nullable column marking deleted rows.
+	SoftDeleteColumn() string
+}
+
+// HardDelete removes the records matching the given selectors, including
+// soft deleted records. For entities that are not soft deleted it is the same
+// as Delete.
+//
+// Parameters:
+//   - preparer: The database connection or transaction to use.
+//   - entity: An entity that provides the target table name.
+//   - selectors: Conditions to match target records.
+//   - opts: Options for the delete operation.
+//   - queryBuilder: The SQL query builder for constructing the query.
+//   - errorChecker: Optional error checker to translate SQL driver errors into
+//     custom errors or skip them.
+//
+// Returns:
+//   - int64: The number of deleted records.
+//   - error: An error if the delete fails.
+func (*MutateDBOps[Entity]) HardDelete(
+	preparer Preparer,
+	entity Mutator,
+	selectors []Selector,
+	opts *DeleteOptions,
+	queryBuilder QueryBuilder,
+	errorChecker ErrorChecker,
+) (int64, error) {
+	if preparer == nil {
+		return 0, fmt.Errorf("HardDelete: preparer is nil")
+	}
+	if entity == nil {
+		return 0, fmt.Errorf("HardDelete: entity is nil")
+	}
+	if opts == nil {
+		return 0, fmt.Errorf("HardDelete: opts is nil")
+	}
+	if queryBuilder == nil {
+		return 0, fmt.Errorf("HardDelete: queryBuilder is nil")
+	}
+
+	query, params := queryBuilder.Delete(
+		entity.TableName(), selectors, opts,
+	)
+	result, err := doExec(preparer, query, params)
+	return checkUpdateResult(result, err, errorChecker)
+}
+
+// Restore clears the soft delete marker of the deleted records matching the
+// given selectors.
+//
+// Parameters:
+//   - preparer: The database connection or transaction to use.
+//   - entity: A soft deleted entity that provides the target table name.
+//   - selectors: Conditions to match target records.
+//   - queryBuilder: The SQL query builder for constructing the query.
+//   - errorChecker: Optional error checker to translate SQL driver errors into
+//     custom errors or skip them.
+//
+// Returns:
+//   - int64: The number of restored records.
+//   - error: An error if the entity is not soft deleted or the update fails.
+func (*MutateDBOps[Entity]) Restore(
+	preparer Preparer,
+	entity Mutator,
+	selectors []Selector,
+	queryBuilder QueryBuilder,
+	errorChecker ErrorChecker,
+) (int64, error) {
+	if preparer == nil {
+		return 0, fmt.Errorf("Restore: preparer is nil")
+	}
+	if entity == nil {
+		return 0, fmt.Errorf("Restore: entity is nil")
+	}
+	if queryBuilder == nil {
+		return 0, fmt.Errorf("Restore: queryBuilder is nil")
+	}
+	softDeleter, ok := entity.(SoftDeleter)
+	if !ok {
+		return 0, fmt.Errorf("Restore: entity is not soft deleted")
+	}
+
+	column := softDeleter.SoftDeleteColumn()
+	query, params := queryBuilder.UpdateQuery(
+		entity.TableName(),
+		[]Update{NewUpdate(column, nil)},
+		append(slices.Clip(selectors), Selector{
+			Table:     entity.TableName(),
+			Column:    column,
+			Predicate: NotEqual,
+			Value:     nil,
+		}),
+	)
+	result, err := doExec(preparer, query, params)
+	return checkUpdateResult(result, err, errorChecker)
+}
+
+// softDelete sets the soft delete marker of the records matching the
+// selectors that are not deleted yet.
+func softDelete(
+	preparer Preparer,
+	entity Mutator,
+	softDeleter SoftDeleter,
+	selectors []Selector,
+	queryBuilder QueryBuilder,
+	errorChecker ErrorChecker,
+) (int64, error) {
+	query, params := queryBuilder.UpdateQuery(
+		entity.TableName(),
+		[]Update{NewUpdate(
+			softDeleter.SoftDeleteColumn(), deletedAt(queryBuilder),
+		)},
+		excludeDeleted(entity, entity.TableName(), selectors),
+	)
+	result, err := doExec(preparer, query, params)
+	return checkUpdateResult(result, err, errorChecker)
+}
+
+// deletedAt returns the soft delete marker of deleted rows. The marker is the
+// database clock if the query builder implements ExprDialect, and otherwise
+// the current time.
+func deletedAt(queryBuilder QueryBuilder) any {
+	if _, ok := queryBuilder.(ExprDialect); ok {
+		return Func(FuncNow)
+	}
+	return time.Now()
+}
+
+// excludeDeleted returns the selectors with a selector excluding soft deleted
+// rows of the table if the entity is soft deleted.
+func excludeDeleted(
+	entity any, table string, selectors []Selector,
+) []Selector {
+	softDeleter, ok := entity.(SoftDeleter)
+	if !ok {
+		return selectors
+	}
+	return append(slices.Clip(selectors), Selector{
+		Table:     table,
+		Column:    softDeleter.SoftDeleteColumn(),
+		Predicate: Equal,
+		Value:     nil,
+	})
+}
+
+// excludesDeleted reports whether soft deleted rows of the entity are
+// excluded from reads.
+func excludesDeleted(entity any, includeDeleted bool) bool {
+	_, ok := entity.(SoftDeleter)
+	return ok && !includeDeleted
+}
+
+// withoutDeleted returns a copy of the get options excluding soft deleted
+// rows, or the options as they are if deleted rows are included, the entity
+// is not soft deleted or the rows are selected from another source than the
+// table of the entity, e.g. a CTE, which must exclude deleted rows itself.
+func (o *GetOptions) withoutDeleted(entity TableNamer) *GetOptions {
+	if !excludesDeleted(entity, o.IncludeDeleted) ||
+		o.Table(entity.TableName()) != entity.TableName() {
+		return o
+	}
+	options := *o
+	options.Selectors = excludeDeleted(
+		entity, entity.TableName(), o.Selectors,
+	)
+	return &options
+}
+
+// withoutDeleted returns a copy of the count options excluding soft deleted
+// rows, or the options as they are if deleted rows are included or the
+// entity is not soft deleted.
+func (o *CountOptions) withoutDeleted(entity TableNamer) *CountOptions {
+	if !excludesDeleted(entity, o.IncludeDeleted) {
+		return o
+	}
+	options := *o
+	options.Selectors = excludeDeleted(
+		entity, entity.TableName(), o.Selectors,
+	)
+	return &options
+}
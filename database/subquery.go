@@ -0,0 +1,181 @@
+package database
+
+import (
+	"fmt"
+)
+
+// SubqueryDialect is implemented by query builders that support subqueries.
+type SubqueryDialect interface {
+	ExprDialect
+	// BuildSelect renders a SELECT statement of the table with the options.
+	// The parameters of the statement are appended to the given parameters,
+	// so that placeholders are numbered in statement order. Selectors must
+	// be rendered with BuildSelectors to support correlated columns.
+	BuildSelect(
+		table string, options *GetOptions, params []any,
+	) (string, []any, error)
+}
+
+// QueryFeature is a feature of a get query that requires a SubqueryDialect.
+type QueryFeature string
+
+// Query features.
+const (
+	FeatureSubquery QueryFeature = "subquery" // Subqueries in selectors.
+	FeatureWith     QueryFeature = "WITH"     // Common table expressions.
+	FeatureCompound QueryFeature = "UNION"    // Compound queries.
+)
+
+// UnsupportedQueryError is returned when a query builder does not support a
+// feature of a get query.
+type UnsupportedQueryError struct {
+	Feature QueryFeature
+}
+
+// Error returns the error message.
+func (e *UnsupportedQueryError) Error() string {
+	return fmt.Sprintf("unsupported query feature: %s", e.Feature)
+}
+
+// Subquery is a SELECT statement used as a selector value. It can be the
+// value of IN and NOT IN selectors, in which case it must have exactly one
+// projection, and of EXISTS and NOT EXISTS selectors.
+type Subquery struct {
+	Table   string
+	Options *GetOptions
+}
+
+// NewSubquery creates a new subquery.
+//
+// Parameters:
+//   - table: The table to select from.
+//   - options: The options of the subquery.
+//
+// Returns:
+//   - *Subquery: The new subquery.
+func NewSubquery(table string, options *GetOptions) *Subquery {
+	return &Subquery{
+		Table:   table,
+		Options: options,
+	}
+}
+
+// NewExistsSelector creates an EXISTS selector. Selectors of the subquery can
+// reference columns of the outer query with ColumnExpr values.
+//
+// Example:
+//
+//	// Users with at least one active order.
+//	NewExistsSelector(NewSubquery("order", &GetOptions{
+//	    Selectors: Selectors{
+//	        {Table: "order", Column: "user_id", Predicate: Equal,
+//	            Value: ColumnExpr{Table: "user", Column: "id"}},
+//	        {Table: "order", Column: "status", Predicate: Equal,
+//	            Value: "active"},
+//	    },
+//	}))
+//
+// Parameters:
+//   - subquery: The subquery.
+//
+// Returns:
+//   - Selector: The EXISTS selector.
+func NewExistsSelector(subquery *Subquery) Selector {
+	return Selector{Predicate: Exists, Value: subquery}
+}
+
+// NewNotExistsSelector creates a NOT EXISTS selector.
+//
+// Parameters:
+//   - subquery: The subquery.
+//
+// Returns:
+//   - Selector: The NOT EXISTS selector.
+func NewNotExistsSelector(subquery *Subquery) Selector {
+	return Selector{Predicate: NotExists, Value: subquery}
+}
+
+// BuildExpr renders the subquery in parentheses.
+func (s *Subquery) BuildExpr(
+	dialect ExprDialect, params []any,
+) (string, []any, error) {
+	sql, params, err := s.BuildSelect(dialect, params)
+	if err != nil {
+		return "", nil, err
+	}
+	return "(" + sql + ")", params, nil
+}
+
+// BuildSelect renders the SELECT statement of the subquery.
+func (s *Subquery) BuildSelect(
+	dialect ExprDialect, params []any,
+) (string, []any, error) {
+	subqueryDialect, ok := dialect.(SubqueryDialect)
+	if !ok {
+		return "", nil, fmt.Errorf(
+			"BuildSelect: query builder does not support subqueries",
+		)
+	}
+	options := s.Options
+	if options == nil {
+		options = &GetOptions{}
+	}
+	return subqueryDialect.BuildSelect(s.Table, options, params)
+}
+
+// checkGetOptions checks that the locking, joins, CTEs and subqueries of the
+// get options can be rendered by the query builder.
+func checkGetOptions(queryBuilder QueryBuilder, options *GetOptions) error {
+	if err := checkLocking(queryBuilder, options); err != nil {
+		return err
+	}
+	if err := checkJoins(queryBuilder, options.Joins); err != nil {
+		return err
+	}
+	if err := checkWith(queryBuilder, options); err != nil {
+		return err
+	}
+	return checkSubqueries(queryBuilder, options.Selectors)
+}
+
+// checkSubqueries checks that the subqueries of the selectors can be rendered
+// by the query builder.
+func checkSubqueries(queryBuilder QueryBuilder, selectors Selectors) error {
+	for _, selector := range selectors {
+		query, ok := selector.Value.(SelectQuery)
+		if !ok {
+			continue
+		}
+		err := checkSelectQuery(queryBuilder, query, FeatureSubquery)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkSelectQuery checks that the query can be rendered by the query
+// builder, including the options of its subqueries. An UnsupportedQueryError
+// with the feature is returned if the query builder does not support
+// subqueries.
+func checkSelectQuery(
+	queryBuilder QueryBuilder, query SelectQuery, feature QueryFeature,
+) error {
+	if _, ok := queryBuilder.(SubqueryDialect); !ok {
+		return &UnsupportedQueryError{Feature: feature}
+	}
+	switch query := query.(type) {
+	case *Subquery:
+		if query != nil && query.Options != nil {
+			return checkGetOptions(queryBuilder, query.Options)
+		}
+	case *SetQuery:
+		for _, subquery := range query.Queries {
+			err := checkSelectQuery(queryBuilder, subquery, feature)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
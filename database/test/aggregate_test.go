@@ -0,0 +1,181 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/pakkasys/fluidapi/database"
+	databasemock "github.com/pakkasys/fluidapi/database/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// TestCheckGrouping tests that non-aggregated projections must be grouped.
+func TestCheckGrouping(t *testing.T) {
+	options := &database.GetOptions{
+		Projections: database.Projections{
+			{Table: "order", Column: "status"},
+			database.NewAggregate(database.AggregateSum, "order", "total", ""),
+		},
+	}
+	assert.Error(t, options.CheckGrouping())
+
+	options.GroupBy = []database.ColumnSelector{
+		{Table: "order", Column: "status"},
+	}
+	assert.NoError(t, options.CheckGrouping())
+
+	options.Having = database.Havings{
+		{Aggregate: database.AggregateAvg, Column: "*"},
+	}
+	assert.Error(t, options.CheckGrouping())
+}
+
+// TestBuildProjection tests rendering of aggregate projections.
+func TestBuildProjection(t *testing.T) {
+	projection := database.Projection{
+		Table:     "order",
+		Column:    "user_id",
+		Aggregate: database.AggregateCount,
+		Distinct:  true,
+	}
+
+	sql, err := database.BuildProjection(testDialect{}, projection)
+
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		`COUNT(DISTINCT "order"."user_id") AS "count_user_id"`,
+		sql,
+	)
+}
+
+// TestBuildHavings tests that having conditions are parameterized.
+func TestBuildHavings(t *testing.T) {
+	havings := database.Havings{
+		{
+			Aggregate: database.AggregateCount,
+			Column:    "*",
+			Predicate: database.Greater,
+			Value:     1,
+		},
+		{
+			Aggregate: database.AggregateMax,
+			Table:     "order",
+			Column:    "total",
+			Predicate: database.LessOrEqual,
+			Value:     100,
+		},
+	}
+
+	sql, params, err := database.BuildHavings(testDialect{}, havings, []any{"x"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, `COUNT(*) > $2 AND MAX("order"."total") <= $3`, sql)
+	assert.Equal(t, []any{"x", 1, 100}, params)
+}
+
+// TestBuildHavings_InvalidPredicate tests that having predicates must be
+// comparisons.
+func TestBuildHavings_InvalidPredicate(t *testing.T) {
+	havings := database.Havings{
+		{
+			Aggregate: database.AggregateCount,
+			Column:    "*",
+			Predicate: "> 0 OR 1 =",
+			Value:     1,
+		},
+	}
+
+	_, _, err := database.BuildHavings(testDialect{}, havings, nil)
+
+	assert.EqualError(t, err, "BuildHavings: invalid predicate: > 0 OR 1 =")
+	assert.Error(t, (&database.GetOptions{Having: havings}).CheckGrouping())
+}
+
+// titleCount is an aggregate result of the tests.
+type titleCount struct {
+	Title string
+	Count int
+}
+
+func (c *titleCount) ScanProjection(
+	row database.Row, _ database.Projections,
+) error {
+	return row.Scan(&c.Title, &c.Count)
+}
+
+// TestGetAggregates_ExcludesSoftDeleted tests that aggregates exclude soft
+// deleted rows.
+func TestGetAggregates_ExcludesSoftDeleted(t *testing.T) {
+	queryBuilder := &databasemock.MockQueryBuilder{}
+	db := &databasemock.MockDB{}
+	options := &database.GetOptions{
+		Projections: database.Projections{
+			{Table: "document", Column: "title"},
+			database.NewAggregate(
+				database.AggregateCount, "document", "*", "",
+			),
+		},
+		GroupBy: []database.ColumnSelector{
+			{Table: "document", Column: "title"},
+		},
+	}
+	queryBuilder.On("Get", "document", mock.MatchedBy(
+		func(o *database.GetOptions) bool {
+			return assert.ObjectsAreEqual(
+				database.Selectors{notDeleted}, o.Selectors,
+			)
+		},
+	)).Return("SELECT", []any{})
+	expectUserRows(&db.Mock, "SELECT")
+
+	results, err := database.GetAggregates(
+		db,
+		&testDocument{},
+		options,
+		func() *titleCount { return &titleCount{} },
+		queryBuilder,
+		nil,
+	)
+
+	assert.NoError(t, err)
+	assert.Empty(t, results)
+	assert.Empty(t, options.Selectors)
+	queryBuilder.AssertExpectations(t)
+}
+
+// TestGetAggregates_From tests that aggregates are selected from the CTE
+// named in From.
+func TestGetAggregates_From(t *testing.T) {
+	queryBuilder := subqueryQueryBuilder{&databasemock.MockQueryBuilder{}}
+	db := &databasemock.MockDB{}
+	options := &database.GetOptions{
+		With: []database.CTE{{
+			Name:  "recent",
+			Query: database.NewSubquery("document", nil),
+		}},
+		From: "recent",
+		Projections: database.Projections{
+			{Table: "recent", Column: "title"},
+			database.NewAggregate(database.AggregateCount, "recent", "*", ""),
+		},
+		GroupBy: []database.ColumnSelector{
+			{Table: "recent", Column: "title"},
+		},
+	}
+	queryBuilder.On("Get", "recent", options).Return("SELECT", []any{})
+	expectUserRows(&db.Mock, "SELECT")
+
+	results, err := database.GetAggregates(
+		db,
+		&testDocument{},
+		options,
+		func() *titleCount { return &titleCount{} },
+		queryBuilder,
+		nil,
+	)
+
+	assert.NoError(t, err)
+	assert.Empty(t, results)
+	queryBuilder.AssertExpectations(t)
+}
@@ -0,0 +1,220 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/pakkasys/fluidapi/database"
+	databasemock "github.com/pakkasys/fluidapi/database/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// testRow is a mutator with two columns.
+type testRow struct {
+	id int
+}
+
+func (r *testRow) TableName() string {
+	return "row"
+}
+
+func (r *testRow) InsertedValues() ([]string, []any) {
+	return []string{"id", "name"}, []any{r.id, "name"}
+}
+
+// reportingQueryBuilder reports inserted IDs like MySQL.
+type reportingQueryBuilder struct {
+	*databasemock.MockQueryBuilder
+}
+
+func (reportingQueryBuilder) InsertedIDs(
+	lastInsertID int64, rows int,
+) []int64 {
+	return database.FirstInsertedIDs(lastInsertID, rows)
+}
+
+// testRows returns n test rows.
+func testRows(n int) []database.Mutator {
+	rows := make([]database.Mutator, n)
+	for i := range rows {
+		rows[i] = &testRow{id: i}
+	}
+	return rows
+}
+
+// expectChunk sets up the execution of a chunk of the given size.
+func expectChunk(
+	queryBuilder *databasemock.MockQueryBuilder,
+	preparer *mock.Mock,
+	rows int,
+	lastInsertID int64,
+) {
+	stmt := &databasemock.MockStmt{}
+	result := &databasemock.MockResult{}
+	queryBuilder.On("InsertMany", "row", mock.MatchedBy(
+		func(valuesFuncs []database.InsertedValuesFn) bool {
+			return len(valuesFuncs) == rows
+		},
+	)).Return("INSERT", []any{}).Once()
+	preparer.On("Prepare", "INSERT").Return(stmt, nil).Once()
+	stmt.On("Exec", mock.Anything).Return(result, nil)
+	stmt.On("Close").Return(nil)
+	result.On("RowsAffected").Return(int64(rows), nil)
+	result.On("LastInsertId").Return(lastInsertID, nil)
+}
+
+// TestBatchOptions_ChunkSize tests the chunk size of row and parameter
+// limits.
+func TestBatchOptions_ChunkSize(t *testing.T) {
+	var none *database.BatchOptions
+	assert.Equal(t, 0, none.ChunkSize(2))
+	assert.Equal(t, 10, (&database.BatchOptions{MaxRows: 10}).ChunkSize(2))
+	assert.Equal(t, 5, (&database.BatchOptions{
+		MaxRows: 10, MaxParams: 11,
+	}).ChunkSize(2))
+	assert.Equal(t, 32767, (&database.BatchOptions{
+		MaxParams: database.MaxPostgresParams,
+	}).ChunkSize(2))
+	assert.Equal(t, 1, (&database.BatchOptions{MaxParams: 1}).ChunkSize(2))
+}
+
+// TestInsertBatch tests that rows are inserted in chunks with inserted IDs.
+func TestInsertBatch(t *testing.T) {
+	queryBuilder := &databasemock.MockQueryBuilder{}
+	tx := &databasemock.MockTx{}
+	expectChunk(queryBuilder, &tx.Mock, 2, 1)
+	expectChunk(queryBuilder, &tx.Mock, 2, 3)
+	expectChunk(queryBuilder, &tx.Mock, 1, 5)
+
+	result, err := database.NewMutateDBOps[*testRow]().InsertBatch(
+		tx,
+		testRows(5),
+		&database.BatchOptions{MaxParams: 5},
+		reportingQueryBuilder{queryBuilder},
+		nil,
+	)
+
+	assert.NoError(t, err)
+	assert.Len(t, result.Chunks, 3)
+	assert.Equal(t, int64(5), result.RowsAffected)
+	assert.Equal(t, []int64{1, 2, 3, 4, 5}, result.InsertedIDs())
+	queryBuilder.AssertExpectations(t)
+}
+
+// TestInsertBatch_NoIDs tests that inserted IDs are not reported without an
+// InsertedIDsReporter.
+func TestInsertBatch_NoIDs(t *testing.T) {
+	queryBuilder := &databasemock.MockQueryBuilder{}
+	tx := &databasemock.MockTx{}
+	expectChunk(queryBuilder, &tx.Mock, 3, 0)
+
+	result, err := database.NewMutateDBOps[*testRow]().InsertBatch(
+		tx, testRows(3), nil, queryBuilder, nil,
+	)
+
+	assert.NoError(t, err)
+	assert.Len(t, result.Chunks, 1)
+	assert.Nil(t, result.InsertedIDs())
+}
+
+// TestInsertBatch_ChunkError tests that the results of the preceding chunks
+// are returned with the error of a failed chunk.
+func TestInsertBatch_ChunkError(t *testing.T) {
+	queryBuilder := &databasemock.MockQueryBuilder{}
+	tx := &databasemock.MockTx{}
+	expectChunk(queryBuilder, &tx.Mock, 2, 0)
+	queryBuilder.On("InsertMany", "row", mock.Anything).
+		Return("INSERT", []any{}).Once()
+	tx.On("Prepare", "INSERT").Return(nil, errors.New("too large")).Once()
+
+	result, err := database.NewMutateDBOps[*testRow]().InsertBatch(
+		tx,
+		testRows(4),
+		&database.BatchOptions{MaxRows: 2},
+		queryBuilder,
+		nil,
+	)
+
+	assert.ErrorContains(t, err, "chunk at row 2: too large")
+	assert.Len(t, result.Chunks, 1)
+}
+
+// TestInsertBatchTx_Rollback tests that a failed chunk rolls back the batch.
+func TestInsertBatchTx_Rollback(t *testing.T) {
+	queryBuilder := &databasemock.MockQueryBuilder{}
+	db := &databasemock.MockDB{}
+	tx := &databasemock.MockTx{}
+	db.On("BeginTx", mock.Anything, mock.Anything).Return(tx, nil)
+	expectChunk(queryBuilder, &tx.Mock, 2, 0)
+	queryBuilder.On("InsertMany", "row", mock.Anything).
+		Return("INSERT", []any{}).Once()
+	tx.On("Prepare", "INSERT").Return(nil, errors.New("too large")).Once()
+	tx.On("Rollback").Return(nil)
+
+	_, err := database.NewMutateDBOps[*testRow]().InsertBatchTx(
+		context.Background(),
+		db,
+		testRows(4),
+		&database.BatchOptions{MaxRows: 2},
+		queryBuilder,
+		nil,
+	)
+
+	assert.Error(t, err)
+	tx.AssertCalled(t, "Rollback")
+	tx.AssertNotCalled(t, "Commit")
+}
+
+// TestUpsertBatch_NoProjections tests that upserts require update
+// projections.
+func TestUpsertBatch_NoProjections(t *testing.T) {
+	_, err := database.NewMutateDBOps[*testRow]().UpsertBatch(
+		&databasemock.MockTx{},
+		testRows(1),
+		nil,
+		nil,
+		&databasemock.MockQueryBuilder{},
+		nil,
+	)
+
+	assert.ErrorContains(t, err, "must provide update projections")
+}
+
+// TestUpsertBatch_StatementParams tests that the parameters of the update
+// clause count towards the parameter limit and that upserts do not report
+// inserted IDs.
+func TestUpsertBatch_StatementParams(t *testing.T) {
+	queryBuilder := &databasemock.MockQueryBuilder{}
+	tx := &databasemock.MockTx{}
+	updateProjections := []database.Projection{
+		{Table: "row", Column: "name", Alias: "new"},
+	}
+	expectUpsert := func(rows int, params []any) {
+		queryBuilder.On("UpsertMany", "row", mock.MatchedBy(
+			func(valuesFuncs []database.InsertedValuesFn) bool {
+				return len(valuesFuncs) == rows
+			},
+		), updateProjections).Return("UPSERT", params).Once()
+	}
+	expectUpsert(1, []any{0, "name", "a", "b"})
+	for _, rows := range []int{2, 2, 1} {
+		expectUpsert(rows, []any{})
+		databasemock.ExpectExec(&tx.Mock, "UPSERT", int64(rows), 9)
+	}
+
+	result, err := database.NewMutateDBOps[*testRow]().UpsertBatch(
+		tx,
+		testRows(5),
+		updateProjections,
+		&database.BatchOptions{MaxParams: 7},
+		reportingQueryBuilder{queryBuilder},
+		nil,
+	)
+
+	assert.NoError(t, err)
+	assert.Len(t, result.Chunks, 3)
+	assert.Nil(t, result.InsertedIDs())
+	queryBuilder.AssertExpectations(t)
+}
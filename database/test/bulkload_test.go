@@ -0,0 +1,220 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"iter"
+	"testing"
+	"time"
+
+	"github.com/pakkasys/fluidapi/core"
+	"github.com/pakkasys/fluidapi/database"
+	databasemock "github.com/pakkasys/fluidapi/database/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// copyQueryBuilder is a query builder supporting COPY.
+type copyQueryBuilder struct {
+	*databasemock.MockQueryBuilder
+}
+
+func (copyQueryBuilder) CopyIn(table string, columns []string) string {
+	return "COPY " + table
+}
+
+// loadDataQueryBuilder is a query builder supporting LOAD DATA.
+type loadDataQueryBuilder struct {
+	*databasemock.MockQueryBuilder
+	reader *io.Reader
+}
+
+func (b loadDataQueryBuilder) LoadData(
+	table string, columns []string, reader io.Reader,
+) (string, func()) {
+	*b.reader = reader
+	return "LOAD DATA " + table, func() {}
+}
+
+// rowSeq returns an iterator of the rows.
+func rowSeq(rows []database.Mutator) iter.Seq2[database.Mutator, error] {
+	return func(yield func(database.Mutator, error) bool) {
+		for _, row := range rows {
+			if !yield(row, nil) {
+				return
+			}
+		}
+	}
+}
+
+// TestBulkLoad_Insert tests the chunked insert fallback and its progress
+// events.
+func TestBulkLoad_Insert(t *testing.T) {
+	queryBuilder := &databasemock.MockQueryBuilder{}
+	tx := &databasemock.MockTx{}
+	expectChunk(queryBuilder, &tx.Mock, 2, 0)
+	expectChunk(queryBuilder, &tx.Mock, 2, 0)
+	expectChunk(queryBuilder, &tx.Mock, 1, 0)
+	emitter := core.NewEventEmitter()
+	events := make(chan *core.Event, 3)
+	for _, eventType := range []core.EventType{
+		database.EventBulkLoadProgress, database.EventBulkLoadCompleted,
+	} {
+		emitter.RegisterListener(eventType, func(event *core.Event) {
+			events <- event
+		})
+	}
+
+	result, err := database.NewMutateDBOps[*testRow]().BulkLoad(
+		context.Background(),
+		tx,
+		rowSeq(testRows(5)),
+		&database.BulkLoadOptions{
+			Batch:            &database.BatchOptions{MaxRows: 2},
+			EventEmitter:     emitter,
+			ProgressInterval: 2,
+		},
+		queryBuilder,
+		nil,
+	)
+
+	assert.NoError(t, err)
+	assert.Equal(t, database.BulkLoadInsert, result.Method)
+	assert.Equal(t, int64(5), result.Rows)
+	queryBuilder.AssertExpectations(t)
+	var rows []int64
+	completed := false
+	for range 3 {
+		select {
+		case event := <-events:
+			progress := event.Data.(*database.BulkLoadProgress)
+			rows = append(rows, progress.Rows)
+			completed = completed ||
+				event.Type == database.EventBulkLoadCompleted
+		case <-time.After(time.Second):
+			t.Fatal("event not emitted")
+		}
+	}
+	assert.ElementsMatch(t, []int64{2, 4, 5}, rows)
+	assert.True(t, completed)
+}
+
+// TestBulkLoad_Copy tests that rows are sent with COPY and flushed.
+func TestBulkLoad_Copy(t *testing.T) {
+	tx := &databasemock.MockTx{}
+	stmt := &databasemock.MockStmt{}
+	tx.On("Prepare", "COPY row").Return(stmt, nil)
+	stmt.On("Exec", mock.Anything).Return(&databasemock.MockResult{}, nil)
+	stmt.On("Close").Return(nil)
+
+	result, err := database.NewMutateDBOps[*testRow]().BulkLoad(
+		context.Background(),
+		tx,
+		rowSeq(testRows(3)),
+		nil,
+		copyQueryBuilder{&databasemock.MockQueryBuilder{}},
+		nil,
+	)
+
+	assert.NoError(t, err)
+	assert.Equal(t, database.BulkLoadCopy, result.Method)
+	assert.Equal(t, int64(3), result.Rows)
+	stmt.AssertNumberOfCalls(t, "Exec", 4)
+	stmt.AssertCalled(t, "Exec", []any(nil))
+}
+
+// TestBulkLoad_LoadData tests that rows are streamed in the LOAD DATA
+// format.
+func TestBulkLoad_LoadData(t *testing.T) {
+	tx := &databasemock.MockTx{}
+	stmt := &databasemock.MockStmt{}
+	var reader io.Reader
+	var loaded []byte
+	tx.On("Prepare", "LOAD DATA row").Return(stmt, nil)
+	stmt.On("Exec", mock.Anything).Run(func(mock.Arguments) {
+		loaded, _ = io.ReadAll(reader)
+	}).Return(&databasemock.MockResult{}, nil)
+	stmt.On("Close").Return(nil)
+
+	result, err := database.NewMutateDBOps[*testRow]().BulkLoad(
+		context.Background(),
+		tx,
+		rowSeq(testRows(2)),
+		nil,
+		loadDataQueryBuilder{&databasemock.MockQueryBuilder{}, &reader},
+		nil,
+	)
+
+	assert.NoError(t, err)
+	assert.Equal(t, database.BulkLoadLoadData, result.Method)
+	assert.Equal(t, "0\tname\n1\tname\n", string(loaded))
+}
+
+// eventRow is a mutator with a time column.
+type eventRow struct {
+	at time.Time
+}
+
+func (r *eventRow) TableName() string {
+	return "event"
+}
+
+func (r *eventRow) InsertedValues() ([]string, []any) {
+	return []string{"at"}, []any{r.at}
+}
+
+// TestBulkLoad_LoadDataTime tests that times are loaded in UTC.
+func TestBulkLoad_LoadDataTime(t *testing.T) {
+	tx := &databasemock.MockTx{}
+	stmt := &databasemock.MockStmt{}
+	var reader io.Reader
+	var loaded []byte
+	tx.On("Prepare", "LOAD DATA event").Return(stmt, nil)
+	stmt.On("Exec", mock.Anything).Run(func(mock.Arguments) {
+		loaded, _ = io.ReadAll(reader)
+	}).Return(&databasemock.MockResult{}, nil)
+	stmt.On("Close").Return(nil)
+	helsinki := time.FixedZone("EET", 2*60*60)
+	at := time.Date(2024, 1, 1, 14, 30, 0, 0, helsinki)
+
+	_, err := database.NewMutateDBOps[*eventRow]().BulkLoad(
+		context.Background(),
+		tx,
+		rowSeq([]database.Mutator{&eventRow{at: at}}),
+		nil,
+		loadDataQueryBuilder{&databasemock.MockQueryBuilder{}, &reader},
+		nil,
+	)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "2024-01-01 12:30:00\n", string(loaded))
+}
+
+// TestBulkLoad_SourceError tests that an error of the entities aborts the
+// load.
+func TestBulkLoad_SourceError(t *testing.T) {
+	tx := &databasemock.MockTx{}
+	stmt := &databasemock.MockStmt{}
+	tx.On("Prepare", "COPY row").Return(stmt, nil)
+	stmt.On("Exec", mock.Anything).Return(&databasemock.MockResult{}, nil)
+	stmt.On("Close").Return(nil)
+	entities := func(yield func(database.Mutator, error) bool) {
+		if yield(&testRow{id: 1}, nil) {
+			yield(nil, errors.New("malformed line"))
+		}
+	}
+
+	_, err := database.NewMutateDBOps[*testRow]().BulkLoad(
+		context.Background(),
+		tx,
+		entities,
+		nil,
+		copyQueryBuilder{&databasemock.MockQueryBuilder{}},
+		nil,
+	)
+
+	assert.ErrorContains(t, err, "malformed line")
+	stmt.AssertNumberOfCalls(t, "Exec", 1)
+	stmt.AssertCalled(t, "Close")
+}
@@ -0,0 +1,117 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/pakkasys/fluidapi/database"
+	databasemock "github.com/pakkasys/fluidapi/database/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// TestBuildWith_Recursive tests a recursive CTE with parameters numbered in
+// statement order.
+func TestBuildWith_Recursive(t *testing.T) {
+	ctes := []database.CTE{{
+		Name:      "tree",
+		Columns:   []string{"id", "parent_id"},
+		Recursive: true,
+		Query: database.NewUnionAll(
+			database.NewSubquery("category", &database.GetOptions{
+				Selectors: database.Selectors{{
+					Table:     "category",
+					Column:    "id",
+					Predicate: database.Equal,
+					Value:     1,
+				}},
+			}),
+			database.NewSubquery("tree", &database.GetOptions{
+				Selectors: database.Selectors{{
+					Table:     "tree",
+					Column:    "depth",
+					Predicate: database.Less,
+					Value:     5,
+				}},
+			}),
+		),
+	}}
+
+	sql, params, err := database.BuildWith(testSubqueryDialect{}, ctes, nil)
+
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		`WITH RECURSIVE "tree" ("id", "parent_id") AS (`+
+			`SELECT 1 FROM "category" WHERE "category"."id" = $1 UNION ALL `+
+			`SELECT 1 FROM "tree" WHERE "tree"."depth" < $2)`,
+		sql,
+	)
+	assert.Equal(t, []any{1, 5}, params)
+}
+
+// TestBuildCompound tests that compound queries follow the main query.
+func TestBuildCompound(t *testing.T) {
+	compound := database.NewUnion(
+		database.NewSubquery("archived_user", nil),
+	)
+
+	sql, params, err := database.BuildCompound(
+		testSubqueryDialect{}, compound, []any{"x"},
+	)
+
+	assert.NoError(t, err)
+	assert.Equal(t, ` UNION SELECT 1 FROM "archived_user"`, sql)
+	assert.Equal(t, []any{"x"}, params)
+}
+
+// TestGetOptions_Table tests that From overrides the entity table.
+func TestGetOptions_Table(t *testing.T) {
+	assert.Equal(t, "user", (&database.GetOptions{}).Table("user"))
+	assert.Equal(
+		t, "tree", (&database.GetOptions{From: "tree"}).Table("category"),
+	)
+}
+
+// TestGetMany_UnsupportedWith tests that GetMany returns an
+// UnsupportedQueryError if the query builder does not support CTEs.
+func TestGetMany_UnsupportedWith(t *testing.T) {
+	db := &databasemock.MockDB{}
+	options := &database.GetOptions{
+		With: []database.CTE{{
+			Name:  "active",
+			Query: database.NewSubquery("user", nil),
+		}},
+		From: "active",
+	}
+
+	_, err := database.NewReadDBOps[*testUser]().GetMany(
+		db, options, newTestUser, &databasemock.MockQueryBuilder{}, nil,
+	)
+
+	var queryErr *database.UnsupportedQueryError
+	assert.ErrorAs(t, err, &queryErr)
+	assert.Equal(t, database.FeatureWith, queryErr.Feature)
+	db.AssertNotCalled(t, "Prepare", mock.Anything)
+}
+
+// TestIterate_UnsupportedCompound tests that Iterate yields an
+// UnsupportedQueryError if the query builder does not support UNION.
+func TestIterate_UnsupportedCompound(t *testing.T) {
+	db := &databasemock.MockDB{}
+	options := &database.GetOptions{
+		Compound: database.NewUnionAll(database.NewSubquery("admin", nil)),
+	}
+
+	var errs []error
+	for _, err := range database.NewReadDBOps[*testUser]().Iterate(
+		db, options, newTestUser, &databasemock.MockQueryBuilder{}, nil,
+	) {
+		errs = append(errs, err)
+	}
+
+	assert.Len(t, errs, 1)
+	var queryErr *database.UnsupportedQueryError
+	assert.ErrorAs(t, errs[0], &queryErr)
+	assert.Equal(t, database.FeatureCompound, queryErr.Feature)
+	db.AssertNotCalled(t, "Prepare", mock.Anything)
+}
@@ -0,0 +1,120 @@
+package test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/pakkasys/fluidapi/database"
+	"github.com/stretchr/testify/assert"
+)
+
+// testDialect is an expression dialect with numbered placeholders.
+type testDialect struct{}
+
+func (testDialect) QuoteColumn(table string, column string) string {
+	if table == "" {
+		return `"` + column + `"`
+	}
+	return `"` + table + `"."` + column + `"`
+}
+
+func (testDialect) Placeholder(position int) string {
+	return fmt.Sprintf("$%d", position)
+}
+
+func (testDialect) Function(
+	fn database.Function, args []string,
+) (string, bool) {
+	switch fn {
+	case database.FuncNow:
+		return "NOW()", true
+	case database.FuncCoalesce:
+		return "COALESCE(" + strings.Join(args, ", ") + ")", true
+	default:
+		return "", false
+	}
+}
+
+// TestBuildValue_Increment tests that increments reference the column and
+// pass the amount as a parameter.
+func TestBuildValue_Increment(t *testing.T) {
+	sql, params, err := database.BuildValue(
+		testDialect{}, database.Increment("views", 1), []any{"x"},
+	)
+
+	assert.NoError(t, err)
+	assert.Equal(t, `"views" + $2`, sql)
+	assert.Equal(t, []any{"x", 1}, params)
+}
+
+// TestBuildValue_NestedArithmetic tests that nested arithmetic operands keep
+// their grouping.
+func TestBuildValue_NestedArithmetic(t *testing.T) {
+	sql, params, err := database.BuildValue(
+		testDialect{},
+		database.ArithmeticExpr{
+			Left:     database.Column("x"),
+			Operator: "-",
+			Right:    database.Increment("y", 1),
+		},
+		nil,
+	)
+
+	assert.NoError(t, err)
+	assert.Equal(t, `"x" - ("y" + $1)`, sql)
+	assert.Equal(t, []any{1}, params)
+}
+
+// TestBuildValue_Func tests function calls with nested expressions.
+func TestBuildValue_Func(t *testing.T) {
+	sql, params, err := database.BuildValue(
+		testDialect{},
+		database.Func(
+			database.FuncCoalesce,
+			database.Column("nickname"),
+			database.Func(database.FuncNow),
+			"default",
+		),
+		nil,
+	)
+
+	assert.NoError(t, err)
+	assert.Equal(t, `COALESCE("nickname", NOW(), $1)`, sql)
+	assert.Equal(t, []any{"default"}, params)
+}
+
+// TestBuildValue_UnsupportedFunc tests that functions unknown to the dialect
+// return an error.
+func TestBuildValue_UnsupportedFunc(t *testing.T) {
+	_, _, err := database.BuildValue(
+		testDialect{}, database.Func(database.FuncUpper, "a"), nil,
+	)
+
+	assert.Error(t, err)
+}
+
+// TestBuildValue_Case tests that CASE branches are rendered with
+// parameterized conditions and values.
+func TestBuildValue_Case(t *testing.T) {
+	expr := database.Case(
+		database.When{
+			Selectors: database.Selectors{
+				{Column: "status", Predicate: database.In, Value: []string{"a", "b"}},
+				{Column: "deleted_at", Predicate: database.Equal, Value: nil},
+			},
+			Then: database.Decrement("stock", 1),
+		},
+	).WithElse(database.Column("stock"))
+
+	sql, params, err := database.BuildValue(testDialect{}, expr, nil)
+
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		`CASE WHEN "status" IN ($1, $2) AND "deleted_at" IS NULL `+
+			`THEN "stock" - $3 ELSE "stock" END`,
+		sql,
+	)
+	assert.Equal(t, []any{"a", "b", 1}, params)
+}
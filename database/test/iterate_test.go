@@ -0,0 +1,96 @@
+package test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/pakkasys/fluidapi/database"
+	databasemock "github.com/pakkasys/fluidapi/database/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// TestIterate tests that entities are yielded one at a time and the
+// statement and rows are closed.
+func TestIterate(t *testing.T) {
+	queryBuilder := &databasemock.MockQueryBuilder{}
+	db := &databasemock.MockDB{}
+	queryBuilder.On("Get", "user", mock.Anything).Return("SELECT", []any{})
+	expectUserRows(&db.Mock, "SELECT", 1, 2, 3)
+
+	var ids []int64
+	for user, err := range database.NewReadDBOps[*testUser]().Iterate(
+		db, &database.GetOptions{}, newTestUser, queryBuilder, nil,
+	) {
+		assert.NoError(t, err)
+		ids = append(ids, user.ID)
+	}
+
+	assert.Equal(t, []int64{1, 2, 3}, ids)
+}
+
+// TestIterate_Break tests that the statement and rows are closed when the
+// loop exits early.
+func TestIterate_Break(t *testing.T) {
+	queryBuilder := &databasemock.MockQueryBuilder{}
+	db := &databasemock.MockDB{}
+	stmt := &databasemock.MockStmt{}
+	rows := &databasemock.MockRows{}
+	queryBuilder.On("Get", "user", mock.Anything).Return("SELECT", []any{})
+	db.On("Prepare", "SELECT").Return(stmt, nil)
+	stmt.On("Query", mock.Anything).Return(rows, nil)
+	stmt.On("Close").Return(nil)
+	rows.On("Next").Return(true)
+	rows.On("Scan", mock.Anything).Return(nil)
+	rows.On("Close").Return(nil)
+
+	count := 0
+	for range database.NewReadDBOps[*testUser]().Iterate(
+		db, &database.GetOptions{}, newTestUser, queryBuilder, nil,
+	) {
+		count++
+		if count == 2 {
+			break
+		}
+	}
+
+	assert.Equal(t, 2, count)
+	rows.AssertCalled(t, "Close")
+	stmt.AssertCalled(t, "Close")
+	rows.AssertNotCalled(t, "Err")
+}
+
+// TestIterateRows_ScanError tests that a scan error is yielded and ends the
+// iteration.
+func TestIterateRows_ScanError(t *testing.T) {
+	rows := &databasemock.MockRows{}
+	rows.On("Next").Return(true)
+	rows.On("Scan", mock.Anything).Return(errors.New("bad row"))
+	rows.On("Close").Return(nil)
+
+	var errs []error
+	for _, err := range database.IterateRows(rows, newTestUser) {
+		errs = append(errs, err)
+	}
+
+	assert.Len(t, errs, 1)
+	assert.EqualError(t, errs[0], "bad row")
+	rows.AssertCalled(t, "Close")
+}
+
+// TestIterate_NilOptions tests that argument errors are yielded.
+func TestIterate_NilOptions(t *testing.T) {
+	var errs []error
+	for _, err := range database.NewReadDBOps[*testUser]().Iterate(
+		&databasemock.MockDB{},
+		nil,
+		newTestUser,
+		&databasemock.MockQueryBuilder{},
+		nil,
+	) {
+		errs = append(errs, err)
+	}
+
+	assert.Len(t, errs, 1)
+	assert.EqualError(t, errs[0], "Iterate: options is nil")
+}
@@ -0,0 +1,175 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/pakkasys/fluidapi/database"
+	databasemock "github.com/pakkasys/fluidapi/database/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// testJoinDialect is a test dialect that supports all joins except FULL and
+// LATERAL joins.
+type testJoinDialect struct {
+	testSubqueryDialect
+	lateral bool
+}
+
+func (testJoinDialect) QuoteTable(table string) string {
+	return `"` + table + `"`
+}
+
+func (d testJoinDialect) SupportsJoin(
+	joinType database.JoinType, lateral bool,
+) bool {
+	return joinType != database.JoinTypeFull && (!lateral || d.lateral)
+}
+
+// TestBuildJoin_SelfJoin tests an aliased self-join on a composite key with
+// an extra selector.
+func TestBuildJoin_SelfJoin(t *testing.T) {
+	join := database.NewJoin(database.JoinTypeLeft, "user").
+		WithAlias("manager").
+		WithOn(
+			database.ColumnSelector{Table: "manager", Column: "id"},
+			database.ColumnSelector{Table: "user", Column: "manager_id"},
+		).
+		WithOn(
+			database.ColumnSelector{Table: "manager", Column: "tenant_id"},
+			database.ColumnSelector{Table: "user", Column: "tenant_id"},
+		).
+		WithSelectors(database.Selector{
+			Table:     "manager",
+			Column:    "deleted_at",
+			Predicate: database.Equal,
+		})
+
+	sql, params, err := database.BuildJoin(testJoinDialect{}, join, nil)
+
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		`LEFT JOIN "user" AS "manager" ON "manager"."id" = "user"."manager_id"`+
+			` AND "manager"."tenant_id" = "user"."tenant_id"`+
+			` AND "manager"."deleted_at" IS NULL`,
+		sql,
+	)
+	assert.Empty(t, params)
+}
+
+// TestBuildJoin_Lateral tests a LATERAL join of a subquery.
+func TestBuildJoin_Lateral(t *testing.T) {
+	join := database.Join{
+		Type:    database.JoinTypeLeft,
+		Alias:   "last_order",
+		Lateral: true,
+		Subquery: database.NewSubquery("order", &database.GetOptions{
+			Selectors: database.Selectors{{
+				Table:     "order",
+				Column:    "user_id",
+				Predicate: database.Equal,
+				Value:     database.ColumnExpr{Table: "user", Column: "id"},
+			}},
+		}),
+	}
+
+	sql, _, err := database.BuildJoin(
+		testJoinDialect{lateral: true}, join, nil,
+	)
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		`LEFT JOIN LATERAL (SELECT 1 FROM "order" WHERE `+
+			`"order"."user_id" = "user"."id") AS "last_order" ON TRUE`,
+		sql,
+	)
+
+	_, _, err = database.BuildJoin(testJoinDialect{}, join, nil)
+	var joinErr *database.UnsupportedJoinError
+	assert.ErrorAs(t, err, &joinErr)
+	assert.True(t, joinErr.Lateral)
+}
+
+// TestBuildJoin_Unsupported tests that unsupported join types return an
+// error instead of SQL.
+func TestBuildJoin_Unsupported(t *testing.T) {
+	join := database.Join{
+		Type:    database.JoinTypeFull,
+		Table:   "order",
+		OnLeft:  database.ColumnSelector{Table: "order", Column: "user_id"},
+		OnRight: database.ColumnSelector{Table: "user", Column: "id"},
+	}
+
+	_, _, err := database.BuildJoin(testJoinDialect{}, join, nil)
+
+	var joinErr *database.UnsupportedJoinError
+	assert.ErrorAs(t, err, &joinErr)
+	assert.Equal(t, database.JoinTypeFull, joinErr.Type)
+}
+
+// TestBuildJoin_Cross tests that CROSS joins have no condition.
+func TestBuildJoin_Cross(t *testing.T) {
+	join := database.NewJoin(database.JoinTypeCross, "calendar")
+
+	sql, _, err := database.BuildJoin(testJoinDialect{}, join, nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, `CROSS JOIN "calendar"`, sql)
+}
+
+// joinQueryBuilder is a query builder that supports all joins except FULL
+// joins.
+type joinQueryBuilder struct {
+	*databasemock.MockQueryBuilder
+}
+
+func (joinQueryBuilder) SupportsJoin(
+	joinType database.JoinType, lateral bool,
+) bool {
+	return joinType != database.JoinTypeFull
+}
+
+// TestGet_UnsupportedJoin tests that Get returns an UnsupportedJoinError
+// without querying if the query builder does not support a join.
+func TestGet_UnsupportedJoin(t *testing.T) {
+	db := &databasemock.MockDB{}
+	queryBuilder := joinQueryBuilder{&databasemock.MockQueryBuilder{}}
+	options := &database.GetOptions{Joins: database.Joins{
+		database.NewJoin(database.JoinTypeFull, "order").WithOn(
+			database.ColumnSelector{Table: "order", Column: "user_id"},
+			database.ColumnSelector{Table: "user", Column: "id"},
+		),
+	}}
+
+	_, err := database.NewReadDBOps[*testUser]().Get(
+		db, options, newTestUser, queryBuilder, nil,
+	)
+
+	var joinErr *database.UnsupportedJoinError
+	assert.ErrorAs(t, err, &joinErr)
+	assert.Equal(t, database.JoinTypeFull, joinErr.Type)
+	db.AssertNotCalled(t, "Prepare", mock.Anything)
+}
+
+// TestCount_UnsupportedLateralJoin tests that Count returns an
+// UnsupportedJoinError for a LATERAL join if the query builder does not
+// render joins with BuildJoin.
+func TestCount_UnsupportedLateralJoin(t *testing.T) {
+	db := &databasemock.MockDB{}
+	options := &database.CountOptions{Joins: database.Joins{{
+		Type:     database.JoinTypeLeft,
+		Alias:    "last_order",
+		Subquery: database.NewSubquery("order", nil),
+		Lateral:  true,
+	}}}
+
+	_, err := database.NewReadDBOps[*testUser]().Count(
+		db, options, newTestUser, &databasemock.MockQueryBuilder{}, nil,
+	)
+
+	var joinErr *database.UnsupportedJoinError
+	assert.ErrorAs(t, err, &joinErr)
+	assert.True(t, joinErr.Lateral)
+	db.AssertNotCalled(t, "Prepare", mock.Anything)
+}
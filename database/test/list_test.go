@@ -0,0 +1,83 @@
+package test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/pakkasys/fluidapi/database"
+	databasemock "github.com/pakkasys/fluidapi/database/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// estimatingQueryBuilder is a query builder supporting estimated counts.
+type estimatingQueryBuilder struct {
+	*databasemock.MockQueryBuilder
+}
+
+func (b estimatingQueryBuilder) EstimatedCount(table string) (string, []any) {
+	args := b.Called(table)
+	return args.String(0), args.Get(1).([]any)
+}
+
+// expectCount sets up a count query returning the count or scan error.
+func expectCount(preparer *mock.Mock, query string, count int, err error) {
+	stmt := &databasemock.MockStmt{}
+	row := &databasemock.MockRow{}
+	preparer.On("Prepare", query).Return(stmt, nil).Once()
+	stmt.On("QueryRow", mock.Anything).Return(row)
+	stmt.On("Close").Return(nil)
+	row.On("Scan", mock.Anything).Run(func(args mock.Arguments) {
+		*args.Get(0).([]any)[0].(*int) = count
+	}).Return(err)
+}
+
+// TestList_EstimatedCount tests that the estimated count is used when there
+// are no selectors.
+func TestList_EstimatedCount(t *testing.T) {
+	queryBuilder := estimatingQueryBuilder{&databasemock.MockQueryBuilder{}}
+	db := &databasemock.MockDB{}
+	queryBuilder.On("Get", "user", mock.Anything).Return("SELECT", []any{})
+	queryBuilder.On("EstimatedCount", "user").Return("ESTIMATE", []any{})
+	expectUserRows(&db.Mock, "SELECT", 1)
+	expectCount(&db.Mock, "ESTIMATE", 1000, nil)
+
+	result, err := database.NewReadDBOps[*testUser]().List(
+		db,
+		&database.GetOptions{},
+		database.CountEstimated,
+		newTestUser,
+		queryBuilder,
+		nil,
+	)
+
+	assert.NoError(t, err)
+	assert.Len(t, result.Entities, 1)
+	assert.Equal(t, 1000, result.Total)
+	assert.True(t, result.Estimated)
+}
+
+// TestList_EstimatedCountError tests that estimated count errors are
+// translated by the error checker like other read errors.
+func TestList_EstimatedCountError(t *testing.T) {
+	queryBuilder := estimatingQueryBuilder{&databasemock.MockQueryBuilder{}}
+	db := &databasemock.MockDB{}
+	errorChecker := &databasemock.MockErrorChecker{}
+	translated := errors.New("translated")
+	queryBuilder.On("Get", "user", mock.Anything).Return("SELECT", []any{})
+	queryBuilder.On("EstimatedCount", "user").Return("ESTIMATE", []any{})
+	expectUserRows(&db.Mock, "SELECT")
+	expectCount(&db.Mock, "ESTIMATE", 0, assert.AnError)
+	errorChecker.On("Check", assert.AnError).Return(translated)
+
+	_, err := database.NewReadDBOps[*testUser]().List(
+		db,
+		&database.GetOptions{},
+		database.CountEstimated,
+		newTestUser,
+		queryBuilder,
+		errorChecker,
+	)
+
+	assert.Equal(t, translated, err)
+}
@@ -0,0 +1,63 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/pakkasys/fluidapi/database"
+	"github.com/stretchr/testify/assert"
+)
+
+// testLockDialect is a test dialect without FOR NO KEY UPDATE.
+type testLockDialect struct{}
+
+func (testLockDialect) QuoteTable(table string) string {
+	return "`" + table + "`"
+}
+
+func (testLockDialect) SupportsLock(
+	strength database.LockStrength, _ database.LockWait,
+) bool {
+	return strength == database.LockForUpdate ||
+		strength == database.LockForShare
+}
+
+// TestBuildLock tests rendering of lock modes with lock-of tables.
+func TestBuildLock(t *testing.T) {
+	locking := database.NewLocking(database.LockForUpdate).
+		WithOf("job").
+		WithWait(database.LockSkipLocked)
+
+	sql, err := database.BuildLock(testLockDialect{}, locking)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "FOR UPDATE OF `job` SKIP LOCKED", sql)
+}
+
+// TestBuildLock_Unsupported tests that unsupported lock modes return an
+// error.
+func TestBuildLock_Unsupported(t *testing.T) {
+	locking := database.NewLocking(database.LockForNoKeyUpdate)
+
+	_, err := database.BuildLock(testLockDialect{}, locking)
+
+	var lockErr *database.UnsupportedLockError
+	assert.ErrorAs(t, err, &lockErr)
+	assert.Equal(t, database.LockForNoKeyUpdate, lockErr.Strength)
+}
+
+// TestGetOptions_LockClause tests that the Lock flag is treated as FOR
+// UPDATE.
+func TestGetOptions_LockClause(t *testing.T) {
+	assert.Nil(t, (&database.GetOptions{}).LockClause())
+	assert.Equal(
+		t,
+		database.NewLocking(database.LockForUpdate),
+		(&database.GetOptions{Lock: true}).LockClause(),
+	)
+	share := database.NewLocking(database.LockForShare)
+	assert.Equal(
+		t,
+		share,
+		(&database.GetOptions{Lock: true, Locking: share}).LockClause(),
+	)
+}
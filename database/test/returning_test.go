@@ -0,0 +1,317 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/pakkasys/fluidapi/database"
+	databasemock "github.com/pakkasys/fluidapi/database/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// testUser is an entity with a generated ID.
+type testUser struct {
+	ID   int64
+	Name string
+}
+
+func (u *testUser) TableName() string {
+	return "user"
+}
+
+func (u *testUser) InsertedValues() ([]string, []any) {
+	return []string{"name"}, []any{u.Name}
+}
+
+func (u *testUser) ScanRow(row database.Row) error {
+	return row.Scan(&u.ID, &u.Name)
+}
+
+// returningQueryBuilder is a query builder supporting RETURNING.
+type returningQueryBuilder struct {
+	*databasemock.MockQueryBuilder
+}
+
+func (b returningQueryBuilder) InsertReturning(
+	table string,
+	insertedValuesFunc database.InsertedValuesFn,
+	projections database.Projections,
+) (string, []any) {
+	args := b.Called(table, projections)
+	return args.String(0), args.Get(1).([]any)
+}
+
+func (b returningQueryBuilder) UpdateReturning(
+	table string,
+	updates []database.Update,
+	selectors []database.Selector,
+	projections database.Projections,
+) (string, []any) {
+	args := b.Called(table, updates, selectors, projections)
+	return args.String(0), args.Get(1).([]any)
+}
+
+func (b returningQueryBuilder) DeleteReturning(
+	table string,
+	selectors []database.Selector,
+	projections database.Projections,
+) (string, []any) {
+	args := b.Called(table, selectors, projections)
+	return args.String(0), args.Get(1).([]any)
+}
+
+// expectUserRows sets up a query returning users with the given IDs.
+func expectUserRows(preparer *mock.Mock, query string, ids ...int64) {
+	stmt := &databasemock.MockStmt{}
+	rows := &databasemock.MockRows{}
+	preparer.On("Prepare", query).Return(stmt, nil).Once()
+	stmt.On("Query", mock.Anything).Return(rows, nil)
+	stmt.On("Close").Return(nil)
+	for _, id := range ids {
+		rows.On("Next").Return(true).Once()
+		rows.On("Scan", mock.Anything).Run(func(args mock.Arguments) {
+			dest := args.Get(0).([]any)
+			*dest[0].(*int64) = id
+			*dest[1].(*string) = "Ada"
+		}).Return(nil).Once()
+	}
+	rows.On("Next").Return(false)
+	rows.On("Err").Return(nil)
+	rows.On("Close").Return(nil)
+}
+
+// newTestUser returns a new test user.
+func newTestUser() *testUser {
+	return &testUser{}
+}
+
+// TestBuildReturning tests the rendering of RETURNING clauses.
+func TestBuildReturning(t *testing.T) {
+	all, err := database.BuildReturning(testDialect{}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "RETURNING *", all)
+
+	projected, err := database.BuildReturning(testDialect{}, database.Projections{
+		{Column: "id"},
+		{Column: "name", Alias: "user_name"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, `RETURNING "id", "name" AS "user_name"`, projected)
+}
+
+// TestReturningInsert_Native tests inserts with a RETURNING clause.
+func TestReturningInsert_Native(t *testing.T) {
+	queryBuilder := returningQueryBuilder{&databasemock.MockQueryBuilder{}}
+	db := &databasemock.MockDB{}
+	stmt := &databasemock.MockStmt{}
+	row := &databasemock.MockRow{}
+	queryBuilder.On("InsertReturning", "user", mock.Anything).
+		Return("INSERT RETURNING", []any{})
+	db.On("Prepare", "INSERT RETURNING").Return(stmt, nil)
+	stmt.On("QueryRow", mock.Anything).Return(row)
+	stmt.On("Close").Return(nil)
+	row.On("Scan", mock.Anything).Run(func(args mock.Arguments) {
+		*args.Get(0).([]any)[0].(*int64) = 7
+	}).Return(nil)
+	row.On("Err").Return(nil)
+
+	user, err := database.NewReturningDBOps[*testUser]().Insert(
+		db,
+		&testUser{Name: "Ada"},
+		&database.Returning{},
+		newTestUser,
+		queryBuilder,
+		nil,
+	)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), user.ID)
+}
+
+// TestReturningInsert_Emulated tests that inserted rows are selected by the
+// last insert ID without RETURNING support.
+func TestReturningInsert_Emulated(t *testing.T) {
+	queryBuilder := &databasemock.MockQueryBuilder{}
+	tx := &databasemock.MockTx{}
+	queryBuilder.On("Insert", "user", mock.Anything).Return("INSERT", []any{})
+	databasemock.ExpectExec(&tx.Mock, "INSERT", 1, 7)
+	queryBuilder.On("Get", "user", mock.MatchedBy(
+		func(options *database.GetOptions) bool {
+			return options.Selectors[0].Column == "id" &&
+				options.Selectors[0].Value == int64(7)
+		},
+	)).Return("SELECT", []any{})
+	expectUserRows(&tx.Mock, "SELECT", 7)
+
+	user, err := database.NewReturningDBOps[*testUser]().Insert(
+		tx,
+		&testUser{Name: "Ada"},
+		&database.Returning{KeyColumn: "id"},
+		newTestUser,
+		queryBuilder,
+		nil,
+	)
+
+	assert.NoError(t, err)
+	assert.Equal(t, &testUser{ID: 7, Name: "Ada"}, user)
+}
+
+// TestReturningInsert_EmulatedWithoutTx tests that emulation requires a
+// transaction.
+func TestReturningInsert_EmulatedWithoutTx(t *testing.T) {
+	_, err := database.NewReturningDBOps[*testUser]().Insert(
+		&databasemock.MockDB{},
+		&testUser{Name: "Ada"},
+		&database.Returning{KeyColumn: "id"},
+		newTestUser,
+		&databasemock.MockQueryBuilder{},
+		nil,
+	)
+
+	assert.ErrorContains(t, err, "requires a transaction")
+}
+
+// TestReturningUpdate_Emulated tests that updated rows are selected by the
+// keys locked before the update.
+func TestReturningUpdate_Emulated(t *testing.T) {
+	queryBuilder := &databasemock.MockQueryBuilder{}
+	tx := &databasemock.MockTx{}
+	keyStmt := &databasemock.MockStmt{}
+	keyRows := &databasemock.MockRows{}
+	selectors := []database.Selector{
+		{Table: "user", Column: "name", Predicate: database.Equal, Value: "Ada"},
+	}
+	queryBuilder.On("Get", "user", mock.MatchedBy(
+		func(options *database.GetOptions) bool { return options.Lock },
+	)).Return("SELECT KEYS", []any{}).Once()
+	tx.On("Prepare", "SELECT KEYS").Return(keyStmt, nil).Once()
+	keyStmt.On("Query", mock.Anything).Return(keyRows, nil)
+	keyStmt.On("Close").Return(nil)
+	keyRows.On("Next").Return(true).Once()
+	keyRows.On("Next").Return(false)
+	keyRows.On("Scan", mock.Anything).Run(func(args mock.Arguments) {
+		*args.Get(0).([]any)[0].(*any) = int64(7)
+	}).Return(nil)
+	keyRows.On("Err").Return(nil)
+	keyRows.On("Close").Return(nil)
+	queryBuilder.On("UpdateQuery", "user", mock.Anything, selectors).
+		Return("UPDATE", []any{})
+	databasemock.ExpectExec(&tx.Mock, "UPDATE", 1, 0)
+	queryBuilder.On("Get", "user", mock.MatchedBy(
+		func(options *database.GetOptions) bool {
+			return options.Selectors[0].Predicate == database.In &&
+				assert.ObjectsAreEqual(
+					[]any{int64(7)}, options.Selectors[0].Value,
+				)
+		},
+	)).Return("SELECT", []any{}).Once()
+	expectUserRows(&tx.Mock, "SELECT", 7)
+
+	users, err := database.NewReturningDBOps[*testUser]().Update(
+		tx,
+		&testUser{},
+		selectors,
+		[]database.Update{database.NewUpdate("name", "Grace")},
+		&database.Returning{KeyColumn: "id"},
+		newTestUser,
+		queryBuilder,
+		nil,
+	)
+
+	assert.NoError(t, err)
+	assert.Len(t, users, 1)
+	queryBuilder.AssertExpectations(t)
+}
+
+// TestReturningDelete_Native tests deletes with a RETURNING clause.
+func TestReturningDelete_Native(t *testing.T) {
+	queryBuilder := returningQueryBuilder{&databasemock.MockQueryBuilder{}}
+	db := &databasemock.MockDB{}
+	queryBuilder.On("DeleteReturning", "user", mock.Anything, mock.Anything).
+		Return("DELETE RETURNING", []any{})
+	expectUserRows(&db.Mock, "DELETE RETURNING", 1, 2)
+
+	users, err := database.NewReturningDBOps[*testUser]().Delete(
+		db,
+		&testUser{},
+		nil,
+		&database.Returning{},
+		newTestUser,
+		queryBuilder,
+		nil,
+	)
+
+	assert.NoError(t, err)
+	assert.Len(t, users, 2)
+}
+
+// tableName is a table namer of the tests.
+type tableName string
+
+func (t tableName) TableName() string {
+	return string(t)
+}
+
+// TestReturningDelete_Emulated tests that emulated deletes lock the keys of
+// the matching rows and select and delete the rows by those keys.
+func TestReturningDelete_Emulated(t *testing.T) {
+	queryBuilder := &databasemock.MockQueryBuilder{}
+	tx := &databasemock.MockTx{}
+	keyStmt := &databasemock.MockStmt{}
+	keyRows := &databasemock.MockRows{}
+	selectors := []database.Selector{{
+		Table:     "archived_user",
+		Column:    "name",
+		Predicate: database.Equal,
+		Value:     "Ada",
+	}}
+	byKeys := []database.Selector{{
+		Table:     "archived_user",
+		Column:    "id",
+		Predicate: database.In,
+		Value:     []any{int64(3)},
+	}}
+	queryBuilder.On("Get", "archived_user", mock.MatchedBy(
+		func(options *database.GetOptions) bool {
+			return options.Lock &&
+				assert.ObjectsAreEqual(
+					database.Selectors(selectors), options.Selectors,
+				)
+		},
+	)).Return("SELECT KEYS", []any{}).Once()
+	tx.On("Prepare", "SELECT KEYS").Return(keyStmt, nil).Once()
+	keyStmt.On("Query", mock.Anything).Return(keyRows, nil)
+	keyStmt.On("Close").Return(nil)
+	keyRows.On("Next").Return(true).Once()
+	keyRows.On("Next").Return(false)
+	keyRows.On("Scan", mock.Anything).Run(func(args mock.Arguments) {
+		*args.Get(0).([]any)[0].(*any) = int64(3)
+	}).Return(nil)
+	keyRows.On("Err").Return(nil)
+	keyRows.On("Close").Return(nil)
+	queryBuilder.On("Get", "archived_user", mock.MatchedBy(
+		func(options *database.GetOptions) bool {
+			return assert.ObjectsAreEqual(
+				database.Selectors(byKeys), options.Selectors,
+			)
+		},
+	)).Return("SELECT", []any{}).Once()
+	expectUserRows(&tx.Mock, "SELECT", 3)
+	queryBuilder.On("Delete", "archived_user", byKeys, mock.Anything).
+		Return("DELETE", []any{})
+	databasemock.ExpectExec(&tx.Mock, "DELETE", 1, 0)
+
+	users, err := database.NewReturningDBOps[*testUser]().Delete(
+		tx,
+		tableName("archived_user"),
+		selectors,
+		&database.Returning{KeyColumn: "id"},
+		newTestUser,
+		queryBuilder,
+		nil,
+	)
+
+	assert.NoError(t, err)
+	assert.Len(t, users, 1)
+	queryBuilder.AssertExpectations(t)
+}
@@ -0,0 +1,352 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/pakkasys/fluidapi/database"
+	databasemock "github.com/pakkasys/fluidapi/database/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// testDocument is a soft deleted entity.
+type testDocument struct {
+	ID    int64
+	Title string
+}
+
+func (d *testDocument) TableName() string {
+	return "document"
+}
+
+func (d *testDocument) SoftDeleteColumn() string {
+	return "deleted_at"
+}
+
+func (d *testDocument) InsertedValues() ([]string, []any) {
+	return []string{"title"}, []any{d.Title}
+}
+
+func (d *testDocument) ScanRow(row database.Row) error {
+	return row.Scan(&d.ID, &d.Title)
+}
+
+// newTestDocument returns a new test document.
+func newTestDocument() *testDocument {
+	return &testDocument{}
+}
+
+// notDeleted is the selector excluding soft deleted documents.
+var notDeleted = database.Selector{
+	Table:     "document",
+	Column:    "deleted_at",
+	Predicate: database.Equal,
+	Value:     nil,
+}
+
+// titleSelector is a selector of the tests.
+var titleSelector = database.Selector{
+	Table:     "document",
+	Column:    "title",
+	Predicate: database.Equal,
+	Value:     "draft",
+}
+
+// isSoftDelete matches updates setting the deleted_at column to the
+// database clock.
+func isSoftDelete(updates []database.Update) bool {
+	return len(updates) == 1 && updates[0].Field == "deleted_at" &&
+		assert.ObjectsAreEqual(
+			database.Func(database.FuncNow), updates[0].Value,
+		)
+}
+
+// TestGetMany_ExcludesSoftDeleted tests that soft deleted rows are excluded
+// without modifying the options.
+func TestGetMany_ExcludesSoftDeleted(t *testing.T) {
+	queryBuilder := &databasemock.MockQueryBuilder{}
+	db := &databasemock.MockDB{}
+	options := &database.GetOptions{
+		Selectors: database.Selectors{titleSelector},
+	}
+	queryBuilder.On("Get", "document", mock.MatchedBy(
+		func(o *database.GetOptions) bool {
+			return assert.ObjectsAreEqual(
+				database.Selectors{titleSelector, notDeleted}, o.Selectors,
+			)
+		},
+	)).Return("SELECT", []any{})
+	expectUserRows(&db.Mock, "SELECT")
+
+	documents, err := database.NewReadDBOps[*testDocument]().GetMany(
+		db, options, newTestDocument, queryBuilder, nil,
+	)
+
+	assert.NoError(t, err)
+	assert.Empty(t, documents)
+	assert.Equal(t, database.Selectors{titleSelector}, options.Selectors)
+	queryBuilder.AssertExpectations(t)
+}
+
+// TestGetMany_IncludeDeleted tests that soft deleted rows can be included.
+func TestGetMany_IncludeDeleted(t *testing.T) {
+	queryBuilder := &databasemock.MockQueryBuilder{}
+	db := &databasemock.MockDB{}
+	options := &database.GetOptions{
+		Selectors:      database.Selectors{titleSelector},
+		IncludeDeleted: true,
+	}
+	queryBuilder.On("Get", "document", options).Return("SELECT", []any{})
+	expectUserRows(&db.Mock, "SELECT")
+
+	_, err := database.NewReadDBOps[*testDocument]().GetMany(
+		db, options, newTestDocument, queryBuilder, nil,
+	)
+
+	assert.NoError(t, err)
+	queryBuilder.AssertExpectations(t)
+}
+
+// TestGet_FromOtherSource tests that no soft delete selector is added when
+// the rows are selected from another source than the entity table.
+func TestGet_FromOtherSource(t *testing.T) {
+	queryBuilder := &databasemock.MockQueryBuilder{}
+	db := &databasemock.MockDB{}
+	stmt := &databasemock.MockStmt{}
+	row := &databasemock.MockRow{}
+	queryBuilder.On("Get", "recent", mock.MatchedBy(
+		func(o *database.GetOptions) bool {
+			return len(o.Selectors) == 0
+		},
+	)).Return("SELECT", []any{})
+	db.On("Prepare", "SELECT").Return(stmt, nil)
+	stmt.On("QueryRow", mock.Anything).Return(row)
+	stmt.On("Close").Return(nil)
+	row.On("Scan", mock.Anything).Return(nil)
+	row.On("Err").Return(nil)
+
+	_, err := database.NewReadDBOps[*testDocument]().Get(
+		db,
+		&database.GetOptions{From: "recent"},
+		newTestDocument,
+		queryBuilder,
+		nil,
+	)
+
+	assert.NoError(t, err)
+	queryBuilder.AssertExpectations(t)
+}
+
+// TestCount_ExcludesSoftDeleted tests that counts exclude soft deleted rows.
+func TestCount_ExcludesSoftDeleted(t *testing.T) {
+	queryBuilder := &databasemock.MockQueryBuilder{}
+	db := &databasemock.MockDB{}
+	stmt := &databasemock.MockStmt{}
+	row := &databasemock.MockRow{}
+	queryBuilder.On("Count", "document", &database.CountOptions{
+		Selectors: database.Selectors{notDeleted},
+	}).Return("COUNT", []any{})
+	db.On("Prepare", "COUNT").Return(stmt, nil)
+	stmt.On("QueryRow", mock.Anything).Return(row)
+	stmt.On("Close").Return(nil)
+	row.On("Scan", mock.Anything).Run(func(args mock.Arguments) {
+		*args.Get(0).([]any)[0].(*int) = 3
+	}).Return(nil)
+
+	count, err := database.NewReadDBOps[*testDocument]().Count(
+		db, &database.CountOptions{}, newTestDocument, queryBuilder, nil,
+	)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+}
+
+// TestCount_PrepareErrorWithoutChecker tests that prepare errors are returned
+// without an error checker.
+func TestCount_PrepareErrorWithoutChecker(t *testing.T) {
+	queryBuilder := &databasemock.MockQueryBuilder{}
+	db := &databasemock.MockDB{}
+	queryBuilder.On("Count", "document", mock.Anything).
+		Return("COUNT", []any{})
+	db.On("Prepare", "COUNT").Return(nil, assert.AnError)
+
+	_, err := database.NewReadDBOps[*testDocument]().Count(
+		db, &database.CountOptions{}, newTestDocument, queryBuilder, nil,
+	)
+
+	assert.ErrorIs(t, err, assert.AnError)
+}
+
+// TestUpdate_ExcludesSoftDeleted tests that soft deleted rows are not
+// updated.
+func TestUpdate_ExcludesSoftDeleted(t *testing.T) {
+	queryBuilder := &databasemock.MockQueryBuilder{}
+	db := &databasemock.MockDB{}
+	updates := []database.Update{database.NewUpdate("title", "final")}
+	queryBuilder.On(
+		"UpdateQuery",
+		"document",
+		updates,
+		[]database.Selector{titleSelector, notDeleted},
+	).Return("UPDATE", []any{})
+	databasemock.ExpectExec(&db.Mock, "UPDATE", 2, 0)
+
+	count, err := database.NewMutateDBOps[*testDocument]().Update(
+		db,
+		&testDocument{},
+		[]database.Selector{titleSelector},
+		updates,
+		queryBuilder,
+		nil,
+	)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+}
+
+// TestDelete_SoftDelete tests that deletes set the soft delete column to the
+// database clock.
+func TestDelete_SoftDelete(t *testing.T) {
+	queryBuilder := &databasemock.MockQueryBuilder{}
+	db := &databasemock.MockDB{}
+	queryBuilder.On("Function", database.FuncNow, []string{}).
+		Return("NOW()", true)
+	queryBuilder.On(
+		"UpdateQuery",
+		"document",
+		mock.MatchedBy(isSoftDelete),
+		[]database.Selector{titleSelector, notDeleted},
+	).Return("UPDATE", []any{})
+	databasemock.ExpectExec(&db.Mock, "UPDATE", 1, 0)
+
+	count, err := database.NewMutateDBOps[*testDocument]().Delete(
+		db,
+		&testDocument{},
+		[]database.Selector{titleSelector},
+		&database.DeleteOptions{},
+		queryBuilder,
+		nil,
+	)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+	queryBuilder.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything,
+		mock.Anything)
+}
+
+// TestDelete_SoftDeleteLimit tests that soft deletes reject limits.
+func TestDelete_SoftDeleteLimit(t *testing.T) {
+	queryBuilder := &databasemock.MockQueryBuilder{}
+	db := &databasemock.MockDB{}
+
+	_, err := database.NewMutateDBOps[*testDocument]().Delete(
+		db,
+		&testDocument{},
+		nil,
+		&database.DeleteOptions{Limit: 1},
+		queryBuilder,
+		nil,
+	)
+
+	assert.EqualError(
+		t, err, "Delete: soft delete does not support limit and orders",
+	)
+}
+
+// TestHardDelete tests that hard deletes remove soft deleted rows.
+func TestHardDelete(t *testing.T) {
+	queryBuilder := &databasemock.MockQueryBuilder{}
+	db := &databasemock.MockDB{}
+	opts := &database.DeleteOptions{Limit: 10}
+	queryBuilder.On(
+		"Delete", "document", []database.Selector{titleSelector}, opts,
+	).Return("DELETE", []any{})
+	databasemock.ExpectExec(&db.Mock, "DELETE", 4, 0)
+
+	count, err := database.NewMutateDBOps[*testDocument]().HardDelete(
+		db,
+		&testDocument{},
+		[]database.Selector{titleSelector},
+		opts,
+		queryBuilder,
+		nil,
+	)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), count)
+}
+
+// TestRestore tests that restores clear the soft delete column of deleted
+// rows.
+func TestRestore(t *testing.T) {
+	queryBuilder := &databasemock.MockQueryBuilder{}
+	db := &databasemock.MockDB{}
+	queryBuilder.On(
+		"UpdateQuery",
+		"document",
+		[]database.Update{database.NewUpdate("deleted_at", nil)},
+		[]database.Selector{titleSelector, {
+			Table:     "document",
+			Column:    "deleted_at",
+			Predicate: database.NotEqual,
+			Value:     nil,
+		}},
+	).Return("UPDATE", []any{})
+	databasemock.ExpectExec(&db.Mock, "UPDATE", 1, 0)
+
+	count, err := database.NewMutateDBOps[*testDocument]().Restore(
+		db,
+		&testDocument{},
+		[]database.Selector{titleSelector},
+		queryBuilder,
+		nil,
+	)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+}
+
+// TestRestore_NotSoftDeleted tests that only soft deleted entities can be
+// restored.
+func TestRestore_NotSoftDeleted(t *testing.T) {
+	_, err := database.NewMutateDBOps[*testUser]().Restore(
+		&databasemock.MockDB{},
+		&testUser{},
+		nil,
+		&databasemock.MockQueryBuilder{},
+		nil,
+	)
+
+	assert.EqualError(t, err, "Restore: entity is not soft deleted")
+}
+
+// TestReturningDelete_SoftDelete tests that deletes with RETURNING soft
+// delete the rows.
+func TestReturningDelete_SoftDelete(t *testing.T) {
+	queryBuilder := returningQueryBuilder{&databasemock.MockQueryBuilder{}}
+	db := &databasemock.MockDB{}
+	queryBuilder.On("Function", database.FuncNow, []string{}).
+		Return("NOW()", true)
+	queryBuilder.On(
+		"UpdateReturning",
+		"document",
+		mock.MatchedBy(isSoftDelete),
+		[]database.Selector{titleSelector, notDeleted},
+		database.Projections(nil),
+	).Return("UPDATE RETURNING", []any{})
+	expectUserRows(&db.Mock, "UPDATE RETURNING", 5)
+
+	deleted, err := database.NewReturningDBOps[*testDocument]().Delete(
+		db,
+		&testDocument{},
+		[]database.Selector{titleSelector},
+		&database.Returning{},
+		newTestDocument,
+		queryBuilder,
+		nil,
+	)
+
+	assert.NoError(t, err)
+	assert.Len(t, deleted, 1)
+	assert.Equal(t, int64(5), deleted[0].ID)
+}
@@ -0,0 +1,176 @@
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pakkasys/fluidapi/database"
+	databasemock "github.com/pakkasys/fluidapi/database/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// testSubqueryDialect is a test dialect that supports subqueries.
+type testSubqueryDialect struct {
+	testDialect
+}
+
+func (d testSubqueryDialect) BuildSelect(
+	table string, options *database.GetOptions, params []any,
+) (string, []any, error) {
+	columns := []string{"1"}
+	if len(options.Projections) > 0 {
+		columns = nil
+		for _, projection := range options.Projections {
+			sql, err := database.BuildProjection(d, projection)
+			if err != nil {
+				return "", nil, err
+			}
+			columns = append(columns, sql)
+		}
+	}
+	sql := "SELECT " + strings.Join(columns, ", ") + ` FROM "` + table + `"`
+	if len(options.Selectors) > 0 {
+		where, newParams, err := database.BuildSelectors(
+			d, options.Selectors, params,
+		)
+		if err != nil {
+			return "", nil, err
+		}
+		sql += " WHERE " + where
+		params = newParams
+	}
+	return sql, params, nil
+}
+
+// TestBuildSelectors_Exists tests a correlated EXISTS selector with parameters
+// numbered in statement order.
+func TestBuildSelectors_Exists(t *testing.T) {
+	selectors := database.Selectors{
+		{Table: "user", Column: "name", Predicate: database.Equal, Value: "a"},
+		database.NewExistsSelector(database.NewSubquery("order", &database.GetOptions{
+			Selectors: database.Selectors{
+				{
+					Table:     "order",
+					Column:    "user_id",
+					Predicate: database.Equal,
+					Value:     database.ColumnExpr{Table: "user", Column: "id"},
+				},
+				{
+					Table:     "order",
+					Column:    "status",
+					Predicate: database.Equal,
+					Value:     "active",
+				},
+			},
+		})),
+		{Table: "user", Column: "age", Predicate: database.Greater, Value: 30},
+	}
+
+	sql, params, err := database.BuildSelectors(
+		testSubqueryDialect{}, selectors, nil,
+	)
+
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		`"user"."name" = $1 AND EXISTS (SELECT 1 FROM "order" WHERE `+
+			`"order"."user_id" = "user"."id" AND "order"."status" = $2) `+
+			`AND "user"."age" > $3`,
+		sql,
+	)
+	assert.Equal(t, []any{"a", "active", 30}, params)
+}
+
+// TestBuildSelectors_InSubquery tests an IN selector with a subquery value.
+func TestBuildSelectors_InSubquery(t *testing.T) {
+	subquery := database.NewSubquery("order", &database.GetOptions{
+		Projections: database.Projections{{Table: "order", Column: "user_id"}},
+		Selectors: database.Selectors{
+			{Table: "order", Column: "total", Predicate: database.Greater, Value: 100},
+		},
+	})
+	selectors := database.Selectors{
+		{Table: "user", Column: "id", Predicate: database.NotIn, Value: subquery},
+	}
+
+	sql, params, err := database.BuildSelectors(
+		testSubqueryDialect{}, selectors, nil,
+	)
+
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		`"user"."id" NOT IN (SELECT "order"."user_id" FROM "order" WHERE `+
+			`"order"."total" > $1)`,
+		sql,
+	)
+	assert.Equal(t, []any{100}, params)
+}
+
+// TestBuildSelectors_SubqueryNotSupported tests that dialects without
+// subquery support return an error.
+func TestBuildSelectors_SubqueryNotSupported(t *testing.T) {
+	selectors := database.Selectors{
+		database.NewNotExistsSelector(database.NewSubquery("order", nil)),
+	}
+
+	_, _, err := database.BuildSelectors(testDialect{}, selectors, nil)
+
+	assert.Error(t, err)
+}
+
+// subqueryQueryBuilder is a query builder that supports subqueries but does
+// not render joins with BuildJoin.
+type subqueryQueryBuilder struct {
+	*databasemock.MockQueryBuilder
+}
+
+func (subqueryQueryBuilder) BuildSelect(
+	table string, options *database.GetOptions, params []any,
+) (string, []any, error) {
+	return "SELECT 1 FROM " + table, params, nil
+}
+
+// TestGet_UnsupportedSubquery tests that Get returns an
+// UnsupportedQueryError if the query builder does not support subqueries.
+func TestGet_UnsupportedSubquery(t *testing.T) {
+	db := &databasemock.MockDB{}
+	options := &database.GetOptions{Selectors: database.Selectors{
+		database.NewExistsSelector(database.NewSubquery("order", nil)),
+	}}
+
+	_, err := database.NewReadDBOps[*testUser]().Get(
+		db, options, newTestUser, &databasemock.MockQueryBuilder{}, nil,
+	)
+
+	var queryErr *database.UnsupportedQueryError
+	assert.ErrorAs(t, err, &queryErr)
+	assert.Equal(t, database.FeatureSubquery, queryErr.Feature)
+	db.AssertNotCalled(t, "Prepare", mock.Anything)
+}
+
+// TestGet_UnsupportedJoinInSubquery tests that the options of subqueries are
+// checked as well.
+func TestGet_UnsupportedJoinInSubquery(t *testing.T) {
+	db := &databasemock.MockDB{}
+	queryBuilder := subqueryQueryBuilder{&databasemock.MockQueryBuilder{}}
+	options := &database.GetOptions{Selectors: database.Selectors{
+		database.NewExistsSelector(database.NewSubquery(
+			"order",
+			&database.GetOptions{Joins: database.Joins{{
+				Type:  database.JoinTypeCross,
+				Table: "product",
+			}}},
+		)),
+	}}
+
+	_, err := database.NewReadDBOps[*testUser]().Get(
+		db, options, newTestUser, queryBuilder, nil,
+	)
+
+	var joinErr *database.UnsupportedJoinError
+	assert.ErrorAs(t, err, &joinErr)
+	assert.Equal(t, database.JoinTypeCross, joinErr.Type)
+	db.AssertNotCalled(t, "Prepare", mock.Anything)
+}
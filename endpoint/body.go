@@ -0,0 +1,262 @@
+package endpoint
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/pakkasys/fluidapi/core"
+)
+
+// RequestTooLargeErrorData is the data for the RequestTooLargeError error.
+type RequestTooLargeErrorData struct {
+	MaxBytes int64 `json:"max_bytes"`
+}
+
+// RequestTooLargeError is returned when a request body exceeds the maximum
+// size.
+var RequestTooLargeError = core.NewAPIError("REQUEST_TOO_LARGE")
+
+// InvalidJSONErrorData is the data for the InvalidJSONError error.
+type InvalidJSONErrorData struct {
+	Offset       int64  `json:"offset,omitempty"`
+	MaxDepth     int    `json:"max_depth,omitempty"`
+	UnknownField string `json:"unknown_field,omitempty"`
+}
+
+// InvalidJSONError is returned when a request body is not valid JSON or it
+// violates the decoding limits of the endpoint.
+var InvalidJSONError = core.NewAPIError("INVALID_JSON")
+
+// BodyLimits holds the request body limits of an endpoint.
+type BodyLimits struct {
+	MaxBytes              int64 // Max body size in bytes, zero disables.
+	MaxDepth              int   // Max JSON nesting depth, zero disables.
+	DisallowUnknownFields bool  // Reject unknown fields when decoding JSON.
+}
+
+// bodyLimitsKey is the context key for the body limits.
+type bodyLimitsKey struct{}
+
+// BodyLimitsMiddleware returns a middleware that enforces the given body
+// limits. Bodies whose Content-Length exceeds the maximum size are rejected
+// with a RequestTooLargeError before the next handler runs, and other bodies
+// fail with it when they are read past the maximum size. If a maximum depth
+// is set, JSON bodies are scanned token by token before the next handler
+// runs and rejected with an InvalidJSONError at the first token nested too
+// deep or the first syntax error. The scanned body, at most the maximum size,
+// is kept for the next handler. The limits are stored in the request
+// context, so that DecodeJSON can apply the unknown field policy.
+//
+// Parameters:
+//   - limits: The body limits to enforce.
+//
+// Returns:
+//   - core.Middleware: The body limits middleware.
+func BodyLimitsMiddleware(limits BodyLimits) core.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Body != nil && limits.MaxBytes > 0 {
+				if r.ContentLength > limits.MaxBytes {
+					WriteError(w, requestTooLargeError(limits.MaxBytes))
+					return
+				}
+				r.Body = http.MaxBytesReader(w, r.Body, limits.MaxBytes)
+			}
+			if r.Body != nil && limits.MaxDepth > 0 && isJSONRequest(r) {
+				body, err := scanJSON(r.Body, limits.MaxDepth)
+				if err != nil {
+					WriteError(w, err)
+					return
+				}
+				r.Body = io.NopCloser(body)
+			}
+			ctx := context.WithValue(r.Context(), bodyLimitsKey{}, limits)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// DecodeJSON decodes the JSON request body into dst. If the request has
+// passed through BodyLimitsMiddleware, its nesting depth and unknown field
+// policy are applied while the body is streamed. Decoding failures are
+// returned as InvalidJSONError, or RequestTooLargeError if the body exceeds
+// the maximum size.
+//
+// Parameters:
+//   - r: The HTTP request.
+//   - dst: The destination to decode into.
+//
+// Returns:
+//   - error: An error if the body cannot be decoded.
+func DecodeJSON(r *http.Request, dst any) error {
+	var reader io.Reader = r.Body
+	limits, _ := r.Context().Value(bodyLimitsKey{}).(BodyLimits)
+	if limits.MaxDepth > 0 {
+		reader = &depthLimitReader{reader: r.Body, maxDepth: limits.MaxDepth}
+	}
+	decoder := json.NewDecoder(reader)
+	if limits.DisallowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+	if err := decoder.Decode(dst); err != nil {
+		return jsonDecodeError(err)
+	}
+	return nil
+}
+
+// scanJSON reads the JSON body token by token and returns a reader that
+// replays it. Reading stops at the first token nested deeper than the maximum
+// depth or at the first syntax error.
+func scanJSON(body io.Reader, maxDepth int) (io.Reader, *core.APIError) {
+	var buffer bytes.Buffer
+	decoder := json.NewDecoder(io.TeeReader(body, &buffer))
+	depth := 0
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return &buffer, nil
+		}
+		if err != nil {
+			return nil, jsonDecodeError(err)
+		}
+		switch token {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+			if depth > maxDepth {
+				return nil, jsonDecodeError(&jsonDepthError{
+					offset:   decoder.InputOffset(),
+					maxDepth: maxDepth,
+				})
+			}
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+	}
+}
+
+// isJSONRequest reports whether the request has a JSON content type.
+func isJSONRequest(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" ||
+		strings.HasSuffix(mediaType, "+json")
+}
+
+// jsonDepthError is returned by depthLimitReader when the nesting depth of
+// the JSON stream exceeds the maximum.
+type jsonDepthError struct {
+	offset   int64
+	maxDepth int
+}
+
+func (e *jsonDepthError) Error() string {
+	return fmt.Sprintf("JSON nesting depth exceeds %d", e.maxDepth)
+}
+
+// depthLimitReader tracks the nesting depth of the JSON stream it reads and
+// fails when the depth exceeds the maximum, so that the depth is checked
+// without buffering the body. Brackets in strings are ignored. Syntax errors
+// are left to the decoder.
+type depthLimitReader struct {
+	reader   io.Reader
+	maxDepth int
+	depth    int
+	offset   int64
+	inString bool
+	escaped  bool
+	err      error
+}
+
+func (d *depthLimitReader) Read(p []byte) (int, error) {
+	// The error is returned without data, as decoders may ignore errors
+	// returned together with data.
+	if d.err != nil {
+		return 0, d.err
+	}
+	n, err := d.reader.Read(p)
+	for i, b := range p[:n] {
+		switch {
+		case d.escaped:
+			d.escaped = false
+		case d.inString:
+			switch b {
+			case '\\':
+				d.escaped = true
+			case '"':
+				d.inString = false
+			}
+		case b == '"':
+			d.inString = true
+		case b == '{' || b == '[':
+			d.depth++
+			if d.depth > d.maxDepth {
+				d.err = &jsonDepthError{
+					offset:   d.offset + int64(i) + 1,
+					maxDepth: d.maxDepth,
+				}
+				return i, nil
+			}
+		case b == '}' || b == ']':
+			d.depth--
+		}
+	}
+	d.offset += int64(n)
+	return n, err
+}
+
+// requestTooLargeError returns an error for a body exceeding the maximum
+// size.
+func requestTooLargeError(maxBytes int64) *core.APIError {
+	return RequestTooLargeError.
+		WithData(RequestTooLargeErrorData{MaxBytes: maxBytes}).
+		WithMessage(fmt.Sprintf("request body exceeds %d bytes", maxBytes))
+}
+
+// jsonDecodeError translates a JSON decoding error into an InvalidJSONError.
+func jsonDecodeError(err error) *core.APIError {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var maxBytesErr *http.MaxBytesError
+	var depthErr *jsonDepthError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return requestTooLargeError(maxBytesErr.Limit)
+	case errors.As(err, &depthErr):
+		return InvalidJSONError.
+			WithData(InvalidJSONErrorData{
+				Offset:   depthErr.offset,
+				MaxDepth: depthErr.maxDepth,
+			}).
+			WithMessage(depthErr.Error())
+	case errors.As(err, &syntaxErr):
+		return InvalidJSONError.
+			WithData(InvalidJSONErrorData{Offset: syntaxErr.Offset}).
+			WithMessage(syntaxErr.Error())
+	case errors.As(err, &typeErr):
+		return InvalidJSONError.
+			WithData(InvalidJSONErrorData{Offset: typeErr.Offset}).
+			WithMessage(fmt.Sprintf(
+				"invalid type for field %s: %s", typeErr.Field, typeErr.Value,
+			))
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(
+			strings.TrimPrefix(err.Error(), "json: unknown field "), `"`,
+		)
+		return InvalidJSONError.
+			WithData(InvalidJSONErrorData{UnknownField: field}).
+			WithMessage(fmt.Sprintf("unknown field: %s", field))
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return InvalidJSONError.WithMessage("unexpected end of JSON input")
+	default:
+		return InvalidJSONError.WithMessage(err.Error())
+	}
+}
@@ -0,0 +1,256 @@
+package endpoint
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/pakkasys/fluidapi/core"
+	"github.com/pakkasys/fluidapi/database"
+)
+
+// InvalidCursorError is returned when a cursor is malformed, has been
+// tampered with or does not belong to the requested orders.
+var InvalidCursorError = core.NewAPIError("INVALID_CURSOR")
+
+// Cursor is the position of a keyset pagination page.
+type Cursor struct {
+	Values   []any  `json:"v"`           // Order values of the boundary row.
+	Backward bool   `json:"b,omitempty"` // Whether to page backward.
+	Orders   string `json:"o"`           // Signature of the orders.
+}
+
+// CursorCodec encodes and decodes opaque cursors. Cursors are signed with
+// HMAC-SHA256, so that clients cannot forge or modify them.
+type CursorCodec struct {
+	secret []byte
+}
+
+// MinCursorSecretSize is the minimum size of a cursor secret in bytes, the
+// size of a SHA-256 hash.
+const MinCursorSecretSize = 32
+
+// NewCursorCodec creates a new cursor codec. It panics if the secret is
+// shorter than MinCursorSecretSize, as short secrets make cursor signatures
+// forgeable.
+//
+// Parameters:
+//   - secret: The secret key used to sign cursors.
+//
+// Returns:
+//   - *CursorCodec: A new cursor codec.
+func NewCursorCodec(secret []byte) *CursorCodec {
+	if len(secret) < MinCursorSecretSize {
+		panic(fmt.Sprintf(
+			"NewCursorCodec: secret must be at least %d bytes, got %d",
+			MinCursorSecretSize, len(secret),
+		))
+	}
+	return &CursorCodec{secret: bytes.Clone(secret)}
+}
+
+// Encode encodes a cursor into an opaque string.
+//
+// Parameters:
+//   - cursor: The cursor to encode.
+//
+// Returns:
+//   - string: The encoded cursor.
+//   - error: An error if the cursor cannot be encoded.
+func (c *CursorCodec) Encode(cursor Cursor) (string, error) {
+	payload, err := json.Marshal(cursor)
+	if err != nil {
+		return "", fmt.Errorf("Encode: %w", err)
+	}
+	encoding := base64.RawURLEncoding
+	return encoding.EncodeToString(payload) + "." +
+		encoding.EncodeToString(c.sign(payload)), nil
+}
+
+// Decode decodes and verifies an opaque cursor string. Numeric values are
+// decoded as json.Number.
+//
+// Parameters:
+//   - encoded: The encoded cursor.
+//
+// Returns:
+//   - *Cursor: The decoded cursor.
+//   - error: An InvalidCursorError if the cursor is invalid.
+func (c *CursorCodec) Decode(encoded string) (*Cursor, error) {
+	encoding := base64.RawURLEncoding
+	payloadPart, signaturePart, ok := strings.Cut(encoded, ".")
+	if !ok {
+		return nil, InvalidCursorError.WithMessage("malformed cursor")
+	}
+	payload, err := encoding.DecodeString(payloadPart)
+	if err != nil {
+		return nil, InvalidCursorError.WithMessage("malformed cursor")
+	}
+	signature, err := encoding.DecodeString(signaturePart)
+	if err != nil || !hmac.Equal(signature, c.sign(payload)) {
+		return nil, InvalidCursorError.WithMessage("invalid cursor signature")
+	}
+	// Numbers are decoded as json.Number, so that integers beyond the range
+	// of float64 keep their exact value.
+	var cursor Cursor
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	if err := decoder.Decode(&cursor); err != nil {
+		return nil, InvalidCursorError.WithMessage("malformed cursor")
+	}
+	return &cursor, nil
+}
+
+// ToDBSeek translates the cursor of the page into a database seek and page.
+// The cursor must have been created for the same orders. The cursor values
+// are coerced into the types of their fields. The returned page limit is one
+// larger than the page limit, so that NewCursorResult can detect whether more
+// rows follow. If the page has no cursor, the seek is nil.
+//
+// Parameters:
+//   - page: The requested page.
+//   - orders: The orders of the query, including tiebreakers.
+//   - apiToDBFieldMap: The mapping of API field names to database fields.
+//
+// Returns:
+//   - *database.Seek: The database seek, or nil if there is no cursor.
+//   - *database.Page: The database page.
+//   - error: An InvalidCursorError if the cursor is invalid.
+func (c *CursorCodec) ToDBSeek(
+	page *Page, orders Orders, apiToDBFieldMap map[string]DBField,
+) (*database.Seek, *database.Page, error) {
+	if page == nil {
+		return nil, nil, nil
+	}
+	dbPage := &database.Page{Limit: page.Limit + 1}
+	if page.Cursor == "" {
+		return nil, dbPage, nil
+	}
+
+	cursor, err := c.Decode(page.Cursor)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cursor.Orders != ordersSignature(orders) ||
+		len(cursor.Values) != len(orders) {
+		return nil, nil, InvalidCursorError.WithMessage(
+			"cursor does not match the requested orders",
+		)
+	}
+
+	values := make([]any, len(cursor.Values))
+	for i, order := range orders {
+		value, ok := coerceValue(
+			cursor.Values[i], apiToDBFieldMap[order.Field].Type,
+		)
+		if !ok || value == nil {
+			return nil, nil, InvalidCursorError.WithMessage(
+				fmt.Sprintf("invalid cursor value for %s", order.Field),
+			)
+		}
+		values[i] = value
+	}
+	return database.NewSeek(values, cursor.Backward), dbPage, nil
+}
+
+// CursorResult is a page of items with cursors to the adjacent pages.
+type CursorResult[T any] struct {
+	Items []T     `json:"items"`
+	Next  *string `json:"next,omitempty"`
+	Prev  *string `json:"prev,omitempty"`
+}
+
+// NewCursorResult creates a cursor result from rows fetched with the seek and
+// page returned by ToDBSeek. Rows of backward seeks are reversed back into
+// the requested order.
+//
+// Parameters:
+//   - codec: The cursor codec.
+//   - rows: The fetched rows.
+//   - limit: The requested page limit.
+//   - seek: The seek used to fetch the rows, or nil for the first page.
+//   - orders: The orders of the query, including tiebreakers.
+//   - keyFn: A function returning the order values of a row, in the order of
+//     the orders.
+//
+// Returns:
+//   - *CursorResult[T]: The cursor result.
+//   - error: An error if the cursors cannot be encoded.
+func NewCursorResult[T any](
+	codec *CursorCodec,
+	rows []T,
+	limit int,
+	seek *database.Seek,
+	orders Orders,
+	keyFn func(row T) []any,
+) (*CursorResult[T], error) {
+	hasMore := len(rows) > limit
+	if hasMore {
+		rows = rows[:limit]
+	}
+	backward := seek != nil && seek.Backward
+	if backward {
+		slices.Reverse(rows)
+	}
+
+	hasNext, hasPrev := hasMore, seek != nil
+	if backward {
+		hasNext, hasPrev = true, hasMore
+	}
+
+	result := &CursorResult[T]{Items: rows}
+	if len(rows) == 0 {
+		return result, nil
+	}
+	signature := ordersSignature(orders)
+	if hasNext {
+		next, err := codec.Encode(Cursor{
+			Values: keyFn(rows[len(rows)-1]),
+			Orders: signature,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("NewCursorResult: %w", err)
+		}
+		result.Next = &next
+	}
+	if hasPrev {
+		prev, err := codec.Encode(Cursor{
+			Values:   keyFn(rows[0]),
+			Backward: true,
+			Orders:   signature,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("NewCursorResult: %w", err)
+		}
+		result.Prev = &prev
+	}
+	return result, nil
+}
+
+// sign returns the HMAC-SHA256 signature of the payload.
+func (c *CursorCodec) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// ordersSignature returns a string identifying the orders, so that cursors
+// cannot be used with different orders.
+func ordersSignature(orders Orders) string {
+	parts := make([]string, len(orders))
+	for i, order := range orders {
+		direction := strings.ToLower(string(order.Direction))
+		if DirectionsToDB[OrderDirection(direction)] == database.OrderDesc {
+			direction = "-"
+		} else {
+			direction = "+"
+		}
+		parts[i] = direction + order.Field
+	}
+	return strings.Join(parts, ",")
+}
@@ -2,6 +2,7 @@ package endpoint
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/pakkasys/fluidapi/core"
 )
@@ -12,6 +13,9 @@ type Definition struct {
 	Method  string
 	Stack   *Stack
 	Handler http.HandlerFunc // Optional handler for the endpoint.
+	Timeout time.Duration    // Optional request timeout, zero disables.
+	// Optional request body limits enforced before the handler runs.
+	BodyLimits *BodyLimits
 }
 
 // NewDefinition creates a new endpoint definition.
@@ -63,6 +67,10 @@ func (d *Definition) Clone(options ...Option) *Definition {
 	if d.Stack != nil {
 		cloned.Stack = d.Stack.Clone()
 	}
+	if d.BodyLimits != nil {
+		limits := *d.BodyLimits
+		cloned.BodyLimits = &limits
+	}
 	for _, option := range options {
 		option(&cloned)
 	}
@@ -100,6 +108,33 @@ func WithMethod(method string) Option {
 	}
 }
 
+// WithTimeout returns an option that sets the request timeout of the endpoint.
+//
+// Parameters:
+//   - timeout: The request timeout of the endpoint.
+//
+// Returns:
+//   - func(*Definition): a function that sets the request timeout.
+func WithTimeout(timeout time.Duration) Option {
+	return func(e *Definition) {
+		e.Timeout = timeout
+	}
+}
+
+// WithBodyLimits returns an option that sets the request body limits of the
+// endpoint.
+//
+// Parameters:
+//   - limits: The request body limits of the endpoint.
+//
+// Returns:
+//   - func(*Definition): a function that sets the request body limits.
+func WithBodyLimits(limits BodyLimits) Option {
+	return func(e *Definition) {
+		e.BodyLimits = &limits
+	}
+}
+
 // WithMiddlewareStack return an option that sets the middleware stack.
 //
 // Parameters:
@@ -158,11 +193,17 @@ func (d Definitions) ToEndpoints() []core.Endpoint {
 				middlewares = append(middlewares, mw.Middleware)
 			}
 		}
+		if definition.BodyLimits != nil {
+			// Enforce the body limits right before the handler.
+			middlewares = append(
+				middlewares, BodyLimitsMiddleware(*definition.BodyLimits),
+			)
+		}
 		endpoints = append(
 			endpoints,
 			*core.NewEndpoint(
 				definition.URL, definition.Method, middlewares,
-			).WithHandler(definition.Handler),
+			).WithHandler(definition.Handler).WithTimeout(definition.Timeout),
 		)
 	}
 	return endpoints
@@ -0,0 +1,461 @@
+package endpoint
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"mime"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pakkasys/fluidapi/core"
+	"github.com/pakkasys/fluidapi/database"
+)
+
+// FormatParam is the query parameter selecting the format of an export. It
+// takes precedence over the Accept header.
+const FormatParam = "format"
+
+// Default flush limits of exports.
+const (
+	DefaultExportFlushRows     = 500
+	DefaultExportFlushInterval = time.Second
+)
+
+// UnsupportedFormatErrorData is the data for the UnsupportedFormatError
+// error.
+type UnsupportedFormatErrorData struct {
+	Format string `json:"format"`
+}
+
+// UnsupportedFormatError is returned when none of the requested formats of an
+// export are supported.
+var UnsupportedFormatError = core.NewAPIError("UNSUPPORTED_FORMAT")
+
+// streamMediaTypes maps media types of the Accept header to stream formats.
+var streamMediaTypes = map[string]StreamFormat{
+	"text/csv":             StreamCSV,
+	"application/x-ndjson": StreamNDJSON,
+	"application/ndjson":   StreamNDJSON,
+	"application/json":     StreamJSON,
+}
+
+// ExportQuery is an export request translated into database terms.
+type ExportQuery struct {
+	Format StreamFormat
+	// Fields are the exported API fields, in the order of the CSV columns.
+	Fields Fields
+	// Sparse reports whether the client requested the fields, in which case
+	// the projections select only them.
+	Sparse      bool
+	Selectors   []database.Selector
+	Orders      []database.Order
+	Projections database.Projections
+}
+
+// GetOptions returns the database get options of the export. Exports are not
+// paginated.
+//
+// Returns:
+//   - *database.GetOptions: The get options.
+func (q *ExportQuery) GetOptions() *database.GetOptions {
+	return &database.GetOptions{
+		Selectors:   q.Selectors,
+		Orders:      q.Orders,
+		Projections: q.Projections,
+	}
+}
+
+// Exporter streams list results as CSV, NDJSON or JSON exports. Requests are
+// parsed like list requests, so exports support the same filters, sorts and
+// fieldsets, but pagination parameters are ignored and all matching rows are
+// exported.
+type Exporter struct {
+	Parser   *ListParser
+	FieldMap map[string]DBField
+	// Formats are the allowed formats. The first one is used if the client
+	// accepts any format. If empty, CSV and NDJSON are allowed.
+	Formats []StreamFormat
+	// Filename is the base name of the downloaded file. If set, the response
+	// is sent as an attachment with the format as extension.
+	Filename string
+	// FlushRows is the number of rows after which the response is flushed.
+	// If zero, DefaultExportFlushRows is used.
+	FlushRows int
+	// FlushInterval is the time after which buffered rows are flushed. If
+	// zero, DefaultExportFlushInterval is used.
+	FlushInterval time.Duration
+	// OnError is called with errors that occur after the response has
+	// started, which cannot be written to the client anymore.
+	OnError func(r *http.Request, err error)
+}
+
+// NewExporter creates a new exporter.
+//
+// Parameters:
+//   - parser: The list parser of the list endpoint.
+//   - apiToDBFieldMap: The mapping of API field names to database fields.
+//
+// Returns:
+//   - *Exporter: A new exporter.
+func NewExporter(
+	parser *ListParser, apiToDBFieldMap map[string]DBField,
+) *Exporter {
+	return &Exporter{Parser: parser, FieldMap: apiToDBFieldMap}
+}
+
+// NegotiateFormat selects the format of an export from the format parameter
+// or, if it is not given, from the Accept header.
+//
+// Parameters:
+//   - r: The HTTP request.
+//
+// Returns:
+//   - StreamFormat: The selected format.
+//   - error: An UnsupportedFormatError if no allowed format is requested.
+func (e *Exporter) NegotiateFormat(r *http.Request) (StreamFormat, error) {
+	formats := e.Formats
+	if len(formats) == 0 {
+		formats = []StreamFormat{StreamCSV, StreamNDJSON}
+	}
+	if param := r.URL.Query().Get(FormatParam); param != "" {
+		format := StreamFormat(strings.ToLower(param))
+		if !slices.Contains(formats, format) {
+			return "", unsupportedFormatError(param)
+		}
+		return format, nil
+	}
+
+	accept := r.Header.Get("Accept")
+	if strings.TrimSpace(accept) == "" {
+		return formats[0], nil
+	}
+	for _, mediaType := range acceptedMediaTypes(accept) {
+		if mediaType == "*/*" {
+			return formats[0], nil
+		}
+		format, ok := streamMediaTypes[mediaType]
+		if ok && slices.Contains(formats, format) {
+			return format, nil
+		}
+	}
+	return "", unsupportedFormatError(accept)
+}
+
+// ParseRequest parses an export request. Filters, sorts and fields are
+// parsed and checked by the list parser and translated with the field map.
+// If no fields are requested, the allowed fields of the parser are exported,
+// or all fields of the field map in alphabetical order.
+//
+// Parameters:
+//   - r: The HTTP request.
+//
+// Returns:
+//   - *ExportQuery: The parsed export query.
+//   - error: An API error if the request is invalid.
+func (e *Exporter) ParseRequest(r *http.Request) (*ExportQuery, error) {
+	format, err := e.NegotiateFormat(r)
+	if err != nil {
+		return nil, err
+	}
+	values := r.URL.Query()
+	for _, param := range []string{OffsetParam, LimitParam, CursorParam} {
+		values.Del(param)
+	}
+	listQuery, err := e.Parser.ParseQuery(values)
+	if err != nil {
+		return nil, err
+	}
+
+	selectors, err := listQuery.Selectors.ToDBSelectors(e.FieldMap)
+	if err != nil {
+		return nil, err
+	}
+	orders, err := listQuery.Orders.TranslateToDBOrders(e.FieldMap)
+	if err != nil {
+		return nil, err
+	}
+	projections, err := listQuery.Fields.ToDBProjections(e.FieldMap)
+	if err != nil {
+		return nil, err
+	}
+	return &ExportQuery{
+		Format:      format,
+		Fields:      e.exportedFields(listQuery.Fields),
+		Sparse:      len(listQuery.Fields) > 0,
+		Selectors:   selectors,
+		Orders:      orders,
+		Projections: projections,
+	}, nil
+}
+
+// ExportHandler returns a handler that parses export requests and streams
+// the items returned by the query function. Request errors and errors of the
+// first item are written with WriteError. Errors after the response has
+// started end the response. Errors of the export are passed to OnError.
+//
+// Example:
+//
+//	handler := ExportHandler(exporter, func(
+//	    ctx context.Context, query *ExportQuery,
+//	) iter.Seq2[*User, error] {
+//	    return users.Iterate(db, query.GetOptions(), newUser, qb, nil)
+//	})
+//
+// Parameters:
+//   - exporter: The exporter.
+//   - queryFn: A function returning the items of the export query.
+//
+// Returns:
+//   - http.HandlerFunc: The export handler.
+func ExportHandler[T any](
+	exporter *Exporter,
+	queryFn func(ctx context.Context, query *ExportQuery) iter.Seq2[T, error],
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query, err := exporter.ParseRequest(r)
+		if err != nil {
+			WriteError(w, err)
+			return
+		}
+		err = Export(exporter, w, r, query, queryFn(r.Context(), query))
+		if err != nil && exporter.OnError != nil {
+			exporter.OnError(r, err)
+		}
+	}
+}
+
+// Export streams the items in the format of the export query. CSV columns
+// are the fields of the query, with values taken from the JSON encoding of
+// the items, and JSON items are limited to the fields if they were requested.
+// The first item is read before the response starts, so that if it fails the
+// error is written with WriteError instead of an OK status. The response is
+// flushed periodically, and the export stops when the client disconnects.
+//
+// Parameters:
+//   - exporter: The exporter.
+//   - w: The response writer.
+//   - r: The HTTP request.
+//   - query: The export query.
+//   - items: The items to export.
+//
+// Returns:
+//   - error: An error if an item cannot be read or written, or the client
+//     disconnected.
+func Export[T any](
+	exporter *Exporter,
+	w http.ResponseWriter,
+	r *http.Request,
+	query *ExportQuery,
+	items iter.Seq2[T, error],
+) error {
+	items, stop, err := pullFirst(items)
+	defer stop()
+	if err != nil {
+		WriteError(w, err)
+		return err
+	}
+
+	w.Header().Set("Content-Type", query.Format.ContentType())
+	if exporter.Filename != "" {
+		w.Header().Set("Content-Disposition", mime.FormatMediaType(
+			"attachment",
+			map[string]string{
+				"filename": exporter.Filename + "." + string(query.Format),
+			},
+		))
+	}
+	w.WriteHeader(http.StatusOK)
+
+	if query.Format == StreamCSV {
+		writer := NewStreamWriter[T](w, query.Format).
+			WithCSV(query.Fields, jsonRecordFn[T](query.Fields))
+		return streamExport(exporter, w, r, writer, items)
+	}
+	var fields Fields
+	if query.Sparse {
+		fields = query.Fields
+	}
+	return streamExport(
+		exporter,
+		w,
+		r,
+		NewStreamWriter[Sparse](w, query.Format),
+		MapItems(items, func(item T) Sparse {
+			return Sparse{Value: item, Fields: fields}
+		}),
+	)
+}
+
+// streamExport writes the items and flushes the response periodically. It
+// stops when the client disconnects.
+func streamExport[T any](
+	exporter *Exporter,
+	w http.ResponseWriter,
+	r *http.Request,
+	writer *StreamWriter[T],
+	items iter.Seq2[T, error],
+) error {
+	flushRows := exporter.FlushRows
+	if flushRows <= 0 {
+		flushRows = DefaultExportFlushRows
+	}
+	flushInterval := exporter.FlushInterval
+	if flushInterval <= 0 {
+		flushInterval = DefaultExportFlushInterval
+	}
+	flush := func() error {
+		if err := writer.Flush(); err != nil {
+			return err
+		}
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+		return nil
+	}
+
+	ctx := r.Context()
+	pending, lastFlush := 0, time.Now()
+	for item, err := range items {
+		if err != nil {
+			_ = flush()
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := writer.Write(item); err != nil {
+			return err
+		}
+		pending++
+		if pending >= flushRows || time.Since(lastFlush) >= flushInterval {
+			if err := flush(); err != nil {
+				return err
+			}
+			pending, lastFlush = 0, time.Now()
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return flush()
+}
+
+// pullFirst pulls the first item of the items. It returns the items
+// including the first one, a function releasing the items, and the error of
+// the first item.
+func pullFirst[T any](
+	items iter.Seq2[T, error],
+) (iter.Seq2[T, error], func(), error) {
+	next, stop := iter.Pull2(items)
+	first, err, ok := next()
+	if err != nil {
+		return nil, stop, err
+	}
+	return func(yield func(T, error) bool) {
+		defer stop()
+		for ok {
+			if !yield(first, err) {
+				return
+			}
+			first, err, ok = next()
+		}
+	}, stop, nil
+}
+
+// exportedFields returns the fields of an export.
+func (e *Exporter) exportedFields(requested Fields) Fields {
+	if len(requested) > 0 {
+		return requested
+	}
+	if e.Parser != nil && e.Parser.Fields != nil {
+		return e.Parser.Fields
+	}
+	fields := mapKeys(e.FieldMap)
+	sort.Strings(fields)
+	return fields
+}
+
+// unsupportedFormatError returns an error for an unsupported format.
+func unsupportedFormatError(format string) error {
+	return UnsupportedFormatError.
+		WithData(UnsupportedFormatErrorData{Format: format}).
+		WithMessage(fmt.Sprintf("unsupported format: %s", format))
+}
+
+// acceptedMediaTypes returns the media types of an Accept header ordered by
+// their quality. Media types with quality zero are omitted.
+func acceptedMediaTypes(accept string) []string {
+	type acceptedType struct {
+		mediaType string
+		quality   float64
+	}
+	var accepted []acceptedType
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if quality, err = strconv.ParseFloat(q, 64); err != nil {
+				continue
+			}
+		}
+		if quality > 0 {
+			accepted = append(accepted, acceptedType{mediaType, quality})
+		}
+	}
+	sort.SliceStable(accepted, func(i, j int) bool {
+		return accepted[i].quality > accepted[j].quality
+	})
+	mediaTypes := make([]string, len(accepted))
+	for i, acceptedType := range accepted {
+		mediaTypes[i] = acceptedType.mediaType
+	}
+	return mediaTypes
+}
+
+// jsonRecordFn returns a CSV record function taking the values of the fields
+// from the JSON encoding of the items. Strings are written with
+// escapeCSVFormula, nulls and missing fields as empty values, and other values
+// as JSON.
+func jsonRecordFn[T any](fields Fields) CSVRecordFn[T] {
+	return func(item T) ([]string, error) {
+		data, err := json.Marshal(item)
+		if err != nil {
+			return nil, err
+		}
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(data, &object); err != nil {
+			return nil, fmt.Errorf("item is not an object: %w", err)
+		}
+		record := make([]string, len(fields))
+		for i, field := range fields {
+			value := object[field]
+			var str string
+			switch {
+			case len(value) == 0 || string(value) == "null":
+			case json.Unmarshal(value, &str) == nil:
+				record[i] = escapeCSVFormula(str)
+			default:
+				record[i] = string(value)
+			}
+		}
+		return record, nil
+	}
+}
+
+// escapeCSVFormula prefixes a value with a single quote if it starts with a
+// character that makes spreadsheet applications evaluate it as a formula.
+func escapeCSVFormula(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
@@ -0,0 +1,121 @@
+package endpoint
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/pakkasys/fluidapi/core"
+	"github.com/pakkasys/fluidapi/database"
+)
+
+// InvalidProjectionFieldErrorData is the data for the
+// InvalidProjectionFieldError error.
+type InvalidProjectionFieldErrorData struct {
+	Field string `json:"field"`
+}
+
+// InvalidProjectionFieldError is returned when a requested field is unknown
+// or not allowed.
+var InvalidProjectionFieldError = core.NewAPIError("INVALID_PROJECTION_FIELD")
+
+// Fields is a list of API fields requested by the client.
+type Fields []string
+
+// ParseFields parses a comma separated fields parameter. Duplicate fields are
+// ignored after their first occurrence.
+//
+// Parameters:
+//   - fields: The fields parameter, e.g. "id,name".
+//
+// Returns:
+//   - Fields: The requested fields, or nil if none are requested.
+func ParseFields(fields string) Fields {
+	var parsed Fields
+	for _, field := range strings.Split(fields, ",") {
+		field = strings.TrimSpace(field)
+		if field != "" && !slices.Contains(parsed, field) {
+			parsed = append(parsed, field)
+		}
+	}
+	return parsed
+}
+
+// ToDBProjections translates the fields into database projections.
+//
+// Parameters:
+//   - apiToDBFieldMap: The mapping of API field names to database fields.
+//
+// Returns:
+//   - database.Projections: The projections, or nil if there are no fields.
+//   - error: An InvalidProjectionFieldError if a field is not in the map.
+func (f Fields) ToDBProjections(
+	apiToDBFieldMap map[string]DBField,
+) (database.Projections, error) {
+	if len(f) == 0 {
+		return nil, nil
+	}
+	projections := make(database.Projections, 0, len(f))
+	for _, field := range f {
+		dbField, ok := apiToDBFieldMap[field]
+		if !ok {
+			return nil, invalidProjectionFieldError(field)
+		}
+		projections = append(projections, database.Projection{
+			Table:  dbField.Table,
+			Column: dbField.Column,
+		})
+	}
+	return projections, nil
+}
+
+// Sparse wraps a value so that only the requested top-level fields of its JSON
+// encoding are emitted. If there are no fields, the value is emitted as is.
+type Sparse struct {
+	Value  any
+	Fields Fields
+}
+
+// MarshalJSON encodes the requested fields of the value.
+func (s Sparse) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(s.Value)
+	if err != nil || len(s.Fields) == 0 {
+		return data, err
+	}
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, fmt.Errorf("MarshalJSON: value is not an object: %w", err)
+	}
+	sparse := make(map[string]json.RawMessage, len(s.Fields))
+	for _, field := range s.Fields {
+		if value, ok := object[field]; ok {
+			sparse[field] = value
+		}
+	}
+	return json.Marshal(sparse)
+}
+
+// SparseItems wraps the items so that only the requested fields are emitted.
+//
+// Parameters:
+//   - items: The items to wrap.
+//   - fields: The requested fields.
+//
+// Returns:
+//   - []Sparse: The wrapped items.
+func SparseItems[T any](items []T, fields Fields) []Sparse {
+	sparse := make([]Sparse, len(items))
+	for i, item := range items {
+		sparse[i] = Sparse{Value: item, Fields: fields}
+	}
+	return sparse
+}
+
+// invalidProjectionFieldError returns an error for a field that cannot be
+// projected.
+func invalidProjectionFieldError(field string) error {
+	return InvalidProjectionFieldError.
+		WithData(InvalidProjectionFieldErrorData{Field: field}).
+		WithMessage(fmt.Sprintf("field not allowed: %s", field))
+}
@@ -0,0 +1,157 @@
+package endpoint
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/pakkasys/fluidapi/core"
+)
+
+// FieldType is the type of the values of an API field.
+type FieldType string
+
+// Available field types.
+const (
+	FieldTypeString FieldType = "string"
+	FieldTypeInt    FieldType = "int"
+	FieldTypeFloat  FieldType = "float"
+	FieldTypeBool   FieldType = "bool"
+	FieldTypeTime   FieldType = "time" // RFC 3339 timestamp.
+)
+
+// InvalidSelectorValueErrorData is the data for the InvalidSelectorValueError
+// error.
+type InvalidSelectorValueErrorData struct {
+	Field string    `json:"field"`
+	Type  FieldType `json:"type"`
+}
+
+// InvalidSelectorValueError is returned when a selector value cannot be
+// converted into the type of its field.
+var InvalidSelectorValueError = core.NewAPIError("INVALID_SELECTOR_VALUE")
+
+// coerceSelectorValue converts a selector value into the field type. String
+// values are parsed and numbers are converted. Values of other types must
+// already have the field type.
+func coerceSelectorValue(
+	field string, value any, fieldType FieldType,
+) (any, error) {
+	if fieldType == "" || value == nil {
+		return value, nil
+	}
+	coerced, ok := coerceValue(value, fieldType)
+	if !ok {
+		return nil, InvalidSelectorValueError.
+			WithData(InvalidSelectorValueErrorData{
+				Field: field,
+				Type:  fieldType,
+			}).
+			WithMessage(fmt.Sprintf(
+				"invalid value for field %s, expected %s", field, fieldType,
+			))
+	}
+	return coerced, nil
+}
+
+// coerceValue converts the value into the field type. It reports whether the
+// conversion succeeded. Values that cannot represent the field type, such as
+// maps, slices and nested objects, are rejected.
+func coerceValue(value any, fieldType FieldType) (any, bool) {
+	if number, ok := value.(json.Number); ok {
+		return coerceNumber(number, fieldType)
+	}
+	if fieldType == "" {
+		return value, true
+	}
+	switch typed := value.(type) {
+	case string:
+		return parseString(typed, fieldType)
+	case bool:
+		return typed, fieldType == FieldTypeBool
+	case time.Time:
+		return typed, fieldType == FieldTypeTime
+	}
+
+	reflected := reflect.ValueOf(value)
+	switch reflected.Kind() {
+	case reflect.Float32, reflect.Float64:
+		float := reflected.Float()
+		switch fieldType {
+		case FieldTypeInt:
+			if float != math.Trunc(float) ||
+				float < math.MinInt64 || float >= math.MaxInt64 {
+				return nil, false
+			}
+			return int64(float), true
+		case FieldTypeFloat:
+			return float, true
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+		switch fieldType {
+		case FieldTypeInt:
+			return reflected.Int(), true
+		case FieldTypeFloat:
+			return float64(reflected.Int()), true
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64:
+		switch fieldType {
+		case FieldTypeInt:
+			if reflected.Uint() > math.MaxInt64 {
+				return nil, false
+			}
+			return int64(reflected.Uint()), true
+		case FieldTypeFloat:
+			return float64(reflected.Uint()), true
+		}
+	}
+	return nil, false
+}
+
+// coerceNumber converts a JSON number into the field type. Integers are
+// parsed exactly, so that values beyond 2^53 are not rounded. Numbers of
+// untyped fields become int64 if they are integers and float64 otherwise.
+func coerceNumber(number json.Number, fieldType FieldType) (any, bool) {
+	switch fieldType {
+	case "":
+		if integer, err := number.Int64(); err == nil {
+			return integer, true
+		}
+		float, err := number.Float64()
+		return float, err == nil
+	case FieldTypeInt:
+		integer, err := number.Int64()
+		return integer, err == nil
+	case FieldTypeFloat:
+		float, err := number.Float64()
+		return float, err == nil
+	default:
+		return nil, false
+	}
+}
+
+// parseString parses the string into the field type.
+func parseString(str string, fieldType FieldType) (any, bool) {
+	var value any
+	var err error
+	switch fieldType {
+	case FieldTypeString:
+		value = str
+	case FieldTypeInt:
+		value, err = strconv.ParseInt(str, 10, 64)
+	case FieldTypeFloat:
+		value, err = strconv.ParseFloat(str, 64)
+	case FieldTypeBool:
+		value, err = strconv.ParseBool(str)
+	case FieldTypeTime:
+		value, err = time.Parse(time.RFC3339, str)
+	default:
+		return nil, false
+	}
+	return value, err == nil
+}
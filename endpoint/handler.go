@@ -0,0 +1,180 @@
+package endpoint
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/pakkasys/fluidapi/core"
+)
+
+// InternalServerError is returned when a handler fails with an error that is
+// not an API error. The original error is not exposed to the client.
+var InternalServerError = core.NewAPIError("INTERNAL_SERVER_ERROR")
+
+// ErrorStatusCodes maps API error IDs to HTTP status codes. API errors that are
+// not listed are written with status 400. It can be extended with custom API
+// errors.
+var ErrorStatusCodes = map[string]int{
+	InternalServerError.ID:       http.StatusInternalServerError,
+	RequestTooLargeError.ID:      http.StatusRequestEntityTooLarge,
+	InvalidJSONError.ID:          http.StatusBadRequest,
+	InvalidParameterError.ID:     http.StatusBadRequest,
+	ValidationError.ID:           http.StatusBadRequest,
+	core.RequestTimeoutError.ID:  http.StatusServiceUnavailable,
+	MaxPageLimitExceededError.ID: http.StatusBadRequest,
+	UnsupportedFormatError.ID:    http.StatusNotAcceptable,
+	UnsupportedMediaTypeError.ID: http.StatusUnsupportedMediaType,
+}
+
+// WriteError writes the given error as a JSON API error. API errors are
+// written with the status code from ErrorStatusCodes. Other errors are written
+// as InternalServerError.
+//
+// Parameters:
+//   - w: The response writer.
+//   - err: The error to write.
+func WriteError(w http.ResponseWriter, err error) {
+	var apiErr *core.APIError
+	if !errors.As(err, &apiErr) {
+		_ = core.WriteAPIError(
+			w, http.StatusInternalServerError, InternalServerError,
+		)
+		return
+	}
+	statusCode, ok := ErrorStatusCodes[apiErr.ID]
+	if !ok {
+		statusCode = http.StatusBadRequest
+	}
+	_ = core.WriteAPIError(w, statusCode, apiErr)
+}
+
+// Validator can be implemented by handler inputs to validate themselves after
+// they have been decoded.
+type Validator interface {
+	Validate() error
+}
+
+// HandlerFunc is a typed handler function. It receives the decoded input and
+// returns the output to encode.
+type HandlerFunc[In any, Out any] func(
+	ctx context.Context, input *In,
+) (*Out, error)
+
+// handlerConfig holds the configuration of a typed handler.
+type handlerConfig struct {
+	statusCode   int
+	errorHandler func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// HandlerOption is a function that modifies a typed handler.
+type HandlerOption func(*handlerConfig)
+
+// WithStatusCode returns a handler option that sets the status code of
+// successful responses. The default is 200.
+//
+// Parameters:
+//   - statusCode: The status code of successful responses.
+//
+// Returns:
+//   - HandlerOption: a function that sets the status code.
+func WithStatusCode(statusCode int) HandlerOption {
+	return func(c *handlerConfig) {
+		c.statusCode = statusCode
+	}
+}
+
+// WithErrorHandler returns a handler option that sets the function used to
+// write errors. The default is WriteError.
+//
+// Parameters:
+//   - errorHandler: The function used to write errors.
+//
+// Returns:
+//   - HandlerOption: a function that sets the error handler.
+func WithErrorHandler(
+	errorHandler func(w http.ResponseWriter, r *http.Request, err error),
+) HandlerOption {
+	return func(c *handlerConfig) {
+		c.errorHandler = errorHandler
+	}
+}
+
+// Handle adapts a typed handler function into an http.HandlerFunc. The input
+// is populated from the request path, query, headers and JSON body using the
+// "path", "query", "header" and "json" struct tags. Fields with a parameter
+// tag are only set from their parameter, never from the body, and non-empty
+// bodies must have a JSON content type. The input is validated
+// with Validate and, if it implements Validator, with its own Validate method
+// before the function is called. It panics if the validation tags of the input
+// are invalid. The output is encoded as JSON. If the output is nil, only the
+// status code is written. Errors are written with WriteError unless a custom
+// error handler is set.
+//
+// Example:
+//
+//	type GetUserInput struct {
+//	    ID     string `path:"id"`
+//	    Expand bool   `query:"expand"`
+//	}
+//	handler := Handle(func(ctx context.Context, in *GetUserInput) (*User, error) {
+//	    return service.GetUser(ctx, in.ID, in.Expand)
+//	})
+//
+// Parameters:
+//   - fn: The typed handler function.
+//   - options: Options for the handler.
+//
+// Returns:
+//   - http.HandlerFunc: The adapted handler.
+func Handle[In any, Out any](
+	fn HandlerFunc[In, Out], options ...HandlerOption,
+) http.HandlerFunc {
+	config := handlerConfig{
+		statusCode: http.StatusOK,
+		errorHandler: func(w http.ResponseWriter, _ *http.Request, err error) {
+			WriteError(w, err)
+		},
+	}
+	for _, option := range options {
+		option(&config)
+	}
+	decoder := newInputDecoder[In]()
+	if err := CheckValidationTags[In](); err != nil {
+		panic(fmt.Sprintf("Handle: %v", err))
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		input := new(In)
+		if err := decoder.decode(r, input); err != nil {
+			config.errorHandler(w, r, err)
+			return
+		}
+		if err := validateInput(input); err != nil {
+			config.errorHandler(w, r, err)
+			return
+		}
+		output, err := fn(r.Context(), input)
+		if err != nil {
+			config.errorHandler(w, r, err)
+			return
+		}
+		if output == nil {
+			w.WriteHeader(config.statusCode)
+			return
+		}
+		_ = core.WriteJSON(w, config.statusCode, output)
+	}
+}
+
+// validateInput validates the decoded input.
+func validateInput(input any) error {
+	if err := Validate(input); err != nil {
+		return err
+	}
+	if validator, ok := input.(Validator); ok {
+		return validator.Validate()
+	}
+	return nil
+}
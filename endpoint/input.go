@@ -0,0 +1,241 @@
+package endpoint
+
+import (
+	"bufio"
+	"encoding"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/pakkasys/fluidapi/core"
+)
+
+// Input sources for struct tags.
+const (
+	SourcePath   = "path"
+	SourceQuery  = "query"
+	SourceHeader = "header"
+)
+
+// InvalidParameterErrorData is the data for the InvalidParameterError error.
+type InvalidParameterErrorData struct {
+	Source string `json:"source"`
+	Name   string `json:"name"`
+}
+
+// InvalidParameterError is returned when a path, query or header parameter
+// cannot be converted into the type of its input field.
+var InvalidParameterError = core.NewAPIError("INVALID_PARAMETER")
+
+// UnsupportedMediaTypeErrorData is the data for the UnsupportedMediaTypeError
+// error.
+type UnsupportedMediaTypeErrorData struct {
+	ContentType string `json:"content_type"`
+}
+
+// UnsupportedMediaTypeError is returned when a request body that is decoded
+// as JSON does not have a JSON content type.
+var UnsupportedMediaTypeError = core.NewAPIError("UNSUPPORTED_MEDIA_TYPE")
+
+// inputField describes an input struct field populated from a request
+// parameter.
+type inputField struct {
+	index  []int
+	source string
+	name   string
+}
+
+// inputDecoder populates input structs of a type from requests.
+type inputDecoder struct {
+	fields []inputField
+}
+
+// newInputDecoder creates a new inputDecoder for the given input type. It
+// panics if the input type is not a struct.
+func newInputDecoder[In any]() *inputDecoder {
+	inputType := reflect.TypeFor[In]()
+	if inputType.Kind() != reflect.Struct {
+		panic(fmt.Sprintf(
+			"newInputDecoder: input must be a struct, got %s", inputType,
+		))
+	}
+	return &inputDecoder{fields: collectInputFields(inputType, nil)}
+}
+
+// collectInputFields collects the fields with parameter tags, including the
+// fields of embedded structs.
+func collectInputFields(t reflect.Type, parentIndex []int) []inputField {
+	var fields []inputField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		index := append(append([]int{}, parentIndex...), i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			fields = append(fields, collectInputFields(field.Type, index)...)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		for _, source := range []string{SourcePath, SourceQuery, SourceHeader} {
+			if name, ok := field.Tag.Lookup(source); ok && name != "" {
+				fields = append(fields, inputField{
+					index:  index,
+					source: source,
+					name:   name,
+				})
+				break
+			}
+		}
+	}
+	return fields
+}
+
+// decode populates the input from the request body and parameters. The body
+// is decoded first, and fields with parameter tags are then reset, so that
+// they can only be set from their parameters and never from the body.
+func (d *inputDecoder) decode(r *http.Request, input any) error {
+	if err := decodeBody(r, input); err != nil {
+		return err
+	}
+	value := reflect.ValueOf(input).Elem()
+	query := r.URL.Query()
+	for _, field := range d.fields {
+		var values []string
+		switch field.source {
+		case SourcePath:
+			if pathValue := r.PathValue(field.name); pathValue != "" {
+				values = []string{pathValue}
+			}
+		case SourceQuery:
+			values = query[field.name]
+		case SourceHeader:
+			values = r.Header.Values(field.name)
+		}
+		fieldValue := value.FieldByIndex(field.index)
+		fieldValue.SetZero()
+		if len(values) == 0 {
+			continue
+		}
+		if err := setFieldValue(fieldValue, values); err != nil {
+			return InvalidParameterError.
+				WithData(InvalidParameterErrorData{
+					Source: field.source,
+					Name:   field.name,
+				}).
+				WithMessage(fmt.Sprintf(
+					"invalid %s parameter %s: %v",
+					field.source,
+					field.name,
+					err,
+				))
+		}
+	}
+	return nil
+}
+
+// decodeBody decodes the JSON request body into the input if the body is not
+// empty. Bodies without a JSON content type are rejected.
+func decodeBody(r *http.Request, input any) error {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	reader := bufio.NewReader(r.Body)
+	if _, err := reader.Peek(1); err == io.EOF {
+		return nil
+	}
+	if !isJSONRequest(r) {
+		contentType := r.Header.Get("Content-Type")
+		return UnsupportedMediaTypeError.
+			WithData(UnsupportedMediaTypeErrorData{ContentType: contentType}).
+			WithMessage(fmt.Sprintf(
+				"unsupported content type: %q", contentType,
+			))
+	}
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{reader, r.Body}
+	return DecodeJSON(r, input)
+}
+
+// textUnmarshalerType is the reflect type of encoding.TextUnmarshaler.
+var textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+
+// setFieldValue converts the string values into the type of the field and sets
+// the field. Slices receive all values, other types receive the first value.
+func setFieldValue(field reflect.Value, values []string) error {
+	if field.Kind() == reflect.Slice &&
+		!reflect.PointerTo(field.Type()).Implements(textUnmarshalerType) {
+		slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+		for i, value := range values {
+			if err := setStringValue(slice.Index(i), value); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	}
+	return setStringValue(field, values[0])
+}
+
+// setStringValue converts the string into the type of the value and sets it.
+func setStringValue(value reflect.Value, str string) error {
+	if value.Kind() == reflect.Pointer {
+		ptr := reflect.New(value.Type().Elem())
+		if err := setStringValue(ptr.Elem(), str); err != nil {
+			return err
+		}
+		value.Set(ptr)
+		return nil
+	}
+	if value.CanAddr() {
+		addr := value.Addr().Interface()
+		if unmarshaler, ok := addr.(encoding.TextUnmarshaler); ok {
+			return unmarshaler.UnmarshalText([]byte(str))
+		}
+	}
+	if value.Type() == reflect.TypeFor[time.Duration]() {
+		duration, err := time.ParseDuration(str)
+		if err != nil {
+			return err
+		}
+		value.SetInt(int64(duration))
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(str)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(str)
+		if err != nil {
+			return err
+		}
+		value.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(str, 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64:
+		u, err := strconv.ParseUint(str, 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(str, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type: %s", value.Type())
+	}
+	return nil
+}
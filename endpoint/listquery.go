@@ -0,0 +1,393 @@
+package endpoint
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Query parameter names used by ListParser.
+const (
+	FilterParam = "filter"
+	SortParam   = "sort"
+	OffsetParam = "offset"
+	LimitParam  = "limit"
+	CursorParam = "cursor"
+	FieldsParam = "fields"
+)
+
+// nullsSortPrefix is the prefix of the nulls order suffix in sort parameters.
+const nullsSortPrefix = "nulls_"
+
+// ListQuery holds the selectors, orders and page of a list request.
+type ListQuery struct {
+	Selectors Selectors `json:"selectors,omitempty"`
+	Orders    Orders    `json:"orders,omitempty"`
+	Page      *Page     `json:"page,omitempty"`
+	Fields    Fields    `json:"fields,omitempty"`
+}
+
+// ListParser parses list requests into a ListQuery and enforces which fields
+// can be filtered, sorted and selected and how large pages can be.
+type ListParser struct {
+	Filters       map[string]Predicates // Allowed predicates per field.
+	Sorts         []string              // Fields allowed for sorting.
+	Fields        []string              // Fields allowed in fieldsets.
+	MaxLimit      int                   // Max page limit, zero disables.
+	DefaultLimit  int                   // Limit used if none is given.
+	DefaultOrders Orders                // Orders used if none are given.
+	// Orders appended if their field is not ordered by, e.g. a unique ID.
+	Tiebreakers Orders
+}
+
+// NewListParser creates a new list parser.
+//
+// Parameters:
+//   - filters: The allowed predicates per filterable field.
+//   - sorts: The fields allowed for sorting.
+//   - maxLimit: The max page limit. Zero disables the limit.
+//
+// Returns:
+//   - *ListParser: A new list parser.
+func NewListParser(
+	filters map[string]Predicates, sorts []string, maxLimit int,
+) *ListParser {
+	return &ListParser{
+		Filters:  filters,
+		Sorts:    sorts,
+		MaxLimit: maxLimit,
+	}
+}
+
+// ParseRequest parses a list request. GET and HEAD requests and requests
+// without a body are parsed from the query string with ParseQuery. Other
+// requests are decoded from a JSON body in the shape of ListQuery.
+//
+// Parameters:
+//   - r: The HTTP request.
+//
+// Returns:
+//   - *ListQuery: The parsed list query.
+//   - error: An API error if the request is invalid.
+func (p *ListParser) ParseRequest(r *http.Request) (*ListQuery, error) {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead ||
+		r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 {
+		return p.ParseQuery(r.URL.Query())
+	}
+	var listQuery ListQuery
+	if err := DecodeJSON(r, &listQuery); err != nil {
+		return nil, err
+	}
+	if err := p.Check(&listQuery); err != nil {
+		return nil, err
+	}
+	return &listQuery, nil
+}
+
+// ParseQuery parses list query parameters. Filters are given as
+// "filter[field][predicate]=value" or "filter[field]=value" for equality, and
+// IN and NOT IN values are separated by commas. Sorting is given as a comma
+// separated list of fields, where a "-" prefix sorts in descending order and
+// a ":nulls_first" or ":nulls_last" suffix places NULL values. The orders keep
+// the order in which they are given.
+//
+// Pages are selected with "offset" and "limit", or with "cursor" and "limit"
+// for keyset pagination. A sparse fieldset is given as a comma separated list
+// of fields in "fields".
+//
+// Example:
+//
+//	?filter[age][gt]=30&sort=-created_at:nulls_last,name&offset=0&limit=50
+//	&fields=id,name
+//
+// Parameters:
+//   - values: The query parameters.
+//
+// Returns:
+//   - *ListQuery: The parsed list query.
+//   - error: An API error if the parameters are invalid.
+func (p *ListParser) ParseQuery(values url.Values) (*ListQuery, error) {
+	listQuery := ListQuery{}
+
+	selectors, err := parseFilters(values)
+	if err != nil {
+		return nil, err
+	}
+	listQuery.Selectors = selectors
+	orders, err := parseSorts(values.Get(SortParam))
+	if err != nil {
+		return nil, err
+	}
+	listQuery.Orders = orders
+
+	page, err := parsePage(values)
+	if err != nil {
+		return nil, err
+	}
+	listQuery.Page = page
+	listQuery.Fields = ParseFields(values.Get(FieldsParam))
+
+	if err := p.Check(&listQuery); err != nil {
+		return nil, err
+	}
+	return &listQuery, nil
+}
+
+// Check enforces the allowed filters, sorts, fields and page limit on a list
+// query.
+// Predicates are normalized to their canonical form, and the default orders,
+// tiebreakers and default limit are applied.
+//
+// Parameters:
+//   - listQuery: The list query to check.
+//
+// Returns:
+//   - error: An API error if the list query is not allowed.
+func (p *ListParser) Check(listQuery *ListQuery) error {
+	for field, selector := range listQuery.Selectors {
+		predicate, err := p.checkSelector(field, selector.Predicate)
+		if err != nil {
+			return err
+		}
+		selector.Predicate = predicate
+		listQuery.Selectors[field] = selector
+	}
+	for _, order := range listQuery.Orders {
+		if !slices.Contains(p.Sorts, order.Field) {
+			return InvalidOrderFieldError.
+				WithData(InvalidOrderFieldErrorData{Field: order.Field}).
+				WithMessage(fmt.Sprintf("field not allowed: %s", order.Field))
+		}
+		if _, _, err := order.translateDirection(); err != nil {
+			return err
+		}
+	}
+	for _, field := range listQuery.Fields {
+		if !slices.Contains(p.Fields, field) {
+			return invalidProjectionFieldError(field)
+		}
+	}
+	listQuery.Orders = listQuery.Orders.WithDefaults(
+		p.DefaultOrders, p.Tiebreakers...,
+	)
+	return p.checkPage(listQuery)
+}
+
+// checkSelector checks that the field can be filtered with the predicate and
+// returns the canonical predicate.
+func (p *ListParser) checkSelector(
+	field string, predicate Predicate,
+) (Predicate, error) {
+	allowed, ok := p.Filters[field]
+	if !ok {
+		return "", InvalidSelectorFieldError.
+			WithData(InvalidSelectorFieldErrorData{Field: field}).
+			WithMessage(fmt.Sprintf("field not allowed: %s", field))
+	}
+	canonical, ok := findPredicate(AllPredicates, predicate)
+	if !ok {
+		return "", InvalidPredicateError.
+			WithData(InvalidPredicateErrorData{Predicate: predicate}).
+			WithMessage(fmt.Sprintf("invalid predicate: %s", predicate))
+	}
+	if _, ok := findPredicate(allowed, canonical); !ok {
+		return "", PredicateNotAllowedError.
+			WithData(PredicateNotAllowedErrorData{Predicate: predicate}).
+			WithMessage(fmt.Sprintf(
+				"predicate %s not allowed for field %s", predicate, field,
+			))
+	}
+	return canonical, nil
+}
+
+// checkPage applies the default limit and enforces the max limit.
+func (p *ListParser) checkPage(listQuery *ListQuery) error {
+	if listQuery.Page == nil {
+		listQuery.Page = &Page{}
+	}
+	if listQuery.Page.Limit == 0 {
+		listQuery.Page.Limit = p.DefaultLimit
+		if listQuery.Page.Limit == 0 {
+			listQuery.Page.Limit = p.MaxLimit
+		}
+	}
+	if listQuery.Page.Offset < 0 {
+		return InvalidParameterError.
+			WithData(InvalidParameterErrorData{
+				Source: SourceQuery,
+				Name:   OffsetParam,
+			}).
+			WithMessage("offset must not be negative")
+	}
+	if listQuery.Page.Limit < 0 {
+		return InvalidParameterError.
+			WithData(InvalidParameterErrorData{
+				Source: SourceQuery,
+				Name:   LimitParam,
+			}).
+			WithMessage("limit must not be negative")
+	}
+	if listQuery.Page.Cursor != "" && listQuery.Page.Offset != 0 {
+		return InvalidParameterError.
+			WithData(InvalidParameterErrorData{
+				Source: SourceQuery,
+				Name:   CursorParam,
+			}).
+			WithMessage("cursor cannot be combined with offset")
+	}
+	if p.MaxLimit > 0 && listQuery.Page.Limit > p.MaxLimit {
+		return MaxPageLimitExceededError.
+			WithData(MaxPageLimitExceededErrorData{MaxLimit: p.MaxLimit}).
+			WithMessage(fmt.Sprintf(
+				"page limit exceeds %d", p.MaxLimit,
+			))
+	}
+	return nil
+}
+
+// parseFilters parses "filter[field][predicate]" query parameters.
+func parseFilters(values url.Values) (Selectors, error) {
+	selectors := Selectors{}
+	for key, fieldValues := range values {
+		if !strings.HasPrefix(key, FilterParam+"[") {
+			continue
+		}
+		field, predicate, err := parseFilterKey(key)
+		if err != nil {
+			return nil, err
+		}
+		if _, exists := selectors[field]; exists || len(fieldValues) > 1 {
+			return nil, InvalidSelectorFieldError.
+				WithData(InvalidSelectorFieldErrorData{Field: field}).
+				WithMessage(fmt.Sprintf(
+					"multiple filters for field: %s", field,
+				))
+		}
+		var value any = fieldValues[0]
+		if isListPredicate(predicate) {
+			value = strings.Split(fieldValues[0], ",")
+		}
+		selectors.AddSelector(field, predicate, value)
+	}
+	return selectors, nil
+}
+
+// parseFilterKey parses the field and predicate of a filter key. The
+// predicate defaults to equality.
+func parseFilterKey(key string) (string, Predicate, error) {
+	rest := strings.TrimPrefix(key, FilterParam)
+	var parts []string
+	for rest != "" {
+		if !strings.HasPrefix(rest, "[") {
+			return "", "", invalidFilterKeyError(key)
+		}
+		end := strings.Index(rest, "]")
+		if end < 0 {
+			return "", "", invalidFilterKeyError(key)
+		}
+		parts = append(parts, rest[1:end])
+		rest = rest[end+1:]
+	}
+	switch {
+	case len(parts) == 1 && parts[0] != "":
+		return parts[0], Eq, nil
+	case len(parts) == 2 && parts[0] != "" && parts[1] != "":
+		return parts[0], Predicate(parts[1]), nil
+	default:
+		return "", "", invalidFilterKeyError(key)
+	}
+}
+
+// invalidFilterKeyError returns an error for a malformed filter key.
+func invalidFilterKeyError(key string) error {
+	return InvalidParameterError.
+		WithData(InvalidParameterErrorData{Source: SourceQuery, Name: key}).
+		WithMessage(fmt.Sprintf("invalid filter parameter: %s", key))
+}
+
+// parseSorts parses a comma separated sort parameter. A field can be suffixed
+// with ":nulls_first" or ":nulls_last" to place NULL values.
+func parseSorts(sort string) (Orders, error) {
+	orders := Orders{}
+	for _, part := range strings.Split(sort, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		field, nulls, hasNulls := strings.Cut(part, ":")
+		order := NewOrder(strings.TrimPrefix(field, "+"), DirectionAsc)
+		if strings.HasPrefix(field, "-") {
+			order = NewOrder(strings.TrimPrefix(field, "-"), DirectionDesc)
+		}
+		if hasNulls {
+			if !strings.HasPrefix(nulls, nullsSortPrefix) {
+				return nil, InvalidParameterError.
+					WithData(InvalidParameterErrorData{
+						Source: SourceQuery,
+						Name:   SortParam,
+					}).
+					WithMessage(fmt.Sprintf("invalid sort: %s", part))
+			}
+			order = order.WithNulls(
+				NullsOrder(strings.TrimPrefix(nulls, nullsSortPrefix)),
+			)
+		}
+		if order.Field != "" && !orders.hasField(order.Field) {
+			orders = append(orders, order)
+		}
+	}
+	return orders, nil
+}
+
+// parsePage parses the offset and limit query parameters.
+func parsePage(values url.Values) (*Page, error) {
+	page := &Page{}
+	for _, param := range []struct {
+		name   string
+		target *int
+	}{
+		{name: OffsetParam, target: &page.Offset},
+		{name: LimitParam, target: &page.Limit},
+	} {
+		str := values.Get(param.name)
+		if str == "" {
+			continue
+		}
+		value, err := strconv.Atoi(str)
+		if err != nil {
+			return nil, InvalidParameterError.
+				WithData(InvalidParameterErrorData{
+					Source: SourceQuery,
+					Name:   param.name,
+				}).
+				WithMessage(fmt.Sprintf(
+					"invalid %s parameter: %s", param.name, str,
+				))
+		}
+		*param.target = value
+	}
+	page.Cursor = values.Get(CursorParam)
+	return page, nil
+}
+
+// isListPredicate reports whether the predicate takes a list of values.
+func isListPredicate(predicate Predicate) bool {
+	return strings.EqualFold(string(predicate), string(In)) ||
+		strings.EqualFold(string(predicate), string(NotIn))
+}
+
+// findPredicate returns the predicate from the list that matches the given
+// predicate case-insensitively.
+func findPredicate(
+	predicates []Predicate, predicate Predicate,
+) (Predicate, bool) {
+	for _, candidate := range predicates {
+		if strings.EqualFold(string(candidate), string(predicate)) {
+			return candidate, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,110 @@
+package endpoint
+
+import (
+	"net/url"
+	"strconv"
+
+	"github.com/pakkasys/fluidapi/database"
+)
+
+// ListLinks holds the links to the current and the adjacent pages of a list.
+type ListLinks struct {
+	Self string  `json:"self"`
+	Next *string `json:"next,omitempty"`
+	Prev *string `json:"prev,omitempty"`
+}
+
+// ListResponse is the response envelope of offset paginated lists.
+type ListResponse[T any] struct {
+	Items     []T       `json:"items"`
+	Total     int       `json:"total"`
+	Estimated bool      `json:"estimated,omitempty"` // Total is an estimate.
+	Offset    int       `json:"offset"`
+	Limit     int       `json:"limit"`
+	Links     ListLinks `json:"links"`
+}
+
+// NewListResponse creates a list response for the items of the page. The
+// links are built from the request URL by replacing its offset and limit
+// parameters, so that filters and sorts are kept. A next link is included if
+// the total count has more items after the page and a previous link if the
+// page has an offset.
+//
+// Parameters:
+//   - items: The items of the page.
+//   - total: The total count of matching items.
+//   - page: The requested page. A nil page is treated as a single page.
+//   - requestURL: The URL of the list request.
+//
+// Returns:
+//   - *ListResponse[T]: The list response.
+func NewListResponse[T any](
+	items []T, total int, page *Page, requestURL *url.URL,
+) *ListResponse[T] {
+	if items == nil {
+		items = []T{}
+	}
+	offset, limit := 0, len(items)
+	if page != nil {
+		offset, limit = page.Offset, page.Limit
+	}
+
+	response := &ListResponse[T]{
+		Items:  items,
+		Total:  total,
+		Offset: offset,
+		Limit:  limit,
+		Links:  ListLinks{Self: pageLink(requestURL, offset, limit)},
+	}
+	if limit <= 0 {
+		return response
+	}
+	if offset+limit < total {
+		next := pageLink(requestURL, offset+limit, limit)
+		response.Links.Next = &next
+	}
+	if offset > 0 {
+		prev := pageLink(requestURL, max(offset-limit, 0), limit)
+		response.Links.Prev = &prev
+	}
+	return response
+}
+
+// NewListResponseFromResult creates a list response from the result of
+// database.ReadDBOps.List, converting the entities with the given function.
+//
+// Parameters:
+//   - result: The database list result.
+//   - page: The requested page.
+//   - requestURL: The URL of the list request.
+//   - toItem: A function converting an entity into a response item.
+//
+// Returns:
+//   - *ListResponse[T]: The list response.
+func NewListResponseFromResult[Entity database.Getter, T any](
+	result *database.ListResult[Entity],
+	page *Page,
+	requestURL *url.URL,
+	toItem func(entity Entity) T,
+) *ListResponse[T] {
+	items := make([]T, len(result.Entities))
+	for i, entity := range result.Entities {
+		items[i] = toItem(entity)
+	}
+	response := NewListResponse(items, result.Total, page, requestURL)
+	response.Estimated = result.Estimated
+	return response
+}
+
+// pageLink returns the request URL with the given offset and limit.
+func pageLink(requestURL *url.URL, offset int, limit int) string {
+	if requestURL == nil {
+		return ""
+	}
+	query := requestURL.Query()
+	query.Del(CursorParam)
+	query.Set(OffsetParam, strconv.Itoa(offset))
+	query.Set(LimitParam, strconv.Itoa(limit))
+	link := url.URL{Path: requestURL.Path, RawQuery: query.Encode()}
+	return link.String()
+}
@@ -1,7 +1,10 @@
 package endpoint
 
 import (
+	"context"
 	"fmt"
+	"reflect"
+	"sort"
 	"strings"
 
 	"github.com/pakkasys/fluidapi/core"
@@ -130,10 +133,45 @@ var OnlyInAndNotInPredicates = []Predicate{
 	NotIn,
 }
 
-// DBField is used to translate between API field and database field.
+// DBField is used to translate between API field and database field. It also
+// declares how the field can be used in selectors.
 type DBField struct {
 	Table  string
 	Column string
+	// Predicates allowed in selectors for the field. Nil allows all.
+	Predicates Predicates
+	// Type of the selector values. String values are coerced into the type.
+	// Empty keeps the values as they are.
+	Type FieldType
+	// MaxInValues is the max number of IN and NOT IN values. Zero disables.
+	MaxInValues int
+	// Write is the write policy of the field. Empty is writable.
+	Write WritePolicy
+	// NotNull rejects explicit null values in writes.
+	NotNull bool
+}
+
+// FiltersFromFields returns the allowed predicates of each field in the field
+// map. Fields that do not declare predicates allow all predicates. It can be
+// used as the filters of a ListParser.
+//
+// Parameters:
+//   - apiToDBFieldMap: The mapping of API field names to database fields.
+//
+// Returns:
+//   - map[string]Predicates: The allowed predicates per field.
+func FiltersFromFields(
+	apiToDBFieldMap map[string]DBField,
+) map[string]Predicates {
+	filters := make(map[string]Predicates, len(apiToDBFieldMap))
+	for field, dbField := range apiToDBFieldMap {
+		if dbField.Predicates == nil {
+			filters[field] = AllPredicates
+		} else {
+			filters[field] = dbField.Predicates
+		}
+	}
+	return filters
 }
 
 // MaxPageLimitExceededErrorData is the data for the MaxPageLimitExceededError
@@ -145,10 +183,12 @@ type MaxPageLimitExceededErrorData struct {
 // MaxPageLimitExceededError is returned when a page limit is exceeded.
 var MaxPageLimitExceededError = core.NewAPIError("MAX_PAGE_LIMIT_EXCEEDED")
 
-// Page represents a pagination input.
+// Page represents a pagination input. A page is either selected by offset or
+// by an opaque cursor created by CursorCodec.
 type Page struct {
-	Offset int `json:"offset"`
-	Limit  int `json:"limit"`
+	Offset int    `json:"offset"`
+	Limit  int    `json:"limit"`
+	Cursor string `json:"cursor,omitempty"`
 }
 
 // ToDBPage converts a Page to database Page.
@@ -194,14 +234,105 @@ var DirectionsToDB = map[OrderDirection]database.OrderDirection{
 	DirectionDescending: database.OrderDesc,
 }
 
-// Orders is a map of field names to order directions.
-type Orders map[string]OrderDirection
+// NullsOrder is used to specify where NULL values are placed in the order.
+type NullsOrder string
+
+// String returns the string representation of the nulls order.
+func (n NullsOrder) String() string {
+	return string(n)
+}
+
+// Available nulls orders.
+const (
+	NullsFirst NullsOrder = "first"
+	NullsLast  NullsOrder = "last"
+)
+
+// NullsToDB is a map of nulls orders to database nulls orders.
+var NullsToDB = map[NullsOrder]database.NullsOrder{
+	NullsFirst: database.NullsFirst,
+	NullsLast:  database.NullsLast,
+}
+
+// InvalidOrderDirectionErrorData is the data for the
+// InvalidOrderDirectionError error.
+type InvalidOrderDirectionErrorData struct {
+	Field     string         `json:"field"`
+	Direction OrderDirection `json:"direction,omitempty"`
+	Nulls     NullsOrder     `json:"nulls,omitempty"`
+}
+
+// InvalidOrderDirectionError is returned when an order direction or nulls
+// order is not recognized.
+var InvalidOrderDirectionError = core.NewAPIError("INVALID_ORDER_DIRECTION")
+
+// Order represents the order of a single field. An empty direction sorts in
+// ascending order and an empty nulls order uses the database default.
+type Order struct {
+	Field     string         `json:"field"`
+	Direction OrderDirection `json:"direction,omitempty"`
+	Nulls     NullsOrder     `json:"nulls,omitempty"`
+}
+
+// NewOrder creates a new order with the provided field and direction.
+//
+// Parameters:
+//   - field: The field to order by.
+//   - direction: The direction of the order.
+//
+// Returns:
+//   - Order: A new order.
+func NewOrder(field string, direction OrderDirection) Order {
+	return Order{
+		Field:     field,
+		Direction: direction,
+	}
+}
+
+// WithNulls returns a new order with the provided nulls order.
+//
+// Parameters:
+//   - nulls: The nulls order.
+//
+// Returns:
+//   - Order: A new order.
+func (o Order) WithNulls(nulls NullsOrder) Order {
+	o.Nulls = nulls
+	return o
+}
+
+// Orders is a list of orders. The orders are applied in the order they appear
+// in the list.
+type Orders []Order
+
+// WithDefaults returns the orders with defaults applied. If there are no
+// orders, the default orders are used. The tiebreaker orders are appended for
+// fields that are not already ordered by, so that the ordering is total and
+// pagination is stable.
+//
+// Parameters:
+//   - defaults: The orders to use if there are no orders.
+//   - tiebreakers: The orders to append if their field is not yet ordered by.
+//
+// Returns:
+//   - Orders: The orders with defaults applied.
+func (o Orders) WithDefaults(defaults Orders, tiebreakers ...Order) Orders {
+	orders := append(Orders{}, o...)
+	if len(orders) == 0 {
+		orders = append(orders, defaults...)
+	}
+	for _, tiebreaker := range tiebreakers {
+		if !orders.hasField(tiebreaker.Field) {
+			orders = append(orders, tiebreaker)
+		}
+	}
+	return orders
+}
 
 // TranslateToDBOrders translates the provided orders into database orders.
-// It also returns an error if any of the orders are invalid.
+// Duplicate fields are ignored after their first occurrence. It also returns
+// an error if any of the orders are invalid.
 //
-//   - orders: The list of orders to translate.
-//   - allowedOrderFields: The list of allowed order fields.
 //   - apiToDBFieldMap: The mapping of API field names to database field names.
 func (o Orders) TranslateToDBOrders(
 	apiToDBFieldMap map[string]DBField,
@@ -213,22 +344,32 @@ func (o Orders) TranslateToDBOrders(
 	return dbOrders, nil
 }
 
-// dedup deduplicates the provided orders.
+// dedup deduplicates the provided orders, keeping the first occurrence of
+// each field.
 func (o Orders) dedup() Orders {
-	dedup := map[string]OrderDirection{}
+	dedup := Orders{}
 	existing := make(map[string]bool)
-	for field := range o {
-		order := o[field]
-		if !existing[field] {
-			dedup[field] = order
-			existing[field] = true
+	for _, order := range o {
+		if !existing[order.Field] {
+			dedup = append(dedup, order)
+			existing[order.Field] = true
 		}
 	}
 	return dedup
 }
 
-// ToDBOrders translates the provided orders into database orders.
-// It returns an error if any of the orders are invalid.
+// hasField reports whether the orders contain the field.
+func (o Orders) hasField(field string) bool {
+	for _, order := range o {
+		if order.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// ToDBOrders translates the provided orders into database orders, preserving
+// their order. It returns an error if any of the orders are invalid.
 //
 //   - apiToDBFieldMap: The mapping of API field names to database field names.
 func (o Orders) ToDBOrders(
@@ -236,32 +377,75 @@ func (o Orders) ToDBOrders(
 ) ([]database.Order, error) {
 	dbOrders := []database.Order{}
 
-	for field, direction := range o {
-		translatedField := apiToDBFieldMap[field]
+	for _, order := range o {
+		translatedField := apiToDBFieldMap[order.Field]
 
 		// Translate field.
 		dbColumn := translatedField.Column
 		if dbColumn == "" {
 			return nil, InvalidOrderFieldError.
 				WithData(
-					InvalidOrderFieldErrorData{Field: field},
+					InvalidOrderFieldErrorData{Field: order.Field},
 				).
 				WithMessage(fmt.Sprintf(
-					"cannot translate field: %s", field,
+					"cannot translate field: %s", order.Field,
 				))
 		}
 
-		lowerDir := OrderDirection(strings.ToLower(string(direction)))
+		dbDirection, dbNulls, err := order.translateDirection()
+		if err != nil {
+			return nil, err
+		}
 		dbOrders = append(dbOrders, database.Order{
 			Table:     translatedField.Table,
 			Field:     dbColumn,
-			Direction: DirectionsToDB[lowerDir],
+			Direction: dbDirection,
+			Nulls:     dbNulls,
 		})
 	}
 
 	return dbOrders, nil
 }
 
+// translateDirection translates the direction and nulls order of the order.
+func (o Order) translateDirection() (
+	database.OrderDirection, database.NullsOrder, error,
+) {
+	direction := database.OrderAsc
+	if o.Direction != "" {
+		lowerDir := OrderDirection(strings.ToLower(string(o.Direction)))
+		dbDirection, ok := DirectionsToDB[lowerDir]
+		if !ok {
+			return "", "", InvalidOrderDirectionError.
+				WithData(InvalidOrderDirectionErrorData{
+					Field:     o.Field,
+					Direction: o.Direction,
+				}).
+				WithMessage(fmt.Sprintf(
+					"invalid order direction: %s", o.Direction,
+				))
+		}
+		direction = dbDirection
+	}
+
+	var nulls database.NullsOrder
+	if o.Nulls != "" {
+		lowerNulls := NullsOrder(strings.ToLower(string(o.Nulls)))
+		dbNulls, ok := NullsToDB[lowerNulls]
+		if !ok {
+			return "", "", InvalidOrderDirectionError.
+				WithData(InvalidOrderDirectionErrorData{
+					Field: o.Field,
+					Nulls: o.Nulls,
+				}).
+				WithMessage(fmt.Sprintf("invalid nulls order: %s", o.Nulls))
+		}
+		nulls = dbNulls
+	}
+
+	return direction, nulls, nil
+}
+
 // InvalidDatabaseSelectorTranslationErrorData is the data for the
 // InvalidDatabaseSelectorTranslationError error.
 type InvalidDatabaseSelectorTranslationErrorData struct {
@@ -353,8 +537,11 @@ func (s Selectors) AddSelector(
 }
 
 // ToDBSelectors converts a slice of API-level selectors to database selectors.
+// The selectors are translated in field name order. Each selector must use a
+// field from the field map and a predicate the field allows. Values are
+// coerced into the declared type of the field and IN and NOT IN value lists
+// are limited to the declared max length.
 //
-// Selectors
 // Parameters:
 //   - apiToDBFieldMap: A map translating API field names to their corresponding
 //     database field definitions.
@@ -369,7 +556,9 @@ func (s Selectors) ToDBSelectors(
 ) ([]database.Selector, error) {
 	var databaseSelectors []database.Selector
 
-	for field := range s {
+	fields := mapKeys(s)
+	sort.Strings(fields)
+	for _, field := range fields {
 		selector := s[field]
 
 		// Translate the predicate.
@@ -397,17 +586,128 @@ func (s Selectors) ToDBSelectors(
 				))
 		}
 
+		// Check the predicate is allowed for the field.
+		if dbField.Predicates != nil {
+			if _, ok := findPredicate(dbField.Predicates, lowerPredicate); !ok {
+				return nil, PredicateNotAllowedError.
+					WithData(
+						PredicateNotAllowedErrorData{
+							Predicate: selector.Predicate,
+						},
+					).
+					WithMessage(fmt.Sprintf(
+						"predicate %s not allowed for field %s",
+						selector.Predicate,
+						field,
+					))
+			}
+		}
+
+		value, err := dbField.selectorValue(
+			field, selector.Value, isListPredicate(lowerPredicate),
+		)
+		if err != nil {
+			return nil, err
+		}
+
 		databaseSelectors = append(databaseSelectors, database.Selector{
 			Table:     dbField.Table,
 			Column:    dbField.Column,
 			Predicate: dbPredicate,
-			Value:     selector.Value,
+			Value:     value,
 		})
 	}
 
 	return databaseSelectors, nil
 }
 
+// MaxInValuesExceededErrorData is the data for the MaxInValuesExceededError
+// error.
+type MaxInValuesExceededErrorData struct {
+	Field     string `json:"field"`
+	MaxValues int    `json:"max_values"`
+}
+
+// MaxInValuesExceededError is returned when an IN or NOT IN selector has too
+// many values.
+var MaxInValuesExceededError = core.NewAPIError("MAX_IN_VALUES_EXCEEDED")
+
+// selectorValue coerces a selector value into the type of the field. List
+// predicates receive a slice of values, which is limited to the max length.
+// Other predicates receive a single value, so slices and maps are rejected.
+func (f DBField) selectorValue(
+	field string, value any, isList bool,
+) (any, error) {
+	if !isList {
+		switch reflect.ValueOf(value).Kind() {
+		case reflect.Slice, reflect.Array, reflect.Map:
+			return nil, InvalidSelectorValueError.
+				WithData(InvalidSelectorValueErrorData{
+					Field: field,
+					Type:  f.Type,
+				}).
+				WithMessage(fmt.Sprintf(
+					"invalid value for field %s, expected a single value",
+					field,
+				))
+		}
+		return coerceSelectorValue(field, value, f.Type)
+	}
+
+	values := toValueSlice(value)
+	if f.MaxInValues > 0 && len(values) > f.MaxInValues {
+		return nil, MaxInValuesExceededError.
+			WithData(MaxInValuesExceededErrorData{
+				Field:     field,
+				MaxValues: f.MaxInValues,
+			}).
+			WithMessage(fmt.Sprintf(
+				"too many values for field %s, max %d", field, f.MaxInValues,
+			))
+	}
+	for i := range values {
+		coerced, err := coerceSelectorValue(field, values[i], f.Type)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = coerced
+	}
+	return values, nil
+}
+
+// toValueSlice converts a value into a slice of values. Non-slice values are
+// returned as a single element slice.
+func toValueSlice(value any) []any {
+	switch typed := value.(type) {
+	case []any:
+		return append([]any{}, typed...)
+	case []string:
+		values := make([]any, len(typed))
+		for i, v := range typed {
+			values[i] = v
+		}
+		return values
+	}
+	reflected := reflect.ValueOf(value)
+	if reflected.Kind() != reflect.Slice {
+		return []any{value}
+	}
+	values := make([]any, reflected.Len())
+	for i := range values {
+		values[i] = reflected.Index(i).Interface()
+	}
+	return values
+}
+
+// mapKeys returns the keys of a map.
+func mapKeys[K comparable, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
 // InvalidDatabaseUpdateTranslationErrorData is the data for the
 // InvalidDatabaseUpdateTranslationError error.
 type InvalidDatabaseUpdateTranslationErrorData struct {
@@ -422,24 +722,28 @@ var InvalidDatabaseUpdateTranslationError = core.NewAPIError("INVALID_DATABASE_U
 type Updates map[string]any
 
 // ToDBUpdates translates a list of updates to a database update list
-// and returns an error if the translation fails.
+// and returns an error if the translation fails. The updates are translated
+// in field name order. Read-only and create-only fields are rejected, use
+// ToDBUpdatesContext for updates coming from clients to also check the
+// fields that depend on the role.
 //
 // Parameters:
-//   - updates: The list of updates to translate.
 //   - apiToDBFieldMap: The mapping of API field names to database field names.
 //
 // Returns:
 //   - A list of database entity updates.
-//   - An error if any field translation fails.
+//   - A FieldsNotWritableError if any field is read-only or create-only, or
+//     an error if any field translation fails.
 func (updates Updates) ToDBUpdates(
 	apiToDBFieldMap map[string]DBField,
 ) ([]database.Update, error) {
-	var dbUpdates []database.Update
-
-	for field := range updates {
-		value := updates[field]
-
-		// Translate the field.
+	if err := updates.checkUpdatePolicies(apiToDBFieldMap); err != nil {
+		return nil, err
+	}
+	fields := mapKeys(updates)
+	sort.Strings(fields)
+	dbUpdates := make([]database.Update, 0, len(fields))
+	for _, field := range fields {
 		dbField, ok := apiToDBFieldMap[field]
 		if !ok {
 			return nil, InvalidDatabaseUpdateTranslationError.
@@ -450,12 +754,35 @@ func (updates Updates) ToDBUpdates(
 					"cannot translate field: %s", field,
 				))
 		}
-
 		dbUpdates = append(dbUpdates, database.Update{
 			Field: dbField.Column,
-			Value: value,
+			Value: updates[field],
 		})
 	}
 
 	return dbUpdates, nil
 }
+
+// ToDBUpdatesContext checks the updates with CheckWritable for an update
+// operation and translates them with ToDBUpdates. Fields that are unknown,
+// read-only, create-only or admin-only for the role in the context are
+// rejected.
+//
+// Parameters:
+//   - ctx: The request context carrying the role.
+//   - apiToDBFieldMap: The mapping of API field names to database field names.
+//
+// Returns:
+//   - A list of database entity updates.
+//   - A FieldsNotWritableError if any field cannot be written.
+func (updates Updates) ToDBUpdatesContext(
+	ctx context.Context,
+	apiToDBFieldMap map[string]DBField,
+) ([]database.Update, error) {
+	if err := updates.CheckWritable(
+		ctx, apiToDBFieldMap, WriteUpdate,
+	); err != nil {
+		return nil, err
+	}
+	return updates.ToDBUpdates(apiToDBFieldMap)
+}
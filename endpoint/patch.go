@@ -0,0 +1,190 @@
+package endpoint
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/pakkasys/fluidapi/core"
+)
+
+// Patch media types.
+const (
+	MergePatchContentType = "application/merge-patch+json"
+	JSONPatchContentType  = "application/json-patch+json"
+)
+
+// JSON Patch operations that can be translated into updates.
+const (
+	PatchAdd     = "add"
+	PatchReplace = "replace"
+	PatchRemove  = "remove"
+)
+
+// InvalidPatchErrorData is the data for the InvalidPatchError error.
+type InvalidPatchErrorData struct {
+	Op   string `json:"op,omitempty"`
+	Path string `json:"path,omitempty"`
+}
+
+// InvalidPatchError is returned when a patch document is malformed or uses
+// operations that cannot be translated into updates.
+var InvalidPatchError = core.NewAPIError("INVALID_PATCH")
+
+// PatchOperation is a JSON Patch (RFC 6902) operation.
+type PatchOperation struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	Value any    `json:"value,omitempty"`
+}
+
+// ParsePatchRequest parses the body of a patch request into updates. JSON
+// Patch documents are parsed with ToUpdates. JSON Merge Patch (RFC 7396) and
+// plain JSON documents are parsed as objects whose top-level members are the
+// updates. Explicit null values are kept as nil updates, which set the field
+// to NULL. Object values are merge patches of the current value of the
+// field, resolve them with MergeWith before translating the updates.
+//
+// Parameters:
+//   - r: The HTTP request.
+//
+// Returns:
+//   - Updates: The parsed updates.
+//   - error: An API error if the body cannot be parsed.
+func ParsePatchRequest(r *http.Request) (Updates, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case JSONPatchContentType:
+		var operations []PatchOperation
+		if err := DecodeJSON(r, &operations); err != nil {
+			return nil, err
+		}
+		return PatchOperations(operations).ToUpdates()
+	case MergePatchContentType, "application/json", "":
+		var updates Updates
+		if err := DecodeJSON(r, &updates); err != nil {
+			return nil, err
+		}
+		if updates == nil {
+			return nil, InvalidPatchError.WithMessage(
+				"merge patch must be an object",
+			)
+		}
+		return updates, nil
+	default:
+		return nil, InvalidPatchError.WithMessage(fmt.Sprintf(
+			"unsupported patch content type: %s", mediaType,
+		))
+	}
+}
+
+// PatchOperations is a JSON Patch document.
+type PatchOperations []PatchOperation
+
+// ToUpdates translates the operations into updates. Only "add", "replace"
+// and "remove" operations on top-level members are supported. A "remove"
+// operation sets the field to NULL. Later operations on the same field
+// override earlier ones.
+//
+// Returns:
+//   - Updates: The translated updates.
+//   - error: An InvalidPatchError if an operation is not supported.
+func (p PatchOperations) ToUpdates() (Updates, error) {
+	updates := Updates{}
+	for _, operation := range p {
+		field, ok := patchPathField(operation.Path)
+		if !ok {
+			return nil, invalidPatchOperationError(
+				operation, "unsupported path",
+			)
+		}
+		switch operation.Op {
+		case PatchAdd, PatchReplace:
+			updates[field] = operation.Value
+		case PatchRemove:
+			updates[field] = nil
+		default:
+			return nil, invalidPatchOperationError(
+				operation, "unsupported operation",
+			)
+		}
+	}
+	return updates, nil
+}
+
+// patchPathField returns the member name of a JSON Pointer with a single
+// reference token.
+func patchPathField(path string) (string, bool) {
+	token, ok := strings.CutPrefix(path, "/")
+	if !ok || token == "" || strings.Contains(token, "/") {
+		return "", false
+	}
+	token = strings.ReplaceAll(token, "~1", "/")
+	return strings.ReplaceAll(token, "~0", "~"), true
+}
+
+// invalidPatchOperationError returns an error for an unsupported operation.
+func invalidPatchOperationError(
+	operation PatchOperation, reason string,
+) error {
+	return InvalidPatchError.
+		WithData(InvalidPatchErrorData{
+			Op:   operation.Op,
+			Path: operation.Path,
+		}).
+		WithMessage(fmt.Sprintf(
+			"%s: %s %s", reason, operation.Op, operation.Path,
+		))
+}
+
+// MergeWith resolves the object values of a merge patch against the current
+// values of the fields. Object values are merged into the current value of
+// the field with MergePatch, other values are kept as they are.
+//
+// Parameters:
+//   - current: The current values of the fields by API field name.
+//
+// Returns:
+//   - Updates: The updates with the object values merged.
+func (updates Updates) MergeWith(current map[string]any) Updates {
+	merged := make(Updates, len(updates))
+	for field, value := range updates {
+		if _, ok := value.(map[string]any); ok {
+			value = MergePatch(current[field], value)
+		}
+		merged[field] = value
+	}
+	return merged
+}
+
+// MergePatch applies a JSON Merge Patch (RFC 7396) to a target value. If the
+// patch is an object, its members are merged recursively into the target
+// object and null members are removed from it. Any other patch replaces the
+// target. The target is not modified.
+//
+// Parameters:
+//   - target: The value to patch.
+//   - patch: The merge patch.
+//
+// Returns:
+//   - any: The patched value.
+func MergePatch(target any, patch any) any {
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	targetObject, _ := target.(map[string]any)
+	result := make(map[string]any, len(targetObject)+len(patchObject))
+	for name, value := range targetObject {
+		result[name] = value
+	}
+	for name, value := range patchObject {
+		if value == nil {
+			delete(result, name)
+			continue
+		}
+		result[name] = MergePatch(result[name], value)
+	}
+	return result
+}
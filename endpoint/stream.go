@@ -0,0 +1,227 @@
+package endpoint
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+)
+
+// StreamFormat is the format of a streamed list response.
+type StreamFormat string
+
+// Stream formats.
+const (
+	StreamJSON   StreamFormat = "json"   // A JSON array.
+	StreamNDJSON StreamFormat = "ndjson" // One JSON value per line.
+	StreamCSV    StreamFormat = "csv"    // CSV with a header record.
+)
+
+// streamContentTypes maps stream formats to their content types.
+var streamContentTypes = map[StreamFormat]string{
+	StreamJSON:   "application/json",
+	StreamNDJSON: "application/x-ndjson",
+	StreamCSV:    "text/csv; charset=utf-8",
+}
+
+// ContentType returns the content type of the stream format.
+//
+// Returns:
+//   - string: The content type, or an empty string for unknown formats.
+func (f StreamFormat) ContentType() string {
+	return streamContentTypes[f]
+}
+
+// CSVRecordFn converts an item into a CSV record, in the order of the header.
+type CSVRecordFn[T any] func(item T) ([]string, error)
+
+// StreamWriter writes items one at a time in a stream format, so that large
+// lists can be written without holding them in memory. Close must be called
+// after the last item to complete the output.
+type StreamWriter[T any] struct {
+	writer   io.Writer
+	format   StreamFormat
+	csv      *csv.Writer
+	header   []string
+	recordFn CSVRecordFn[T]
+	count    int
+	started  bool
+}
+
+// NewStreamWriter creates a new stream writer. CSV writers must be configured
+// with WithCSV.
+//
+// Parameters:
+//   - w: The writer of the output.
+//   - format: The stream format.
+//
+// Returns:
+//   - *StreamWriter[T]: A new stream writer.
+func NewStreamWriter[T any](w io.Writer, format StreamFormat) *StreamWriter[T] {
+	return &StreamWriter[T]{writer: w, format: format}
+}
+
+// WithCSV sets the header and the record conversion of CSV output.
+//
+// Parameters:
+//   - header: The header record.
+//   - recordFn: The function converting items into records.
+//
+// Returns:
+//   - *StreamWriter[T]: The stream writer.
+func (s *StreamWriter[T]) WithCSV(
+	header []string, recordFn CSVRecordFn[T],
+) *StreamWriter[T] {
+	s.header = header
+	s.recordFn = recordFn
+	return s
+}
+
+// Count returns the number of written items.
+//
+// Returns:
+//   - int: The number of written items.
+func (s *StreamWriter[T]) Count() int {
+	return s.count
+}
+
+// Write writes an item.
+//
+// Parameters:
+//   - item: The item to write.
+//
+// Returns:
+//   - error: An error if the item cannot be encoded or written.
+func (s *StreamWriter[T]) Write(item T) error {
+	if err := s.begin(); err != nil {
+		return err
+	}
+	switch s.format {
+	case StreamCSV:
+		record, err := s.recordFn(item)
+		if err != nil {
+			return fmt.Errorf("Write: %w", err)
+		}
+		if err := s.csv.Write(record); err != nil {
+			return fmt.Errorf("Write: %w", err)
+		}
+	default:
+		encoded, err := json.Marshal(item)
+		if err != nil {
+			return fmt.Errorf("Write: %w", err)
+		}
+		if s.format == StreamNDJSON {
+			encoded = append(encoded, '\n')
+		} else if s.count > 0 {
+			encoded = append([]byte(","), encoded...)
+		}
+		if _, err := s.writer.Write(encoded); err != nil {
+			return err
+		}
+	}
+	s.count++
+	return nil
+}
+
+// Flush flushes buffered CSV records to the writer.
+//
+// Returns:
+//   - error: An error if the records cannot be written.
+func (s *StreamWriter[T]) Flush() error {
+	if s.csv == nil {
+		return nil
+	}
+	s.csv.Flush()
+	return s.csv.Error()
+}
+
+// Close completes the output, e.g. by closing the JSON array.
+//
+// Returns:
+//   - error: An error if the output cannot be written.
+func (s *StreamWriter[T]) Close() error {
+	if err := s.begin(); err != nil {
+		return err
+	}
+	if s.format == StreamJSON {
+		if _, err := io.WriteString(s.writer, "]"); err != nil {
+			return err
+		}
+	}
+	return s.Flush()
+}
+
+// WriteAll writes all items of the iterator and closes the writer. If the
+// iterator yields an error, writing stops and the output is left incomplete,
+// e.g. a JSON array without its closing bracket, so that clients do not
+// mistake a truncated stream for a complete one.
+//
+// Parameters:
+//   - items: The iterator of the items.
+//
+// Returns:
+//   - error: An error if an item cannot be read, encoded or written.
+func (s *StreamWriter[T]) WriteAll(items iter.Seq2[T, error]) error {
+	for item, err := range items {
+		if err != nil {
+			_ = s.Flush()
+			return err
+		}
+		if err := s.Write(item); err != nil {
+			return err
+		}
+	}
+	return s.Close()
+}
+
+// begin writes the start of the output before the first item.
+func (s *StreamWriter[T]) begin() error {
+	if s.started {
+		return nil
+	}
+	switch s.format {
+	case StreamJSON:
+		if _, err := io.WriteString(s.writer, "["); err != nil {
+			return err
+		}
+	case StreamNDJSON:
+	case StreamCSV:
+		if s.recordFn == nil {
+			return fmt.Errorf("CSV stream has no record function")
+		}
+		s.csv = csv.NewWriter(s.writer)
+		if err := s.csv.Write(s.header); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown stream format: %s", s.format)
+	}
+	s.started = true
+	return nil
+}
+
+// MapItems returns an iterator converting the items of another iterator, e.g.
+// database entities into response items. Errors are passed through.
+//
+// Parameters:
+//   - items: The iterator of the items to convert.
+//   - fn: The conversion function.
+//
+// Returns:
+//   - iter.Seq2[T, error]: The iterator of the converted items.
+func MapItems[E any, T any](
+	items iter.Seq2[E, error], fn func(item E) T,
+) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for item, err := range items {
+			var mapped T
+			if err == nil {
+				mapped = fn(item)
+			}
+			if !yield(mapped, err) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pakkasys/fluidapi/core"
+	"github.com/pakkasys/fluidapi/endpoint"
+	"github.com/stretchr/testify/assert"
+)
+
+// serveWithLimits serves the request through the body limits middleware.
+func serveWithLimits(
+	limits endpoint.BodyLimits, body string, handler http.HandlerFunc,
+) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	endpoint.BodyLimitsMiddleware(limits)(handler).ServeHTTP(rec, req)
+	return rec
+}
+
+// decodeAPIError decodes an API error from the response body.
+func decodeAPIError(t *testing.T, rec *httptest.ResponseRecorder) core.APIError {
+	var apiError core.APIError
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &apiError))
+	return apiError
+}
+
+// TestBodyLimits_TooLarge tests that a body exceeding the maximum size is
+// rejected before the handler runs.
+func TestBodyLimits_TooLarge(t *testing.T) {
+	called := false
+	rec := serveWithLimits(
+		endpoint.BodyLimits{MaxBytes: 8},
+		`{"name":"too long"}`,
+		func(w http.ResponseWriter, r *http.Request) { called = true },
+	)
+
+	assert.False(t, called)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	apiError := decodeAPIError(t, rec)
+	assert.Equal(t, endpoint.RequestTooLargeError.ID, apiError.ID)
+	assert.Equal(t, map[string]any{"max_bytes": float64(8)}, apiError.Data)
+}
+
+// decodeWithLimits decodes the body with DecodeJSON behind the body limits
+// middleware and returns the decoding error.
+func decodeWithLimits(
+	limits endpoint.BodyLimits, req *http.Request, dst any,
+) error {
+	var decodeErr error
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		decodeErr = endpoint.DecodeJSON(r, dst)
+	}
+	endpoint.BodyLimitsMiddleware(limits)(http.HandlerFunc(handler)).
+		ServeHTTP(httptest.NewRecorder(), req)
+	return decodeErr
+}
+
+// TestBodyLimits_TooDeep tests that a JSON body exceeding the maximum depth is
+// rejected before the handler runs.
+func TestBodyLimits_TooDeep(t *testing.T) {
+	called := false
+	rec := serveWithLimits(
+		endpoint.BodyLimits{MaxDepth: 2},
+		`{"a":{"b":{"c":1}}}`,
+		func(w http.ResponseWriter, r *http.Request) { called = true },
+	)
+
+	assert.False(t, called)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	apiError := decodeAPIError(t, rec)
+	assert.Equal(t, endpoint.InvalidJSONError.ID, apiError.ID)
+	assert.Equal(
+		t,
+		map[string]any{"offset": float64(11), "max_depth": float64(2)},
+		apiError.Data,
+	)
+}
+
+// TestBodyLimits_TooDeepStopsReading tests that the depth scan stops reading
+// the body at the first token nested too deep.
+func TestBodyLimits_TooDeepStopsReading(t *testing.T) {
+	body := &countingReader{
+		reader: strings.NewReader(`[[[1]]]` + strings.Repeat(" ", 1<<20)),
+	}
+	req := httptest.NewRequest(http.MethodPost, "/", body)
+	req.Header.Set("Content-Type", "application/json")
+	called := false
+	handler := func(w http.ResponseWriter, r *http.Request) { called = true }
+
+	endpoint.BodyLimitsMiddleware(endpoint.BodyLimits{MaxDepth: 2})(
+		http.HandlerFunc(handler),
+	).ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.False(t, called)
+	assert.Less(t, body.read, 1<<20)
+}
+
+// TestBodyLimits_WithinDepth tests that a JSON body within the maximum depth
+// reaches the handler intact.
+func TestBodyLimits_WithinDepth(t *testing.T) {
+	var input map[string]any
+	var decodeErr error
+	rec := serveWithLimits(
+		endpoint.BodyLimits{MaxBytes: 1024, MaxDepth: 3},
+		`{"a":{"b":[]}}`,
+		func(w http.ResponseWriter, r *http.Request) {
+			decodeErr = endpoint.DecodeJSON(r, &input)
+		},
+	)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NoError(t, decodeErr)
+	assert.Equal(t, map[string]any{"a": map[string]any{"b": []any{}}}, input)
+}
+
+// TestBodyLimits_InvalidJSON tests that a malformed JSON body is rejected
+// before the handler runs when the depth is limited.
+func TestBodyLimits_InvalidJSON(t *testing.T) {
+	called := false
+	rec := serveWithLimits(
+		endpoint.BodyLimits{MaxDepth: 2},
+		`{"a":}`,
+		func(w http.ResponseWriter, r *http.Request) { called = true },
+	)
+
+	assert.False(t, called)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, endpoint.InvalidJSONError.ID, decodeAPIError(t, rec).ID)
+}
+
+// countingReader counts the bytes read from the underlying reader.
+type countingReader struct {
+	reader io.Reader
+	read   int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.read += n
+	return n, err
+}
+
+// TestBodyLimits_TooDeepWithoutContentType tests that the depth limit does
+// not depend on the content type of the request.
+func TestBodyLimits_TooDeepWithoutContentType(t *testing.T) {
+	req := httptest.NewRequest(
+		http.MethodPost, "/", strings.NewReader(`[[[[1]]]]`),
+	)
+
+	var input any
+	err := decodeWithLimits(endpoint.BodyLimits{MaxDepth: 3}, req, &input)
+
+	apiError, ok := err.(*core.APIError)
+	assert.True(t, ok)
+	assert.Equal(t, endpoint.InvalidJSONError.ID, apiError.ID)
+}
+
+// TestBodyLimits_DepthIgnoresStrings tests that brackets in strings do not
+// count towards the depth.
+func TestBodyLimits_DepthIgnoresStrings(t *testing.T) {
+	req := httptest.NewRequest(
+		http.MethodPost, "/", strings.NewReader(`{"a":"[[{\"[{"}`),
+	)
+
+	var input map[string]string
+	err := decodeWithLimits(endpoint.BodyLimits{MaxDepth: 1}, req, &input)
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"a": `[[{"[{`}, input)
+}
+
+// TestBodyLimits_TooLargeWithoutLength tests that a body without a content
+// length fails when it is read past the maximum size.
+func TestBodyLimits_TooLargeWithoutLength(t *testing.T) {
+	req := httptest.NewRequest(
+		http.MethodPost, "/", strings.NewReader(`{"name":"too long"}`),
+	)
+	req.ContentLength = -1
+
+	var input map[string]any
+	err := decodeWithLimits(endpoint.BodyLimits{MaxBytes: 8}, req, &input)
+
+	apiError, ok := err.(*core.APIError)
+	assert.True(t, ok)
+	assert.Equal(t, endpoint.RequestTooLargeError.ID, apiError.ID)
+}
+
+// TestBodyLimits_UnknownField tests that DecodeJSON rejects unknown fields
+// when the endpoint disallows them.
+func TestBodyLimits_UnknownField(t *testing.T) {
+	var decodeErr error
+	serveWithLimits(
+		endpoint.BodyLimits{MaxBytes: 1024, DisallowUnknownFields: true},
+		`{"name":"a","extra":1}`,
+		func(w http.ResponseWriter, r *http.Request) {
+			var input struct {
+				Name string `json:"name"`
+			}
+			decodeErr = endpoint.DecodeJSON(r, &input)
+		},
+	)
+
+	apiError, ok := decodeErr.(*core.APIError)
+	assert.True(t, ok)
+	assert.Equal(t, endpoint.InvalidJSONError.ID, apiError.ID)
+	assert.Equal(
+		t,
+		endpoint.InvalidJSONErrorData{UnknownField: "extra"},
+		apiError.Data,
+	)
+}
+
+// TestBodyLimits_WithinLimits tests that a body within the limits is passed
+// to the handler.
+func TestBodyLimits_WithinLimits(t *testing.T) {
+	var input struct {
+		Name string `json:"name"`
+	}
+	rec := serveWithLimits(
+		endpoint.BodyLimits{MaxBytes: 1024, MaxDepth: 4},
+		`{"name":"a"}`,
+		func(w http.ResponseWriter, r *http.Request) {
+			assert.NoError(t, endpoint.DecodeJSON(r, &input))
+		},
+	)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "a", input.Name)
+}
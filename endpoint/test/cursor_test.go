@@ -0,0 +1,162 @@
+package test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/pakkasys/fluidapi/core"
+	"github.com/pakkasys/fluidapi/database"
+	"github.com/pakkasys/fluidapi/endpoint"
+	"github.com/stretchr/testify/assert"
+)
+
+type cursorRow struct {
+	ID   int64
+	Name string
+}
+
+// cursorFieldMap is the field map used by the cursor tests.
+var cursorFieldMap = map[string]endpoint.DBField{
+	"id":   {Table: "user", Column: "id", Type: endpoint.FieldTypeInt},
+	"name": {Table: "user", Column: "name", Type: endpoint.FieldTypeString},
+}
+
+// cursorOrders are the orders used by the cursor tests.
+var cursorOrders = endpoint.Orders{
+	endpoint.NewOrder("name", endpoint.DirectionDesc),
+	endpoint.NewOrder("id", endpoint.DirectionAsc),
+}
+
+// cursorSecret is the secret used by the cursor tests.
+var cursorSecret = []byte("0123456789abcdef0123456789abcdef")
+
+// cursorKey returns the order values of a row.
+func cursorKey(row cursorRow) []any {
+	return []any{row.Name, row.ID}
+}
+
+// TestCursor_RoundTrip tests that a next cursor is translated into a seek on
+// the last row of the page.
+func TestCursor_RoundTrip(t *testing.T) {
+	codec := endpoint.NewCursorCodec(cursorSecret)
+	rows := []cursorRow{{ID: 1, Name: "c"}, {ID: 2, Name: "b"}, {ID: 3, Name: "a"}}
+
+	result, err := endpoint.NewCursorResult(
+		codec, rows, 2, nil, cursorOrders, cursorKey,
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, rows[:2], result.Items)
+	assert.Nil(t, result.Prev)
+	assert.NotNil(t, result.Next)
+
+	seek, dbPage, err := codec.ToDBSeek(
+		&endpoint.Page{Limit: 2, Cursor: *result.Next},
+		cursorOrders,
+		cursorFieldMap,
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, &database.Page{Limit: 3}, dbPage)
+	assert.Equal(t, database.NewSeek([]any{"b", int64(2)}, false), seek)
+
+	dbOrders, err := cursorOrders.TranslateToDBOrders(cursorFieldMap)
+	assert.NoError(t, err)
+	groups, err := seek.Selectors(dbOrders)
+	assert.NoError(t, err)
+	assert.Equal(t, []database.Selectors{
+		{
+			{Table: "user", Column: "name", Predicate: database.Less, Value: "b"},
+		},
+		{
+			{Table: "user", Column: "name", Predicate: database.Equal, Value: "b"},
+			{Table: "user", Column: "id", Predicate: database.Greater, Value: int64(2)},
+		},
+	}, groups)
+}
+
+// TestCursor_Backward tests that rows of a backward page are reversed and get
+// both cursors.
+func TestCursor_Backward(t *testing.T) {
+	codec := endpoint.NewCursorCodec(cursorSecret)
+	rows := []cursorRow{{ID: 3, Name: "a"}, {ID: 2, Name: "b"}}
+
+	result, err := endpoint.NewCursorResult(
+		codec,
+		rows,
+		2,
+		database.NewSeek([]any{"z", int64(9)}, true),
+		cursorOrders,
+		cursorKey,
+	)
+
+	assert.NoError(t, err)
+	assert.Equal(
+		t, []cursorRow{{ID: 2, Name: "b"}, {ID: 3, Name: "a"}}, result.Items,
+	)
+	assert.NotNil(t, result.Next)
+	assert.Nil(t, result.Prev)
+}
+
+// TestCursor_LargeInteger tests that integer keys beyond 2^53 survive the
+// cursor round trip exactly.
+func TestCursor_LargeInteger(t *testing.T) {
+	codec := endpoint.NewCursorCodec(cursorSecret)
+	const id = int64(1<<53 + 1)
+	rows := []cursorRow{{ID: id, Name: "a"}, {ID: id + 2, Name: "a"}}
+
+	result, err := endpoint.NewCursorResult(
+		codec, rows, 1, nil, cursorOrders, cursorKey,
+	)
+	assert.NoError(t, err)
+
+	seek, _, err := codec.ToDBSeek(
+		&endpoint.Page{Limit: 1, Cursor: *result.Next},
+		cursorOrders,
+		cursorFieldMap,
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, database.NewSeek([]any{"a", id}, false), seek)
+}
+
+// TestCursor_Invalid tests that tampered cursors and cursors for other orders
+// are rejected.
+func TestCursor_Invalid(t *testing.T) {
+	codec := endpoint.NewCursorCodec(cursorSecret)
+	cursor, err := codec.Encode(endpoint.Cursor{
+		Values: []any{"b", 2},
+		Orders: "+name,+id",
+	})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{name: "Tampered", cursor: "x" + cursor},
+		{name: "Malformed", cursor: "abc"},
+		{name: "Other orders", cursor: cursor},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := codec.ToDBSeek(
+				&endpoint.Page{Limit: 2, Cursor: tt.cursor},
+				cursorOrders,
+				cursorFieldMap,
+			)
+
+			var apiError *core.APIError
+			assert.True(t, errors.As(err, &apiError))
+			assert.Equal(t, endpoint.InvalidCursorError.ID, apiError.ID)
+		})
+	}
+}
+
+// TestNewCursorCodec_ShortSecret tests that secrets shorter than the minimum
+// size are rejected when the codec is created.
+func TestNewCursorCodec_ShortSecret(t *testing.T) {
+	assert.Panics(t, func() { endpoint.NewCursorCodec([]byte("secret")) })
+	assert.Panics(t, func() {
+		endpoint.NewCursorCodec(cursorSecret[:endpoint.MinCursorSecretSize-1])
+	})
+	assert.NotPanics(t, func() { endpoint.NewCursorCodec(cursorSecret) })
+}
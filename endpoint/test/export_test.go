@@ -0,0 +1,296 @@
+package test
+
+import (
+	"context"
+	"iter"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pakkasys/fluidapi/core"
+	"github.com/pakkasys/fluidapi/database"
+	"github.com/pakkasys/fluidapi/endpoint"
+	"github.com/stretchr/testify/assert"
+)
+
+// exportUser is an item of the export tests.
+type exportUser struct {
+	ID    int     `json:"id"`
+	Name  string  `json:"name"`
+	Email *string `json:"email"`
+}
+
+// newTestExporter creates an exporter of users.
+func newTestExporter() *endpoint.Exporter {
+	parser := endpoint.NewListParser(
+		map[string]endpoint.Predicates{"id": {endpoint.Gt}},
+		[]string{"id", "name"},
+		10,
+	)
+	parser.Fields = []string{"id", "name", "email"}
+	return endpoint.NewExporter(parser, map[string]endpoint.DBField{
+		"id": {
+			Table: "user", Column: "id", Type: endpoint.FieldTypeInt,
+		},
+		"name":  {Table: "user", Column: "name"},
+		"email": {Table: "user", Column: "email"},
+	})
+}
+
+// exportUsers returns an iterator of test users.
+func exportUsers(count int) iter.Seq2[exportUser, error] {
+	email := "ada@example.com"
+	return func(yield func(exportUser, error) bool) {
+		for i := range count {
+			user := exportUser{ID: i + 1, Name: "Ada, L"}
+			if i == 0 {
+				user.Email = &email
+			}
+			if !yield(user, nil) {
+				return
+			}
+		}
+	}
+}
+
+// TestExporter_NegotiateFormat tests the selection of the export format.
+func TestExporter_NegotiateFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		accept   string
+		expected endpoint.StreamFormat
+		err      bool
+	}{
+		{"default", "/users", "", endpoint.StreamCSV, false},
+		{"param", "/users?format=NDJSON", "text/csv", endpoint.StreamNDJSON, false},
+		{"accept", "/users", "application/x-ndjson", endpoint.StreamNDJSON, false},
+		{
+			"quality",
+			"/users",
+			"text/csv;q=0.5, application/x-ndjson",
+			endpoint.StreamNDJSON,
+			false,
+		},
+		{"any", "/users", "text/html, */*;q=0.1", endpoint.StreamCSV, false},
+		{"unsupported accept", "/users", "text/html", "", true},
+		{"unsupported param", "/users?format=xml", "", "", true},
+		{"not allowed", "/users?format=json", "", "", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, test.url, nil)
+			if test.accept != "" {
+				r.Header.Set("Accept", test.accept)
+			}
+
+			format, err := newTestExporter().NegotiateFormat(r)
+
+			if test.err {
+				apiError, ok := err.(*core.APIError)
+				assert.True(t, ok)
+				assert.Equal(t, endpoint.UnsupportedFormatError.ID, apiError.ID)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, format)
+		})
+	}
+}
+
+// TestExporter_ParseRequest tests that exports are parsed like lists without
+// pagination.
+func TestExporter_ParseRequest(t *testing.T) {
+	r := httptest.NewRequest(
+		http.MethodGet, "/users?filter[id][gt]=5&sort=-name&limit=1000", nil,
+	)
+
+	query, err := newTestExporter().ParseRequest(r)
+
+	assert.NoError(t, err)
+	assert.Equal(t, endpoint.StreamCSV, query.Format)
+	assert.Equal(t, endpoint.Fields{"id", "name", "email"}, query.Fields)
+	assert.False(t, query.Sparse)
+	assert.Equal(t, []database.Selector{{
+		Table:     "user",
+		Column:    "id",
+		Predicate: database.Greater,
+		Value:     int64(5),
+	}}, query.Selectors)
+	assert.Equal(t, "name", query.Orders[0].Field)
+	assert.Nil(t, query.GetOptions().Page)
+}
+
+// TestExportHandler_CSV tests that CSV exports have a header from the fields
+// and are flushed.
+func TestExportHandler_CSV(t *testing.T) {
+	exporter := newTestExporter()
+	exporter.Filename = "users"
+	exporter.FlushRows = 1
+	handler := endpoint.ExportHandler(
+		exporter,
+		func(
+			_ context.Context, _ *endpoint.ExportQuery,
+		) iter.Seq2[exportUser, error] {
+			return exportUsers(2)
+		},
+	)
+	w := httptest.NewRecorder()
+
+	handler(w, httptest.NewRequest(http.MethodGet, "/users", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(
+		t,
+		"attachment; filename=users.csv",
+		w.Header().Get("Content-Disposition"),
+	)
+	assert.Equal(
+		t,
+		"id,name,email\n1,\"Ada, L\",ada@example.com\n2,\"Ada, L\",\n",
+		w.Body.String(),
+	)
+	assert.True(t, w.Flushed)
+}
+
+// TestExportHandler_SparseNDJSON tests that NDJSON exports only contain the
+// requested fields.
+func TestExportHandler_SparseNDJSON(t *testing.T) {
+	var projections database.Projections
+	handler := endpoint.ExportHandler(
+		newTestExporter(),
+		func(
+			_ context.Context, query *endpoint.ExportQuery,
+		) iter.Seq2[exportUser, error] {
+			projections = query.Projections
+			return exportUsers(1)
+		},
+	)
+	w := httptest.NewRecorder()
+
+	handler(w, httptest.NewRequest(
+		http.MethodGet, "/users?format=ndjson&fields=name", nil,
+	))
+
+	assert.Equal(t, "{\"name\":\"Ada, L\"}\n", w.Body.String())
+	assert.Equal(
+		t,
+		database.Projections{{Table: "user", Column: "name"}},
+		projections,
+	)
+}
+
+// TestExportHandler_InvalidRequest tests that request errors are written as
+// API errors.
+func TestExportHandler_InvalidRequest(t *testing.T) {
+	handler := endpoint.ExportHandler(
+		newTestExporter(),
+		func(
+			_ context.Context, _ *endpoint.ExportQuery,
+		) iter.Seq2[exportUser, error] {
+			t.Fatal("query called")
+			return nil
+		},
+	)
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/users", nil)
+	r.Header.Set("Accept", "text/html")
+
+	handler(w, r)
+
+	assert.Equal(t, http.StatusNotAcceptable, w.Code)
+}
+
+// TestExportHandler_Disconnect tests that exports stop when the client
+// disconnects.
+func TestExportHandler_Disconnect(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	exporter := newTestExporter()
+	var exportErr error
+	exporter.OnError = func(_ *http.Request, err error) {
+		exportErr = err
+	}
+	yielded := 0
+	handler := endpoint.ExportHandler(
+		exporter,
+		func(
+			_ context.Context, _ *endpoint.ExportQuery,
+		) iter.Seq2[exportUser, error] {
+			return func(yield func(exportUser, error) bool) {
+				for user := range exportUsers(100) {
+					yielded++
+					if yielded == 2 {
+						cancel()
+					}
+					if !yield(user, nil) {
+						return
+					}
+				}
+			}
+		},
+	)
+	w := httptest.NewRecorder()
+
+	handler(w, httptest.NewRequest(http.MethodGet, "/users", nil).
+		WithContext(ctx))
+
+	assert.ErrorIs(t, exportErr, context.Canceled)
+	assert.Equal(t, 2, yielded)
+}
+
+// TestExportHandler_FirstItemError tests that an error of the first item is
+// written with an error status instead of an empty export.
+func TestExportHandler_FirstItemError(t *testing.T) {
+	exporter := newTestExporter()
+	var exportErr error
+	exporter.OnError = func(_ *http.Request, err error) {
+		exportErr = err
+	}
+	handler := endpoint.ExportHandler(
+		exporter,
+		func(
+			_ context.Context, _ *endpoint.ExportQuery,
+		) iter.Seq2[exportUser, error] {
+			return func(yield func(exportUser, error) bool) {
+				yield(exportUser{}, assert.AnError)
+			}
+		},
+	)
+	w := httptest.NewRecorder()
+
+	handler(w, httptest.NewRequest(http.MethodGet, "/users", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Empty(t, w.Header().Get("Content-Disposition"))
+	assert.ErrorIs(t, exportErr, assert.AnError)
+}
+
+// TestExportHandler_CSVFormula tests that CSV values that would be evaluated
+// as formulas are escaped.
+func TestExportHandler_CSVFormula(t *testing.T) {
+	handler := endpoint.ExportHandler(
+		newTestExporter(),
+		func(
+			_ context.Context, _ *endpoint.ExportQuery,
+		) iter.Seq2[exportUser, error] {
+			return func(yield func(exportUser, error) bool) {
+				for _, name := range []string{"=1+1", "+a", "-a", "@a", "a="} {
+					if !yield(exportUser{ID: -1, Name: name}, nil) {
+						return
+					}
+				}
+			}
+		},
+	)
+	w := httptest.NewRecorder()
+
+	handler(w, httptest.NewRequest(http.MethodGet, "/users", nil))
+
+	assert.Equal(
+		t,
+		"id,name,email\n-1,'=1+1,\n-1,'+a,\n-1,'-a,\n-1,'@a,\n-1,a=,\n",
+		w.Body.String(),
+	)
+}
@@ -0,0 +1,76 @@
+package test
+
+import (
+	"encoding/json"
+	"net/url"
+	"testing"
+
+	"github.com/pakkasys/fluidapi/core"
+	"github.com/pakkasys/fluidapi/database"
+	"github.com/pakkasys/fluidapi/endpoint"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestListParser_Fields tests that the fields parameter is parsed and checked
+// against the allowed fields.
+func TestListParser_Fields(t *testing.T) {
+	parser := endpoint.NewListParser(nil, nil, 0)
+	parser.Fields = []string{"id", "name"}
+
+	listQuery, err := parser.ParseQuery(url.Values{"fields": {"name, id,name"}})
+	assert.NoError(t, err)
+	assert.Equal(t, endpoint.Fields{"name", "id"}, listQuery.Fields)
+
+	_, err = parser.ParseQuery(url.Values{"fields": {"id,password"}})
+	var apiErr *core.APIError
+	assert.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, endpoint.InvalidProjectionFieldError.ID, apiErr.ID)
+	assert.Equal(
+		t,
+		endpoint.InvalidProjectionFieldErrorData{Field: "password"},
+		apiErr.Data,
+	)
+}
+
+// TestFields_ToDBProjections tests that fields are translated through the
+// field map and unknown fields are rejected.
+func TestFields_ToDBProjections(t *testing.T) {
+	fieldMap := map[string]endpoint.DBField{
+		"id":   {Table: "user", Column: "id"},
+		"name": {Table: "user", Column: "full_name"},
+	}
+
+	projections, err := endpoint.Fields{"name", "id"}.ToDBProjections(fieldMap)
+	assert.NoError(t, err)
+	assert.Equal(t, database.Projections{
+		{Table: "user", Column: "full_name"},
+		{Table: "user", Column: "id"},
+	}, projections)
+
+	projections, err = endpoint.Fields{}.ToDBProjections(fieldMap)
+	assert.NoError(t, err)
+	assert.Nil(t, projections)
+
+	_, err = endpoint.Fields{"age"}.ToDBProjections(fieldMap)
+	var apiErr *core.APIError
+	assert.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, endpoint.InvalidProjectionFieldError.ID, apiErr.ID)
+}
+
+// TestSparse_MarshalJSON tests that only the requested fields are emitted.
+func TestSparse_MarshalJSON(t *testing.T) {
+	type user struct {
+		ID    int    `json:"id"`
+		Name  string `json:"name"`
+		Email string `json:"email"`
+	}
+	users := []user{{ID: 1, Name: "a", Email: "a@example.com"}}
+
+	data, err := json.Marshal(endpoint.SparseItems(users, endpoint.Fields{"id"}))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[{"id":1}]`, string(data))
+
+	data, err = json.Marshal(endpoint.SparseItems(users, nil))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[{"id":1,"name":"a","email":"a@example.com"}]`, string(data))
+}
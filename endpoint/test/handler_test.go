@@ -0,0 +1,200 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pakkasys/fluidapi/core"
+	"github.com/pakkasys/fluidapi/endpoint"
+	"github.com/stretchr/testify/assert"
+)
+
+type handlerInput struct {
+	ID      int      `path:"id"`
+	Tags    []string `query:"tag"`
+	Limit   *int     `query:"limit"`
+	TraceID string   `header:"X-Trace-ID"`
+	Name    string   `json:"name"`
+}
+
+type handlerOutput struct {
+	ID      int      `json:"id"`
+	Tags    []string `json:"tags"`
+	Limit   int      `json:"limit"`
+	TraceID string   `json:"trace_id"`
+	Name    string   `json:"name"`
+}
+
+// serveHandler serves the request through a mux so that path values are set.
+func serveHandler(
+	pattern string, handler http.HandlerFunc, req *http.Request,
+) *httptest.ResponseRecorder {
+	mux := http.NewServeMux()
+	mux.Handle(pattern, handler)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	return rec
+}
+
+// TestHandle_DecodesInputAndEncodesOutput tests that the input is populated
+// from all sources and the output is encoded with the configured status.
+func TestHandle_DecodesInputAndEncodesOutput(t *testing.T) {
+	handler := endpoint.Handle(
+		func(ctx context.Context, in *handlerInput) (*handlerOutput, error) {
+			return &handlerOutput{
+				ID:      in.ID,
+				Tags:    in.Tags,
+				Limit:   *in.Limit,
+				TraceID: in.TraceID,
+				Name:    in.Name,
+			}, nil
+		},
+		endpoint.WithStatusCode(http.StatusCreated),
+	)
+	req := httptest.NewRequest(
+		http.MethodPost,
+		"/users/42?tag=a&tag=b&limit=10",
+		strings.NewReader(`{"name":"Alice"}`),
+	)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Trace-ID", "trace")
+
+	rec := serveHandler("/users/{id}", handler, req)
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	var output handlerOutput
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &output))
+	assert.Equal(t, handlerOutput{
+		ID:      42,
+		Tags:    []string{"a", "b"},
+		Limit:   10,
+		TraceID: "trace",
+		Name:    "Alice",
+	}, output)
+}
+
+// TestHandle_InvalidParameter tests that a parameter that cannot be converted
+// is rejected with an InvalidParameterError.
+func TestHandle_InvalidParameter(t *testing.T) {
+	called := false
+	handler := endpoint.Handle(
+		func(ctx context.Context, in *handlerInput) (*handlerOutput, error) {
+			called = true
+			return nil, nil
+		},
+	)
+	req := httptest.NewRequest(http.MethodGet, "/users/abc", nil)
+
+	rec := serveHandler("/users/{id}", handler, req)
+
+	assert.False(t, called)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	apiError := decodeAPIError(t, rec)
+	assert.Equal(t, endpoint.InvalidParameterError.ID, apiError.ID)
+	assert.Equal(
+		t,
+		map[string]any{"source": "path", "name": "id"},
+		apiError.Data,
+	)
+}
+
+// TestHandle_ParametersNotSetFromBody tests that fields with parameter tags
+// cannot be set from the body.
+func TestHandle_ParametersNotSetFromBody(t *testing.T) {
+	var input *handlerInput
+	handler := endpoint.Handle(
+		func(ctx context.Context, in *handlerInput) (*handlerOutput, error) {
+			input = in
+			return nil, nil
+		},
+	)
+	req := httptest.NewRequest(
+		http.MethodPost,
+		"/users/42",
+		strings.NewReader(
+			`{"ID":1,"Tags":["admin"],"TraceID":"forged","name":"Alice"}`,
+		),
+	)
+	req.Header.Set("Content-Type", "application/json")
+
+	rec := serveHandler("/users/{id}", handler, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, &handlerInput{ID: 42, Name: "Alice"}, input)
+}
+
+// TestHandle_UnsupportedMediaType tests that bodies without a JSON content
+// type are rejected.
+func TestHandle_UnsupportedMediaType(t *testing.T) {
+	called := false
+	handler := endpoint.Handle(
+		func(ctx context.Context, in *handlerInput) (*handlerOutput, error) {
+			called = true
+			return nil, nil
+		},
+	)
+	req := httptest.NewRequest(
+		http.MethodPost, "/users/42", strings.NewReader(`{"name":"Alice"}`),
+	)
+	req.Header.Set("Content-Type", "text/plain")
+
+	rec := serveHandler("/users/{id}", handler, req)
+
+	assert.False(t, called)
+	assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+	apiError := decodeAPIError(t, rec)
+	assert.Equal(t, endpoint.UnsupportedMediaTypeError.ID, apiError.ID)
+	assert.Equal(
+		t, map[string]any{"content_type": "text/plain"}, apiError.Data,
+	)
+}
+
+// TestHandle_Errors tests that API errors are rendered with their status and
+// other errors are hidden behind an InternalServerError.
+func TestHandle_Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		expectedID string
+		statusCode int
+	}{
+		{
+			name:       "API error",
+			err:        endpoint.MaxPageLimitExceededError,
+			expectedID: endpoint.MaxPageLimitExceededError.ID,
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "Wrapped API error",
+			err:        errors.Join(core.RequestTimeoutError),
+			expectedID: core.RequestTimeoutError.ID,
+			statusCode: http.StatusServiceUnavailable,
+		},
+		{
+			name:       "Other error",
+			err:        errors.New("database is down"),
+			expectedID: endpoint.InternalServerError.ID,
+			statusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := endpoint.Handle(
+				func(ctx context.Context, in *struct{}) (*struct{}, error) {
+					return nil, tt.err
+				},
+			)
+			rec := httptest.NewRecorder()
+			handler(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			assert.Equal(t, tt.statusCode, rec.Code)
+			assert.Equal(t, tt.expectedID, decodeAPIError(t, rec).ID)
+		})
+	}
+}
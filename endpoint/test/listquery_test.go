@@ -0,0 +1,158 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/pakkasys/fluidapi/core"
+	"github.com/pakkasys/fluidapi/endpoint"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestListParser returns a list parser for the tests.
+func newTestListParser() *endpoint.ListParser {
+	return endpoint.NewListParser(
+		map[string]endpoint.Predicates{
+			"age":    endpoint.OnlyGreaterPredicates,
+			"status": endpoint.OnlyInAndNotInPredicates,
+			"name":   endpoint.OnlyEqualPredicates,
+		},
+		[]string{"created_at", "name"},
+		100,
+	)
+}
+
+// TestParseQuery_Valid tests parsing valid list query parameters.
+func TestParseQuery_Valid(t *testing.T) {
+	values, _ := url.ParseQuery(
+		"filter[age][GT]=30&filter[status][in]=a,b&filter[name]=bob" +
+			"&sort=-created_at:nulls_last,name&offset=10&limit=50",
+	)
+
+	listQuery, err := newTestListParser().ParseQuery(values)
+
+	assert.NoError(t, err)
+	assert.Equal(t, endpoint.Selectors{
+		"age":    {Predicate: endpoint.Gt, Value: "30"},
+		"status": {Predicate: endpoint.In, Value: []string{"a", "b"}},
+		"name":   {Predicate: endpoint.Eq, Value: "bob"},
+	}, listQuery.Selectors)
+	assert.Equal(t, endpoint.Orders{
+		endpoint.NewOrder("created_at", endpoint.DirectionDesc).
+			WithNulls(endpoint.NullsLast),
+		endpoint.NewOrder("name", endpoint.DirectionAsc),
+	}, listQuery.Orders)
+	assert.Equal(t, &endpoint.Page{Offset: 10, Limit: 50}, listQuery.Page)
+}
+
+// TestParseQuery_Errors tests that disallowed list query parameters are
+// rejected with the matching API errors.
+func TestParseQuery_Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		expectedID string
+	}{
+		{
+			name:       "Unknown field",
+			query:      "filter[email]=a",
+			expectedID: endpoint.InvalidSelectorFieldError.ID,
+		},
+		{
+			name:       "Predicate not allowed",
+			query:      "filter[age][lt]=30",
+			expectedID: endpoint.PredicateNotAllowedError.ID,
+		},
+		{
+			name:       "Invalid predicate",
+			query:      "filter[age][between]=30",
+			expectedID: endpoint.InvalidPredicateError.ID,
+		},
+		{
+			name:       "Sort field not allowed",
+			query:      "sort=age",
+			expectedID: endpoint.InvalidOrderFieldError.ID,
+		},
+		{
+			name:       "Invalid nulls order",
+			query:      "sort=name:nulls_middle",
+			expectedID: endpoint.InvalidOrderDirectionError.ID,
+		},
+		{
+			name:       "Max limit exceeded",
+			query:      "limit=101",
+			expectedID: endpoint.MaxPageLimitExceededError.ID,
+		},
+		{
+			name:       "Invalid limit",
+			query:      "limit=ten",
+			expectedID: endpoint.InvalidParameterError.ID,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, _ := url.ParseQuery(tt.query)
+
+			_, err := newTestListParser().ParseQuery(values)
+
+			apiError, ok := err.(*core.APIError)
+			assert.True(t, ok)
+			assert.Equal(t, tt.expectedID, apiError.ID)
+		})
+	}
+}
+
+// TestParseQuery_NegativePage tests that negative offsets and limits name
+// their own parameter.
+func TestParseQuery_NegativePage(t *testing.T) {
+	for _, param := range []string{
+		endpoint.OffsetParam, endpoint.LimitParam,
+	} {
+		t.Run(param, func(t *testing.T) {
+			values := url.Values{param: {"-1"}}
+
+			_, err := newTestListParser().ParseQuery(values)
+
+			apiError, ok := err.(*core.APIError)
+			assert.True(t, ok)
+			assert.Equal(t, endpoint.InvalidParameterError.ID, apiError.ID)
+			assert.Equal(
+				t,
+				endpoint.InvalidParameterErrorData{
+					Source: endpoint.SourceQuery,
+					Name:   param,
+				},
+				apiError.Data,
+			)
+		})
+	}
+}
+
+// TestParseRequest_JSONBody tests parsing a list query from a JSON body.
+func TestParseRequest_JSONBody(t *testing.T) {
+	req := httptest.NewRequest(
+		http.MethodPost,
+		"/users/search",
+		strings.NewReader(
+			`{"selectors":{"age":{"predicate":"ge","value":30}},`+
+				`"orders":[{"field":"name","direction":"desc"}]}`,
+		),
+	)
+
+	listQuery, err := newTestListParser().ParseRequest(req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, endpoint.Selectors{
+		"age": {Predicate: endpoint.Ge, Value: float64(30)},
+	}, listQuery.Selectors)
+	assert.Equal(
+		t,
+		endpoint.Orders{endpoint.NewOrder("name", endpoint.DirectionDesc)},
+		listQuery.Orders,
+	)
+	assert.Equal(t, &endpoint.Page{Limit: 100}, listQuery.Page)
+}
@@ -0,0 +1,55 @@
+package test
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/pakkasys/fluidapi/endpoint"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestNewListResponse tests that the page metadata and links are built from
+// the page and the request URL.
+func TestNewListResponse(t *testing.T) {
+	requestURL, err := url.Parse("/users?filter[age][gt]=30&offset=10&limit=10")
+	assert.NoError(t, err)
+
+	response := endpoint.NewListResponse(
+		[]int{1, 2}, 25, &endpoint.Page{Offset: 10, Limit: 10}, requestURL,
+	)
+
+	assert.Equal(t, []int{1, 2}, response.Items)
+	assert.Equal(t, 25, response.Total)
+	assert.Equal(t, 10, response.Offset)
+	assert.Equal(t, 10, response.Limit)
+	assert.Equal(
+		t,
+		"/users?filter%5Bage%5D%5Bgt%5D=30&limit=10&offset=10",
+		response.Links.Self,
+	)
+	assert.Equal(
+		t,
+		"/users?filter%5Bage%5D%5Bgt%5D=30&limit=10&offset=20",
+		*response.Links.Next,
+	)
+	assert.Equal(
+		t,
+		"/users?filter%5Bage%5D%5Bgt%5D=30&limit=10&offset=0",
+		*response.Links.Prev,
+	)
+}
+
+// TestNewListResponse_LastPage tests that the last page has no next link and
+// the first page has no previous link.
+func TestNewListResponse_LastPage(t *testing.T) {
+	requestURL, err := url.Parse("/users")
+	assert.NoError(t, err)
+
+	response := endpoint.NewListResponse[int](
+		nil, 5, &endpoint.Page{Offset: 0, Limit: 10}, requestURL,
+	)
+
+	assert.Equal(t, []int{}, response.Items)
+	assert.Nil(t, response.Links.Next)
+	assert.Nil(t, response.Links.Prev)
+}
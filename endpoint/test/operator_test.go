@@ -0,0 +1,173 @@
+package test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/pakkasys/fluidapi/core"
+	"github.com/pakkasys/fluidapi/database"
+	"github.com/pakkasys/fluidapi/endpoint"
+	"github.com/stretchr/testify/assert"
+)
+
+// orderFieldMap is the field map used by the order tests.
+var orderFieldMap = map[string]endpoint.DBField{
+	"id":         {Table: "user", Column: "id"},
+	"last_name":  {Table: "user", Column: "last_name"},
+	"first_name": {Table: "user", Column: "first_name"},
+}
+
+// TestTranslateToDBOrders_PreservesOrder tests that orders are translated in
+// the order they are given, with duplicates and tiebreakers applied.
+func TestTranslateToDBOrders_PreservesOrder(t *testing.T) {
+	orders := endpoint.Orders{
+		endpoint.NewOrder("last_name", endpoint.DirectionDesc).
+			WithNulls(endpoint.NullsFirst),
+		endpoint.NewOrder("first_name", ""),
+		endpoint.NewOrder("last_name", endpoint.DirectionAsc),
+	}.WithDefaults(nil, endpoint.NewOrder("id", endpoint.DirectionAsc))
+
+	dbOrders, err := orders.TranslateToDBOrders(orderFieldMap)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []database.Order{
+		{
+			Table:     "user",
+			Field:     "last_name",
+			Direction: database.OrderDesc,
+			Nulls:     database.NullsFirst,
+		},
+		{Table: "user", Field: "first_name", Direction: database.OrderAsc},
+		{Table: "user", Field: "id", Direction: database.OrderAsc},
+	}, dbOrders)
+}
+
+// TestWithDefaults_UsesDefaults tests that default orders are used when no
+// orders are given.
+func TestWithDefaults_UsesDefaults(t *testing.T) {
+	defaults := endpoint.Orders{endpoint.NewOrder("last_name", "asc")}
+	tiebreaker := endpoint.NewOrder("id", "asc")
+
+	orders := endpoint.Orders{}.WithDefaults(defaults, tiebreaker)
+
+	assert.Equal(t, endpoint.Orders{defaults[0], tiebreaker}, orders)
+}
+
+// TestTranslateToDBOrders_InvalidDirection tests that unknown directions are
+// rejected.
+func TestTranslateToDBOrders_InvalidDirection(t *testing.T) {
+	orders := endpoint.Orders{endpoint.NewOrder("last_name", "sideways")}
+
+	_, err := orders.TranslateToDBOrders(orderFieldMap)
+
+	var apiError *core.APIError
+	assert.True(t, errors.As(err, &apiError))
+	assert.Equal(t, endpoint.InvalidOrderDirectionError.ID, apiError.ID)
+}
+
+// selectorFieldMap is the field map used by the selector tests.
+var selectorFieldMap = map[string]endpoint.DBField{
+	"age": {
+		Table:      "user",
+		Column:     "age",
+		Predicates: endpoint.OnlyGreaterPredicates,
+		Type:       endpoint.FieldTypeInt,
+	},
+	"status": {
+		Table:       "user",
+		Column:      "status",
+		Predicates:  endpoint.OnlyInAndNotInPredicates,
+		MaxInValues: 2,
+	},
+	"nickname": {Table: "user", Column: "nickname"},
+}
+
+// TestToDBSelectors_CoercesValues tests that selector values are coerced into
+// the declared field types.
+func TestToDBSelectors_CoercesValues(t *testing.T) {
+	selectors := endpoint.Selectors{}.
+		AddSelector("age", endpoint.Gt, "30").
+		AddSelector("status", endpoint.In, []string{"a", "b"})
+
+	dbSelectors, err := selectors.ToDBSelectors(selectorFieldMap)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []database.Selector{
+		{
+			Table:     "user",
+			Column:    "age",
+			Predicate: database.Greater,
+			Value:     int64(30),
+		},
+		{
+			Table:     "user",
+			Column:    "status",
+			Predicate: database.In,
+			Value:     []any{"a", "b"},
+		},
+	}, dbSelectors)
+}
+
+// TestToDBSelectors_Policy tests that the field policies are enforced.
+func TestToDBSelectors_Policy(t *testing.T) {
+	tests := []struct {
+		name       string
+		selectors  endpoint.Selectors
+		expectedID string
+	}{
+		{
+			name:       "Unknown field",
+			selectors:  endpoint.Selectors{}.AddSelector("name", "eq", "a"),
+			expectedID: endpoint.InvalidDatabaseSelectorTranslationError.ID,
+		},
+		{
+			name:       "Predicate not allowed",
+			selectors:  endpoint.Selectors{}.AddSelector("age", "eq", "30"),
+			expectedID: endpoint.PredicateNotAllowedError.ID,
+		},
+		{
+			name:       "Invalid value",
+			selectors:  endpoint.Selectors{}.AddSelector("age", "gt", "old"),
+			expectedID: endpoint.InvalidSelectorValueError.ID,
+		},
+		{
+			name: "Object value",
+			selectors: endpoint.Selectors{}.
+				AddSelector("age", "gt", map[string]any{"$gt": 1}),
+			expectedID: endpoint.InvalidSelectorValueError.ID,
+		},
+		{
+			name: "List value for non-list predicate",
+			selectors: endpoint.Selectors{}.
+				AddSelector("age", "gt", []any{float64(1), float64(2)}),
+			expectedID: endpoint.InvalidSelectorValueError.ID,
+		},
+		{
+			name:       "Fractional int value",
+			selectors:  endpoint.Selectors{}.AddSelector("age", "gt", 1.5),
+			expectedID: endpoint.InvalidSelectorValueError.ID,
+		},
+		{
+			name: "List value for untyped field",
+			selectors: endpoint.Selectors{}.
+				AddSelector("nickname", "eq", []any{"a"}),
+			expectedID: endpoint.InvalidSelectorValueError.ID,
+		},
+		{
+			name: "Too many IN values",
+			selectors: endpoint.Selectors{}.
+				AddSelector("status", "in", []string{"a", "b", "c"}),
+			expectedID: endpoint.MaxInValuesExceededError.ID,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.selectors.ToDBSelectors(selectorFieldMap)
+
+			var apiError *core.APIError
+			assert.True(t, errors.As(err, &apiError))
+			assert.Equal(t, tt.expectedID, apiError.ID)
+		})
+	}
+}
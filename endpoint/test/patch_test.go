@@ -0,0 +1,245 @@
+package test
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pakkasys/fluidapi/core"
+	"github.com/pakkasys/fluidapi/database"
+	"github.com/pakkasys/fluidapi/endpoint"
+	"github.com/stretchr/testify/assert"
+)
+
+// patchFieldMap is the field map used by the patch tests.
+var patchFieldMap = map[string]endpoint.DBField{
+	"name":       {Column: "name", NotNull: true},
+	"nickname":   {Column: "nickname"},
+	"id":         {Column: "id", Write: endpoint.WritePolicyReadOnly},
+	"email":      {Column: "email", Write: endpoint.WritePolicyCreateOnly},
+	"is_blocked": {Column: "is_blocked", Write: endpoint.WritePolicyAdminOnly},
+}
+
+// TestParsePatchRequest_MergePatch tests that a merge patch is parsed into
+// updates, keeping explicit nulls.
+func TestParsePatchRequest_MergePatch(t *testing.T) {
+	r := httptest.NewRequest(
+		"PATCH", "/", strings.NewReader(`{"name":"a","nickname":null}`),
+	)
+	r.Header.Set("Content-Type", endpoint.MergePatchContentType)
+
+	updates, err := endpoint.ParsePatchRequest(r)
+
+	assert.NoError(t, err)
+	assert.Equal(t, endpoint.Updates{"name": "a", "nickname": nil}, updates)
+}
+
+// TestParsePatchRequest_JSONPatch tests that JSON Patch operations are
+// translated into updates and unsupported operations are rejected.
+func TestParsePatchRequest_JSONPatch(t *testing.T) {
+	r := httptest.NewRequest("PATCH", "/", strings.NewReader(`[
+		{"op":"replace","path":"/name","value":"a"},
+		{"op":"remove","path":"/nickname"}
+	]`))
+	r.Header.Set("Content-Type", endpoint.JSONPatchContentType)
+
+	updates, err := endpoint.ParsePatchRequest(r)
+	assert.NoError(t, err)
+	assert.Equal(t, endpoint.Updates{"name": "a", "nickname": nil}, updates)
+
+	_, err = endpoint.PatchOperations{
+		{Op: "move", Path: "/name"},
+	}.ToUpdates()
+	var apiErr *core.APIError
+	assert.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, endpoint.InvalidPatchError.ID, apiErr.ID)
+
+	_, err = endpoint.PatchOperations{
+		{Op: "replace", Path: "/address/city", Value: "x"},
+	}.ToUpdates()
+	assert.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, endpoint.InvalidPatchError.ID, apiErr.ID)
+}
+
+// TestUpdates_ToDBUpdatesContext_Rejected tests that all fields that cannot be
+// written are listed in the error.
+func TestUpdates_ToDBUpdatesContext_Rejected(t *testing.T) {
+	updates := endpoint.Updates{
+		"name":       nil,
+		"id":         1,
+		"email":      "a@example.com",
+		"is_blocked": true,
+		"unknown":    1,
+		"nickname":   "a",
+	}
+
+	_, err := updates.ToDBUpdatesContext(
+		context.Background(), patchFieldMap,
+	)
+
+	var apiErr *core.APIError
+	assert.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, endpoint.FieldsNotWritableError.ID, apiErr.ID)
+	assert.Equal(t, endpoint.FieldsNotWritableErrorData{
+		Fields: []endpoint.RejectedField{
+			{Field: "email", Reason: endpoint.RejectCreateOnly},
+			{Field: "id", Reason: endpoint.RejectReadOnly},
+			{Field: "is_blocked", Reason: endpoint.RejectAdminOnly},
+			{Field: "name", Reason: endpoint.RejectNotNullable},
+			{Field: "unknown", Reason: endpoint.RejectUnknown},
+		},
+	}, apiErr.Data)
+}
+
+// TestUpdates_ToDBUpdatesContext_Admin tests that admins can write admin-only
+// fields.
+func TestUpdates_ToDBUpdatesContext_Admin(t *testing.T) {
+	ctx := endpoint.WithRole(context.Background(), endpoint.RoleAdmin)
+	updates := endpoint.Updates{"is_blocked": true, "nickname": nil}
+
+	dbUpdates, err := updates.ToDBUpdatesContext(ctx, patchFieldMap)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []database.Update{
+		{Field: "is_blocked", Value: true},
+		{Field: "nickname", Value: nil},
+	}, dbUpdates)
+}
+
+// TestUpdates_ToDBUpdates_Policies tests that read-only and create-only
+// fields are rejected without a role check.
+func TestUpdates_ToDBUpdates_Policies(t *testing.T) {
+	updates := endpoint.Updates{
+		"id":         1,
+		"email":      "a@example.com",
+		"is_blocked": true,
+		"nickname":   "a",
+	}
+
+	_, err := updates.ToDBUpdates(patchFieldMap)
+
+	var apiErr *core.APIError
+	assert.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, endpoint.FieldsNotWritableError.ID, apiErr.ID)
+	assert.Equal(t, endpoint.FieldsNotWritableErrorData{
+		Fields: []endpoint.RejectedField{
+			{Field: "email", Reason: endpoint.RejectCreateOnly},
+			{Field: "id", Reason: endpoint.RejectReadOnly},
+		},
+	}, apiErr.Data)
+}
+
+// TestUpdates_ToDBUpdates_Unknown tests that unknown fields cannot be
+// translated.
+func TestUpdates_ToDBUpdates_Unknown(t *testing.T) {
+	updates := endpoint.Updates{"nickname": "a", "unknown": 1}
+
+	_, err := updates.ToDBUpdates(patchFieldMap)
+
+	var apiErr *core.APIError
+	assert.ErrorAs(t, err, &apiErr)
+	assert.Equal(
+		t, endpoint.InvalidDatabaseUpdateTranslationError.ID, apiErr.ID,
+	)
+	assert.Equal(t, endpoint.InvalidDatabaseUpdateTranslationErrorData{
+		Field: "unknown",
+	}, apiErr.Data)
+}
+
+// TestMergePatch tests that merge patches are applied recursively as in the
+// examples of RFC 7396.
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		target   any
+		patch    any
+		expected any
+	}{
+		{
+			name:     "Replace member",
+			target:   map[string]any{"a": "b"},
+			patch:    map[string]any{"a": "c"},
+			expected: map[string]any{"a": "c"},
+		},
+		{
+			name:     "Remove member",
+			target:   map[string]any{"a": "b", "b": "c"},
+			patch:    map[string]any{"a": nil},
+			expected: map[string]any{"b": "c"},
+		},
+		{
+			name:     "Replace array",
+			target:   map[string]any{"a": []any{"b"}},
+			patch:    map[string]any{"a": "c"},
+			expected: map[string]any{"a": "c"},
+		},
+		{
+			name:   "Merge nested object",
+			target: map[string]any{"a": map[string]any{"b": "c", "d": "e"}},
+			patch: map[string]any{
+				"a": map[string]any{"b": "x", "d": nil, "f": "g"},
+			},
+			expected: map[string]any{"a": map[string]any{"b": "x", "f": "g"}},
+		},
+		{
+			name:   "Create nested object",
+			target: map[string]any{"e": nil},
+			patch: map[string]any{
+				"a": map[string]any{"bb": map[string]any{"ccc": nil}},
+			},
+			expected: map[string]any{
+				"e": nil,
+				"a": map[string]any{"bb": map[string]any{}},
+			},
+		},
+		{
+			name:     "Replace object with scalar",
+			target:   map[string]any{"a": "foo"},
+			patch:    "bar",
+			expected: "bar",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patched := endpoint.MergePatch(tt.target, tt.patch)
+
+			assert.Equal(t, tt.expected, patched)
+		})
+	}
+}
+
+// TestUpdates_MergeWith tests that object values are merged into the current
+// values of the fields.
+func TestUpdates_MergeWith(t *testing.T) {
+	updates := endpoint.Updates{
+		"name":     "a",
+		"settings": map[string]any{"theme": "dark", "lang": nil},
+	}
+	current := map[string]any{
+		"name":     "b",
+		"settings": map[string]any{"lang": "fi", "size": float64(2)},
+	}
+
+	merged := updates.MergeWith(current)
+
+	assert.Equal(t, endpoint.Updates{
+		"name": "a",
+		"settings": map[string]any{
+			"theme": "dark", "size": float64(2),
+		},
+	}, merged)
+}
+
+// TestUpdates_CheckWritable_Create tests that create-only fields can be
+// written on create.
+func TestUpdates_CheckWritable_Create(t *testing.T) {
+	updates := endpoint.Updates{"email": "a@example.com"}
+
+	err := updates.CheckWritable(
+		context.Background(), patchFieldMap, endpoint.WriteCreate,
+	)
+
+	assert.NoError(t, err)
+}
@@ -0,0 +1,127 @@
+package test
+
+import (
+	"errors"
+	"iter"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/pakkasys/fluidapi/endpoint"
+	"github.com/stretchr/testify/assert"
+)
+
+// streamItem is an item of the stream tests.
+type streamItem struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// streamItems returns an iterator of the items followed by the error, if any.
+func streamItems(
+	items []streamItem, err error,
+) iter.Seq2[streamItem, error] {
+	return func(yield func(streamItem, error) bool) {
+		for _, item := range items {
+			if !yield(item, nil) {
+				return
+			}
+		}
+		if err != nil {
+			yield(streamItem{}, err)
+		}
+	}
+}
+
+// streamRecord converts a stream item into a CSV record.
+func streamRecord(item streamItem) ([]string, error) {
+	return []string{strconv.Itoa(item.ID), item.Name}, nil
+}
+
+var testStreamItems = []streamItem{{1, "Ada"}, {2, "Grace, Hopper"}}
+
+// TestStreamWriter_Formats tests the output of the stream formats.
+func TestStreamWriter_Formats(t *testing.T) {
+	tests := []struct {
+		format   endpoint.StreamFormat
+		items    []streamItem
+		expected string
+	}{
+		{
+			endpoint.StreamJSON,
+			testStreamItems,
+			`[{"id":1,"name":"Ada"},{"id":2,"name":"Grace, Hopper"}]`,
+		},
+		{endpoint.StreamJSON, nil, `[]`},
+		{
+			endpoint.StreamNDJSON,
+			testStreamItems,
+			"{\"id\":1,\"name\":\"Ada\"}\n{\"id\":2,\"name\":\"Grace, Hopper\"}\n",
+		},
+		{
+			endpoint.StreamCSV,
+			testStreamItems,
+			"id,name\n1,Ada\n2,\"Grace, Hopper\"\n",
+		},
+		{endpoint.StreamCSV, nil, "id,name\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.format), func(t *testing.T) {
+			var output strings.Builder
+			writer := endpoint.NewStreamWriter[streamItem](
+				&output, test.format,
+			).WithCSV([]string{"id", "name"}, streamRecord)
+
+			err := writer.WriteAll(streamItems(test.items, nil))
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, output.String())
+			assert.Equal(t, len(test.items), writer.Count())
+		})
+	}
+}
+
+// TestStreamWriter_IteratorError tests that the output is left incomplete
+// when the iterator fails.
+func TestStreamWriter_IteratorError(t *testing.T) {
+	var output strings.Builder
+	writer := endpoint.NewStreamWriter[streamItem](
+		&output, endpoint.StreamJSON,
+	)
+
+	err := writer.WriteAll(
+		streamItems(testStreamItems[:1], errors.New("query failed")),
+	)
+
+	assert.EqualError(t, err, "query failed")
+	assert.Equal(t, `[{"id":1,"name":"Ada"}`, output.String())
+}
+
+// TestStreamWriter_CSVWithoutRecordFn tests that CSV streams require a
+// record function.
+func TestStreamWriter_CSVWithoutRecordFn(t *testing.T) {
+	var output strings.Builder
+	writer := endpoint.NewStreamWriter[streamItem](&output, endpoint.StreamCSV)
+
+	err := writer.Write(streamItem{})
+
+	assert.Error(t, err)
+	assert.Error(t, writer.Close())
+}
+
+// TestMapItems tests that items are converted and errors passed through.
+func TestMapItems(t *testing.T) {
+	var names []string
+	var errs []error
+	for name, err := range endpoint.MapItems(
+		streamItems(testStreamItems, errors.New("done")),
+		func(item streamItem) string { return item.Name },
+	) {
+		names = append(names, name)
+		errs = append(errs, err)
+	}
+
+	assert.Equal(t, []string{"Ada", "Grace, Hopper", ""}, names)
+	assert.EqualError(t, errs[2], "done")
+}
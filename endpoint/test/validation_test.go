@@ -0,0 +1,194 @@
+package test
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pakkasys/fluidapi/core"
+	"github.com/pakkasys/fluidapi/endpoint"
+	"github.com/stretchr/testify/assert"
+)
+
+type validationAddress struct {
+	City string `json:"city" validate:"required"`
+}
+
+type validationInput struct {
+	Name      string              `json:"name" validate:"required,min=2,max=5"`
+	Age       int                 `json:"age" validate:"min=18"`
+	Kind      string              `json:"kind" validate:"enum=a|b"`
+	Email     string              `json:"email" validate:"email"`
+	ID        string              `json:"id" validate:"uuid"`
+	Code      string              `json:"code" validate:"regex=^[A-Z]{2,3}$"`
+	Nickname  *string             `json:"nickname" validate:"min=3"`
+	Address   validationAddress   `json:"address"`
+	Addresses []validationAddress `json:"addresses" validate:"max=2"`
+}
+
+// validInput returns an input that passes validation.
+func validInput() validationInput {
+	return validationInput{
+		Name:      "Alice",
+		Age:       30,
+		Kind:      "a",
+		Email:     "alice@example.com",
+		ID:        "123e4567-e89b-12d3-a456-426614174000",
+		Code:      "ABC",
+		Address:   validationAddress{City: "Helsinki"},
+		Addresses: []validationAddress{{City: "Espoo"}},
+	}
+}
+
+// TestValidate_Valid tests that a valid input passes validation.
+func TestValidate_Valid(t *testing.T) {
+	input := validInput()
+	assert.NoError(t, endpoint.Validate(&input))
+}
+
+// TestValidate_CollectsAllFields tests that all failing fields are reported in
+// a single ValidationError.
+func TestValidate_CollectsAllFields(t *testing.T) {
+	nickname := "x"
+	input := validationInput{
+		Name:      "A",
+		Age:       10,
+		Kind:      "c",
+		Email:     "not an email",
+		ID:        "123",
+		Code:      "abc",
+		Nickname:  &nickname,
+		Addresses: []validationAddress{{}, {}, {City: "Espoo"}},
+	}
+
+	err := endpoint.Validate(&input)
+
+	apiError, ok := err.(*core.APIError)
+	assert.True(t, ok)
+	assert.Equal(t, endpoint.ValidationError.ID, apiError.ID)
+	assert.Equal(t, []endpoint.FieldError{
+		{Field: "name", Rule: "min", Param: "2"},
+		{Field: "age", Rule: "min", Param: "18"},
+		{Field: "kind", Rule: "enum", Param: "a|b"},
+		{Field: "email", Rule: "email"},
+		{Field: "id", Rule: "uuid"},
+		{Field: "code", Rule: "regex", Param: "^[A-Z]{2,3}$"},
+		{Field: "nickname", Rule: "min", Param: "3"},
+		{Field: "address.city", Rule: "required"},
+		{Field: "addresses", Rule: "max", Param: "2"},
+		{Field: "addresses[0].city", Rule: "required"},
+		{Field: "addresses[1].city", Rule: "required"},
+	}, apiError.Data.(endpoint.ValidationErrorData).Fields)
+}
+
+// TestValidate_CustomRule tests that custom rules can be registered.
+func TestValidate_CustomRule(t *testing.T) {
+	endpoint.RegisterValidationRule(
+		"prefix",
+		func(value reflect.Value, param string) bool {
+			return strings.HasPrefix(value.String(), param)
+		},
+	)
+	input := struct {
+		SKU string `json:"sku" validate:"prefix=SKU-"`
+	}{SKU: "ABC-1"}
+
+	err := endpoint.Validate(&input)
+
+	apiError, ok := err.(*core.APIError)
+	assert.True(t, ok)
+	assert.Equal(
+		t,
+		[]endpoint.FieldError{{Field: "sku", Rule: "prefix", Param: "SKU-"}},
+		apiError.Data.(endpoint.ValidationErrorData).Fields,
+	)
+}
+
+// TestCheckValidationTags tests that invalid tags of nested types are
+// reported before any input is validated.
+func TestCheckValidationTags(t *testing.T) {
+	type item struct {
+		Code string `json:"code" validate:"regex=[a-"`
+	}
+	type input struct {
+		Name  string  `json:"name" validate:"required"`
+		Items []*item `json:"items"`
+	}
+
+	assert.NoError(t, endpoint.CheckValidationTags[validationInput]())
+	err := endpoint.CheckValidationTags[input]()
+	assert.ErrorContains(t, err, `invalid regex "[a-"`)
+}
+
+// TestCheckValidationTags_InvalidNumber tests that non-numeric parameters of
+// the numeric rules are reported before any input is validated.
+func TestCheckValidationTags_InvalidNumber(t *testing.T) {
+	type input struct {
+		Name string `json:"name" validate:"min=abc"`
+	}
+
+	err := endpoint.CheckValidationTags[input]()
+
+	assert.ErrorContains(t, err, `invalid parameter "abc" for rule "min"`)
+	assert.Panics(t, func() {
+		endpoint.Handle(
+			func(ctx context.Context, in *input) (*struct{}, error) {
+				return nil, nil
+			},
+		)
+	})
+}
+
+// TestValidate_RequiredNumber tests that required rejects zero numbers and
+// accepts a zero behind a pointer.
+func TestValidate_RequiredNumber(t *testing.T) {
+	type input struct {
+		Count int      `json:"count" validate:"required"`
+		Price float64  `json:"price" validate:"required"`
+		Limit *int     `json:"limit" validate:"required"`
+		Ratio *float64 `json:"ratio" validate:"required"`
+	}
+	zero := 0
+
+	err := endpoint.Validate(&input{Limit: &zero})
+
+	apiError, ok := err.(*core.APIError)
+	assert.True(t, ok)
+	assert.Equal(t, []endpoint.FieldError{
+		{Field: "count", Rule: "required"},
+		{Field: "price", Rule: "required"},
+		{Field: "ratio", Rule: "required"},
+	}, apiError.Data.(endpoint.ValidationErrorData).Fields)
+	assert.NoError(t, endpoint.Validate(&input{
+		Count: 1, Price: 0.5, Limit: &zero, Ratio: new(float64),
+	}))
+}
+
+// TestValidate_UnknownRule tests that unknown rules are returned as errors
+// instead of panicking.
+func TestValidate_UnknownRule(t *testing.T) {
+	input := struct {
+		Name string `json:"name" validate:"unknown"`
+	}{Name: "a"}
+
+	err := endpoint.Validate(&input)
+
+	assert.ErrorContains(t, err, `unknown validation rule "unknown"`)
+}
+
+// TestHandle_InvalidValidationTags tests that handlers with invalid
+// validation tags fail when they are built.
+func TestHandle_InvalidValidationTags(t *testing.T) {
+	type input struct {
+		Name string `json:"name" validate:"unknown"`
+	}
+
+	assert.Panics(t, func() {
+		endpoint.Handle(
+			func(ctx context.Context, in *input) (*struct{}, error) {
+				return nil, nil
+			},
+		)
+	})
+}
@@ -0,0 +1,481 @@
+package endpoint
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/pakkasys/fluidapi/core"
+)
+
+// FieldError describes a single failed validation rule.
+type FieldError struct {
+	Field string `json:"field"`
+	Rule  string `json:"rule"`
+	Param string `json:"param,omitempty"`
+}
+
+// ValidationErrorData is the data for the ValidationError error.
+type ValidationErrorData struct {
+	Fields []FieldError `json:"fields"`
+}
+
+// ValidationError is returned when an input fails validation. Its data lists
+// every failed field.
+var ValidationError = core.NewAPIError("VALIDATION_ERROR")
+
+// ValidationRule reports whether the value passes a rule with the given
+// parameter. Pointers are dereferenced before the rule is called.
+type ValidationRule func(value reflect.Value, param string) bool
+
+// Built-in validation rules.
+const (
+	RuleRequired = "required"
+	RuleMin      = "min"
+	RuleMax      = "max"
+	RuleLen      = "len"
+	RuleRegex    = "regex"
+	RuleEnum     = "enum"
+	RuleEmail    = "email"
+	RuleUUID     = "uuid"
+)
+
+// validationRules holds the registered validation rules.
+var validationRules = map[string]ValidationRule{
+	RuleMin:   compareRule(func(v, limit float64) bool { return v >= limit }),
+	RuleMax:   compareRule(func(v, limit float64) bool { return v <= limit }),
+	RuleLen:   compareRule(func(v, limit float64) bool { return v == limit }),
+	RuleEnum:  enumRule,
+	RuleEmail: emailRule,
+	RuleUUID:  uuidRule,
+}
+
+// numericParamRules are the rules whose parameter must be a number.
+var numericParamRules = map[string]bool{
+	RuleMin: true,
+	RuleMax: true,
+	RuleLen: true,
+}
+
+// validationRulesMu guards validationRules.
+var validationRulesMu sync.RWMutex
+
+// RegisterValidationRule registers a custom validation rule that can be used
+// in "validate" struct tags. Registering an existing name replaces the rule,
+// except for the required and regex rules. Rules are resolved when the tags of
+// a type are first compiled, so they must be registered before the type is
+// validated or a handler using it is built.
+//
+// Parameters:
+//   - name: The name of the rule.
+//   - rule: The rule function.
+func RegisterValidationRule(name string, rule ValidationRule) {
+	validationRulesMu.Lock()
+	defer validationRulesMu.Unlock()
+	validationRules[name] = rule
+}
+
+// Validate validates the input using its "validate" struct tags. Nested
+// structs, pointers and slices are validated recursively. All failing fields
+// are collected into a single ValidationError.
+//
+// Rules are separated by commas and parameters are given after "=":
+//
+//	type Input struct {
+//	    Name  string   `json:"name" validate:"required,min=2,max=50"`
+//	    Kind  string   `json:"kind" validate:"enum=a|b|c"`
+//	    Email string   `json:"email" validate:"email"`
+//	    Tags  []string `json:"tags" validate:"max=10"`
+//	    Code  string   `json:"code" validate:"regex=^[A-Z]{3}$"`
+//	}
+//
+// The required rule fails for nil pointers, empty strings, slices and maps
+// and zero numbers, so use a pointer for a required number that can be zero.
+// The min, max and len rules compare numbers by value and strings, slices and
+// maps by length, and their parameters must be numbers. The regex rule
+// consumes the rest of the tag, so it must be the last rule. Apart from
+// required, rules are skipped for nil pointers and empty strings, slices and
+// maps.
+//
+// The tags of each type are parsed and compiled once. Use CheckValidationTags
+// to report unknown rules, invalid parameters and invalid regular expressions
+// up front.
+//
+// Parameters:
+//   - input: The input to validate.
+//
+// Returns:
+//   - error: A ValidationError if any field fails validation, or an error if
+//     the tags are invalid.
+func Validate(input any) error {
+	var fieldErrors []FieldError
+	if err := validateValue(
+		reflect.ValueOf(input), "", &fieldErrors,
+	); err != nil {
+		return fmt.Errorf("Validate: %w", err)
+	}
+	if len(fieldErrors) == 0 {
+		return nil
+	}
+	return ValidationError.
+		WithData(ValidationErrorData{Fields: fieldErrors}).
+		WithMessage(fmt.Sprintf(
+			"validation failed for %d field(s)", len(fieldErrors),
+		))
+}
+
+// CheckValidationTags parses the "validate" struct tags of T and of the
+// struct types nested in it and compiles their rules, so that unknown rules,
+// invalid numeric parameters and invalid regular expressions are reported
+// before any input is validated. Handle calls it when the handler is built.
+//
+// Returns:
+//   - error: An error if a tag is invalid.
+func CheckValidationTags[T any]() error {
+	err := checkValidationType(reflect.TypeFor[T](), map[reflect.Type]bool{})
+	if err != nil {
+		return fmt.Errorf("CheckValidationTags: %w", err)
+	}
+	return nil
+}
+
+// tagRule is a parsed rule from a "validate" struct tag.
+type tagRule struct {
+	name  string
+	param string
+}
+
+// compiledRule is a rule of a "validate" struct tag resolved to its check.
+// Required rules have no check.
+type compiledRule struct {
+	name  string
+	param string
+	check func(value reflect.Value) bool
+}
+
+// fieldRules holds the compiled rules of an exported struct field.
+type fieldRules struct {
+	index []int
+	name  string
+	rules []compiledRule
+}
+
+// structRulesCache caches the compiled field rules of struct types.
+var structRulesCache sync.Map
+
+// structRulesOf returns the compiled field rules of the struct type.
+func structRulesOf(t reflect.Type) ([]fieldRules, error) {
+	if cached, ok := structRulesCache.Load(t); ok {
+		return cached.([]fieldRules), nil
+	}
+	fields, err := collectFieldRules(t, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", t, err)
+	}
+	cached, _ := structRulesCache.LoadOrStore(t, fields)
+	return cached.([]fieldRules), nil
+}
+
+// collectFieldRules compiles the rules of the exported fields, including the
+// fields of embedded structs.
+func collectFieldRules(
+	t reflect.Type, parentIndex []int,
+) ([]fieldRules, error) {
+	var fields []fieldRules
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		index := append(append([]int{}, parentIndex...), i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			embedded, err := collectFieldRules(field.Type, index)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, embedded...)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		var rules []compiledRule
+		if tag, ok := field.Tag.Lookup("validate"); ok && tag != "" {
+			var err error
+			rules, err = compileRules(parseValidationTag(tag))
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", field.Name, err)
+			}
+		}
+		fields = append(fields, fieldRules{
+			index: index,
+			name:  fieldName(field),
+			rules: rules,
+		})
+	}
+	return fields, nil
+}
+
+// compileRules resolves the rules to their checks.
+func compileRules(rules []tagRule) ([]compiledRule, error) {
+	validationRulesMu.RLock()
+	defer validationRulesMu.RUnlock()
+	compiled := make([]compiledRule, len(rules))
+	for i, rule := range rules {
+		compiled[i] = compiledRule{name: rule.name, param: rule.param}
+		switch rule.name {
+		case RuleRequired:
+		case RuleRegex:
+			regex, err := regexp.Compile(rule.param)
+			if err != nil {
+				return nil, fmt.Errorf("invalid regex %q: %w", rule.param, err)
+			}
+			compiled[i].check = func(value reflect.Value) bool {
+				return value.Kind() == reflect.String &&
+					regex.MatchString(value.String())
+			}
+		default:
+			ruleFn, ok := validationRules[rule.name]
+			if !ok {
+				return nil, fmt.Errorf("unknown validation rule %q", rule.name)
+			}
+			if numericParamRules[rule.name] {
+				_, err := strconv.ParseFloat(rule.param, 64)
+				if err != nil {
+					return nil, fmt.Errorf(
+						"invalid parameter %q for rule %q",
+						rule.param, rule.name,
+					)
+				}
+			}
+			param := rule.param
+			compiled[i].check = func(value reflect.Value) bool {
+				return ruleFn(value, param)
+			}
+		}
+	}
+	return compiled, nil
+}
+
+// checkValidationType compiles the rules of the struct types reachable from
+// the type through pointers, slices, arrays and fields.
+func checkValidationType(t reflect.Type, seen map[reflect.Type]bool) error {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice ||
+		t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || seen[t] {
+		return nil
+	}
+	seen[t] = true
+	fields, err := structRulesOf(t)
+	if err != nil {
+		return err
+	}
+	for _, field := range fields {
+		fieldType := t.FieldByIndex(field.index).Type
+		if err := checkValidationType(fieldType, seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateValue validates nested structs and slices recursively.
+func validateValue(
+	value reflect.Value, path string, errs *[]FieldError,
+) error {
+	for value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	switch value.Kind() {
+	case reflect.Struct:
+		return validateStruct(value, path, errs)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			elemPath := fmt.Sprintf("%s[%d]", path, i)
+			err := validateValue(value.Index(i), elemPath, errs)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateStruct validates the fields of a struct.
+func validateStruct(
+	value reflect.Value, path string, errs *[]FieldError,
+) error {
+	fields, err := structRulesOf(value.Type())
+	if err != nil {
+		return err
+	}
+	for _, field := range fields {
+		fieldPath := field.name
+		if path != "" {
+			fieldPath = path + "." + fieldPath
+		}
+		fieldValue := value.FieldByIndex(field.index)
+		applyRules(fieldValue, field.rules, fieldPath, errs)
+		if err := validateValue(fieldValue, fieldPath, errs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fieldName returns the name of the field as seen by the client.
+func fieldName(field reflect.StructField) string {
+	keys := []string{"json", SourcePath, SourceQuery, SourceHeader}
+	for _, key := range keys {
+		name := strings.Split(field.Tag.Get(key), ",")[0]
+		if name != "" && name != "-" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+// parseValidationTag parses the rules of a "validate" struct tag.
+func parseValidationTag(tag string) []tagRule {
+	var rules []tagRule
+	for tag != "" {
+		var part string
+		if strings.HasPrefix(tag, RuleRegex+"=") {
+			// The regex rule consumes the rest of the tag.
+			part, tag = tag, ""
+		} else {
+			part, tag, _ = strings.Cut(tag, ",")
+		}
+		name, param, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if name != "" {
+			rules = append(rules, tagRule{name: name, param: param})
+		}
+	}
+	return rules
+}
+
+// applyRules applies the compiled rules to a field value.
+func applyRules(
+	value reflect.Value, rules []compiledRule, path string, errs *[]FieldError,
+) {
+	for _, rule := range rules {
+		if rule.name == RuleRequired && isMissing(value) {
+			*errs = append(*errs, FieldError{Field: path, Rule: rule.name})
+			return
+		}
+	}
+
+	for value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return
+		}
+		value = value.Elem()
+	}
+	if isEmpty(value) {
+		return
+	}
+
+	for _, rule := range rules {
+		if rule.check == nil {
+			continue
+		}
+		if !rule.check(value) {
+			*errs = append(*errs, FieldError{
+				Field: path,
+				Rule:  rule.name,
+				Param: rule.param,
+			})
+		}
+	}
+}
+
+// isEmpty reports whether the value is a nil pointer or interface, or an empty
+// string, slice or map. Other zero values such as 0 are not considered empty.
+func isEmpty(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return value.IsNil()
+	case reflect.String, reflect.Slice, reflect.Map:
+		return value.Len() == 0
+	default:
+		return false
+	}
+}
+
+// isMissing reports whether the value fails the required rule, i.e. it is
+// empty or a zero number.
+func isMissing(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16,
+		reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return value.IsZero()
+	default:
+		return isEmpty(value)
+	}
+}
+
+// compareRule returns a rule comparing numbers by value and strings, slices,
+// arrays and maps by length.
+func compareRule(cmp func(v float64, limit float64) bool) ValidationRule {
+	return func(value reflect.Value, param string) bool {
+		limit, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return false
+		}
+		switch value.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+			reflect.Int64:
+			return cmp(float64(value.Int()), limit)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+			reflect.Uint64:
+			return cmp(float64(value.Uint()), limit)
+		case reflect.Float32, reflect.Float64:
+			return cmp(value.Float(), limit)
+		case reflect.String:
+			return cmp(float64(utf8.RuneCountInString(value.String())), limit)
+		case reflect.Slice, reflect.Array, reflect.Map:
+			return cmp(float64(value.Len()), limit)
+		default:
+			return false
+		}
+	}
+}
+
+// enumRule checks that the value is one of the "|" separated options.
+func enumRule(value reflect.Value, param string) bool {
+	str := fmt.Sprint(value.Interface())
+	for _, option := range strings.Split(param, "|") {
+		if str == option {
+			return true
+		}
+	}
+	return false
+}
+
+// emailRule checks that a string is a plain email address.
+func emailRule(value reflect.Value, _ string) bool {
+	if value.Kind() != reflect.String {
+		return false
+	}
+	address, err := mail.ParseAddress(value.String())
+	return err == nil && address.Address == value.String()
+}
+
+// uuidRegex matches UUIDs in the canonical textual form.
+var uuidRegex = regexp.MustCompile(
+	`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`,
+)
+
+// uuidRule checks that a string is a UUID.
+func uuidRule(value reflect.Value, _ string) bool {
+	return value.Kind() == reflect.String &&
+		uuidRegex.MatchString(value.String())
+}
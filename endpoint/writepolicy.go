@@ -0,0 +1,210 @@
+package endpoint
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/pakkasys/fluidapi/core"
+)
+
+// WritePolicy controls when a field can be written by clients.
+type WritePolicy string
+
+// Write policies. The zero value is treated as WritePolicyWritable.
+const (
+	WritePolicyWritable   WritePolicy = "writable"    // Create and update.
+	WritePolicyCreateOnly WritePolicy = "create_only" // Create only.
+	WritePolicyReadOnly   WritePolicy = "read_only"   // Never written.
+	WritePolicyAdminOnly  WritePolicy = "admin_only"  // Admin role only.
+)
+
+// WriteOperation is the kind of write a field is checked for.
+type WriteOperation string
+
+// Write operations.
+const (
+	WriteCreate WriteOperation = "create"
+	WriteUpdate WriteOperation = "update"
+)
+
+// Reasons for rejecting a field write.
+const (
+	RejectUnknown     = "unknown"
+	RejectReadOnly    = "read_only"
+	RejectCreateOnly  = "create_only"
+	RejectAdminOnly   = "admin_only"
+	RejectNotNullable = "not_nullable"
+)
+
+// RejectedField is a field whose write was rejected.
+type RejectedField struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+// FieldsNotWritableErrorData is the data for the FieldsNotWritableError error.
+type FieldsNotWritableErrorData struct {
+	Fields []RejectedField `json:"fields"`
+}
+
+// FieldsNotWritableError is returned when a write contains fields that the
+// client is not allowed to write.
+var FieldsNotWritableError = core.NewAPIError("FIELDS_NOT_WRITABLE")
+
+// Role is the role of the client making a request.
+type Role string
+
+// RoleAdmin is the role allowed to write admin-only fields.
+const RoleAdmin Role = "admin"
+
+// roleKey is the context key of the request role.
+type roleKey struct{}
+
+// WithRole returns a copy of the context carrying the role.
+//
+// Parameters:
+//   - ctx: The parent context.
+//   - role: The role of the client.
+//
+// Returns:
+//   - context.Context: The context with the role.
+func WithRole(ctx context.Context, role Role) context.Context {
+	return context.WithValue(ctx, roleKey{}, role)
+}
+
+// RoleFromContext returns the role carried by the context, or an empty role
+// if there is none.
+//
+// Parameters:
+//   - ctx: The context.
+//
+// Returns:
+//   - Role: The role of the client.
+func RoleFromContext(ctx context.Context) Role {
+	role, _ := ctx.Value(roleKey{}).(Role)
+	return role
+}
+
+// RoleMiddleware returns a middleware that derives the role of the client
+// from the request, e.g. from an authenticated session, and stores it in the
+// request context.
+//
+// Parameters:
+//   - roleFn: A function returning the role of the request.
+//
+// Returns:
+//   - core.Middleware: The role middleware.
+func RoleMiddleware(roleFn func(r *http.Request) Role) core.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := WithRole(r.Context(), roleFn(r))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// CheckWritable checks that the client may write every field of the updates
+// in the given operation. Fields must be in the field map, their write policy
+// must allow the operation and the role from the context, and null values are
+// only allowed for nullable fields. All rejected fields are reported at once.
+//
+// Parameters:
+//   - ctx: The request context carrying the role.
+//   - apiToDBFieldMap: The mapping of API field names to database fields.
+//   - operation: The write operation.
+//
+// Returns:
+//   - error: A FieldsNotWritableError listing the rejected fields.
+func (updates Updates) CheckWritable(
+	ctx context.Context,
+	apiToDBFieldMap map[string]DBField,
+	operation WriteOperation,
+) error {
+	role := RoleFromContext(ctx)
+	return updates.rejectFields(func(field string) string {
+		return writeRejection(
+			apiToDBFieldMap, field, updates[field], operation, role,
+		)
+	})
+}
+
+// checkUpdatePolicies checks that no field of the updates has a write policy
+// that forbids updates for every role, i.e. read-only and create-only fields.
+// Unknown fields are left to the translation.
+func (updates Updates) checkUpdatePolicies(
+	apiToDBFieldMap map[string]DBField,
+) error {
+	return updates.rejectFields(func(field string) string {
+		switch apiToDBFieldMap[field].Write {
+		case WritePolicyReadOnly:
+			return RejectReadOnly
+		case WritePolicyCreateOnly:
+			return RejectCreateOnly
+		default:
+			return ""
+		}
+	})
+}
+
+// rejectFields returns a FieldsNotWritableError listing the fields for which
+// reasonFn returns a reason, or nil if there are none.
+func (updates Updates) rejectFields(reasonFn func(field string) string) error {
+	var rejected []RejectedField
+	fields := mapKeys(updates)
+	sort.Strings(fields)
+	for _, field := range fields {
+		if reason := reasonFn(field); reason != "" {
+			rejected = append(rejected, RejectedField{
+				Field:  field,
+				Reason: reason,
+			})
+		}
+	}
+	if len(rejected) == 0 {
+		return nil
+	}
+
+	names := make([]string, len(rejected))
+	for i, field := range rejected {
+		names[i] = field.Field
+	}
+	return FieldsNotWritableError.
+		WithData(FieldsNotWritableErrorData{Fields: rejected}).
+		WithMessage(fmt.Sprintf(
+			"fields not writable: %s", strings.Join(names, ", "),
+		))
+}
+
+// writeRejection returns the reason the field cannot be written, or an empty
+// string if it can.
+func writeRejection(
+	apiToDBFieldMap map[string]DBField,
+	field string,
+	value any,
+	operation WriteOperation,
+	role Role,
+) string {
+	dbField, ok := apiToDBFieldMap[field]
+	if !ok {
+		return RejectUnknown
+	}
+	switch dbField.Write {
+	case WritePolicyReadOnly:
+		return RejectReadOnly
+	case WritePolicyCreateOnly:
+		if operation != WriteCreate {
+			return RejectCreateOnly
+		}
+	case WritePolicyAdminOnly:
+		if role != RoleAdmin {
+			return RejectAdminOnly
+		}
+	}
+	if value == nil && dbField.NotNull {
+		return RejectNotNullable
+	}
+	return ""
+}
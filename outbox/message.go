@@ -0,0 +1,104 @@
+package outbox
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/pakkasys/fluidapi/database"
+)
+
+// Message columns in scan order.
+const (
+	ColumnID          = "id"
+	ColumnKey         = "idempotency_key"
+	ColumnTopic       = "topic"
+	ColumnPayload     = "payload"
+	ColumnAttempts    = "attempts"
+	ColumnLastError   = "last_error"
+	ColumnAvailableAt = "available_at"
+	ColumnCreatedAt   = "created_at"
+	ColumnPublishedAt = "published_at"
+)
+
+// messageColumns are the columns of the outbox table in scan order.
+var messageColumns = []string{
+	ColumnID,
+	ColumnKey,
+	ColumnTopic,
+	ColumnPayload,
+	ColumnAttempts,
+	ColumnLastError,
+	ColumnAvailableAt,
+	ColumnCreatedAt,
+	ColumnPublishedAt,
+}
+
+// Message is a message stored in the outbox table.
+type Message struct {
+	ID int64
+	// Key identifies the message, so that consumers can ignore messages that
+	// are delivered more than once.
+	Key         string
+	Topic       string
+	Payload     []byte
+	Attempts    int
+	LastError   string
+	AvailableAt time.Time
+	CreatedAt   time.Time
+	PublishedAt *time.Time
+
+	table string
+}
+
+// TableName returns the name of the outbox table.
+func (m *Message) TableName() string {
+	return m.table
+}
+
+// InsertedValues returns the columns and values of the message for
+// insertion.
+func (m *Message) InsertedValues() ([]string, []any) {
+	return messageColumns[1:], []any{
+		m.Key,
+		m.Topic,
+		m.Payload,
+		m.Attempts,
+		m.LastError,
+		m.AvailableAt,
+		m.CreatedAt,
+		m.PublishedAt,
+	}
+}
+
+// ScanRow scans a row of the message columns into the message.
+func (m *Message) ScanRow(row database.Row) error {
+	var publishedAt sql.NullTime
+	if err := row.Scan(
+		&m.ID,
+		&m.Key,
+		&m.Topic,
+		&m.Payload,
+		&m.Attempts,
+		&m.LastError,
+		&m.AvailableAt,
+		&m.CreatedAt,
+		&publishedAt,
+	); err != nil {
+		return err
+	}
+	m.PublishedAt = nil
+	if publishedAt.Valid {
+		m.PublishedAt = &publishedAt.Time
+	}
+	return nil
+}
+
+// messageProjections returns the projections of the message columns of the
+// table.
+func messageProjections(table string) database.Projections {
+	projections := make(database.Projections, len(messageColumns))
+	for i, column := range messageColumns {
+		projections[i] = database.Projection{Table: table, Column: column}
+	}
+	return projections
+}
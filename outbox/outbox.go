@@ -0,0 +1,400 @@
+package outbox
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/pakkasys/fluidapi/core"
+	"github.com/pakkasys/fluidapi/database"
+	"github.com/pakkasys/fluidapi/queue"
+)
+
+// Default outbox settings.
+const (
+	DefaultMaxAttempts = 10
+)
+
+// Outbox stores messages in a database table within the transaction of the
+// changes they describe, so that a message is published if and only if the
+// transaction commits. The table must have the columns of Message:
+//
+//	id              auto-increment primary key
+//	idempotency_key string, unique
+//	topic           string
+//	payload         bytes
+//	attempts        int
+//	last_error      string
+//	available_at    timestamp
+//	created_at      timestamp
+//	published_at    nullable timestamp, indexed together with available_at
+//
+// A message whose publish fails is retried after the backoff delay. After
+// the max attempts it is dead-lettered: it stays unpublished and is no longer
+// relayed until it is requeued with Requeue.
+//
+// Messages are relayed with FOR UPDATE SKIP LOCKED, so the query builder must
+// implement database.LockDialect and database.ExprDialect.
+type Outbox struct {
+	table        string
+	queryBuilder database.QueryBuilder
+	errorChecker database.ErrorChecker
+	maxAttempts  int
+	backoff      func(attempt int) time.Duration
+	now          func() time.Time
+}
+
+// Option is a function that configures an outbox.
+type Option func(*Outbox)
+
+// WithErrorChecker sets the error checker for database errors.
+//
+// Parameters:
+//   - errorChecker: The error checker.
+//
+// Returns:
+//   - Option: The option.
+func WithErrorChecker(errorChecker database.ErrorChecker) Option {
+	return func(o *Outbox) {
+		o.errorChecker = errorChecker
+	}
+}
+
+// WithMaxAttempts sets the number of failed publish attempts after which a
+// message is dead-lettered. The default is DefaultMaxAttempts.
+//
+// Parameters:
+//   - maxAttempts: The max attempts.
+//
+// Returns:
+//   - Option: The option.
+func WithMaxAttempts(maxAttempts int) Option {
+	return func(o *Outbox) {
+		o.maxAttempts = maxAttempts
+	}
+}
+
+// WithBackoff sets the function returning the delay before the next attempt
+// of a message whose publish failed. The default is
+// queue.ExponentialBackoff(time.Second, time.Hour).
+//
+// Parameters:
+//   - backoff: The backoff function, given the number of attempts made.
+//
+// Returns:
+//   - Option: The option.
+func WithBackoff(backoff func(attempt int) time.Duration) Option {
+	return func(o *Outbox) {
+		o.backoff = backoff
+	}
+}
+
+// WithClock sets the function returning the current time.
+//
+// Parameters:
+//   - now: The clock function.
+//
+// Returns:
+//   - Option: The option.
+func WithClock(now func() time.Time) Option {
+	return func(o *Outbox) {
+		o.now = now
+	}
+}
+
+// NewOutbox creates a new outbox.
+//
+// Parameters:
+//   - table: The name of the outbox table.
+//   - queryBuilder: The SQL query builder.
+//   - options: Options for the outbox.
+//
+// Returns:
+//   - *Outbox: A new outbox.
+func NewOutbox(
+	table string, queryBuilder database.QueryBuilder, options ...Option,
+) *Outbox {
+	outbox := &Outbox{
+		table:        table,
+		queryBuilder: queryBuilder,
+		maxAttempts:  DefaultMaxAttempts,
+		backoff:      queue.ExponentialBackoff(time.Second, time.Hour),
+		now:          time.Now,
+	}
+	for _, option := range options {
+		option(outbox)
+	}
+	return outbox
+}
+
+// Add adds a message to the outbox in the transaction. If the key is empty,
+// a random key is generated.
+//
+// Example:
+//
+//	id, err := users.Insert(tx, user, queryBuilder, nil)
+//	if err != nil {
+//	    return err
+//	}
+//	_, err = outbox.Add(tx, "user_created", "", payload)
+//
+// Parameters:
+//   - tx: The transaction of the changes described by the message.
+//   - topic: The topic of the message, used as the event type.
+//   - key: The idempotency key of the message.
+//   - payload: The payload of the message.
+//
+// Returns:
+//   - *Message: The added message.
+//   - error: An error if the message cannot be inserted.
+func (o *Outbox) Add(
+	tx database.Tx, topic string, key string, payload []byte,
+) (*Message, error) {
+	if key == "" {
+		var err error
+		if key, err = newKey(); err != nil {
+			return nil, fmt.Errorf("Add: %w", err)
+		}
+	}
+	now := o.now()
+	message := &Message{
+		Key:         key,
+		Topic:       topic,
+		Payload:     payload,
+		AvailableAt: now,
+		CreatedAt:   now,
+		table:       o.table,
+	}
+	id, err := o.insert(tx, message)
+	if err != nil {
+		return nil, fmt.Errorf("Add: %w", err)
+	}
+	message.ID = id
+	return message, nil
+}
+
+// insert inserts the message and returns its ID. The ID is read with
+// RETURNING if the query builder supports it, as not all drivers report the
+// last insert ID.
+func (o *Outbox) insert(tx database.Tx, message *Message) (int64, error) {
+	if _, ok := o.queryBuilder.(database.ReturningDialect); !ok {
+		return database.NewMutateDBOps[*Message]().Insert(
+			tx, message, o.queryBuilder, o.errorChecker,
+		)
+	}
+	inserted, err := database.NewReturningDBOps[*Message]().Insert(
+		tx,
+		message,
+		&database.Returning{Projections: messageProjections(o.table)},
+		func() *Message { return &Message{table: o.table} },
+		o.queryBuilder,
+		o.errorChecker,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return inserted.ID, nil
+}
+
+// Purge deletes messages published before the given time.
+//
+// Parameters:
+//   - preparer: The database connection or transaction to use.
+//   - before: The time before which published messages are deleted.
+//
+// Returns:
+//   - int64: The number of deleted messages.
+//   - error: An error if the messages cannot be deleted.
+func (o *Outbox) Purge(
+	preparer database.Preparer, before time.Time,
+) (int64, error) {
+	count, err := database.NewMutateDBOps[*Message]().Delete(
+		preparer,
+		&Message{table: o.table},
+		[]database.Selector{
+			o.selector(ColumnPublishedAt, database.Less, before),
+		},
+		&database.DeleteOptions{},
+		o.queryBuilder,
+		o.errorChecker,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("Purge: %w", err)
+	}
+	return count, nil
+}
+
+// Requeue makes a dead-lettered message available again with its attempts
+// reset.
+//
+// Parameters:
+//   - preparer: The database connection or transaction to use.
+//   - id: The ID of the message.
+//
+// Returns:
+//   - bool: Whether a dead message was requeued.
+//   - error: An error if the message cannot be updated.
+func (o *Outbox) Requeue(preparer database.Preparer, id int64) (bool, error) {
+	count, err := database.NewMutateDBOps[*Message]().Update(
+		preparer,
+		&Message{table: o.table},
+		[]database.Selector{
+			o.selector(ColumnID, database.Equal, id),
+			o.selector(ColumnPublishedAt, database.Equal, nil),
+			o.selector(
+				ColumnAttempts, database.GreaterOrEqual, o.maxAttempts,
+			),
+		},
+		[]database.Update{
+			{Field: ColumnAttempts, Value: 0},
+			{Field: ColumnAvailableAt, Value: o.now()},
+		},
+		o.queryBuilder,
+		o.errorChecker,
+	)
+	if err != nil {
+		return false, fmt.Errorf("Requeue: %w", err)
+	}
+	return count > 0, nil
+}
+
+// pending selects and locks the available unpublished messages that are not
+// dead-lettered, in insertion order.
+func (o *Outbox) pending(tx database.Tx, limit int) ([]*Message, error) {
+	return database.NewReadDBOps[*Message]().GetMany(
+		tx,
+		&database.GetOptions{
+			Selectors: database.Selectors{
+				o.selector(ColumnPublishedAt, database.Equal, nil),
+				o.selector(ColumnAttempts, database.Less, o.maxAttempts),
+				o.selector(ColumnAvailableAt, database.LessOrEqual, o.now()),
+			},
+			Orders: database.Orders{{
+				Table:     o.table,
+				Field:     ColumnID,
+				Direction: database.OrderAsc,
+			}},
+			Page:        &database.Page{Limit: limit},
+			Projections: messageProjections(o.table),
+			Locking: database.NewLocking(database.LockForUpdate).
+				WithWait(database.LockSkipLocked),
+		},
+		func() *Message { return &Message{table: o.table} },
+		o.queryBuilder,
+		o.errorChecker,
+	)
+}
+
+// markPublished marks the message as published.
+func (o *Outbox) markPublished(tx database.Tx, message *Message) error {
+	publishedAt := o.now()
+	if err := o.update(tx, message, []database.Update{
+		database.NewUpdate(ColumnPublishedAt, publishedAt),
+	}); err != nil {
+		return err
+	}
+	message.PublishedAt = &publishedAt
+	return nil
+}
+
+// markFailed records a failed publish attempt of the message. The message
+// is retried after the backoff delay unless it has no attempts left.
+func (o *Outbox) markFailed(
+	tx database.Tx, message *Message, publishErr error,
+) error {
+	availableAt := o.now().Add(o.backoff(message.Attempts + 1))
+	if err := o.update(tx, message, []database.Update{
+		database.NewUpdate(
+			ColumnAttempts, database.Increment(ColumnAttempts, 1),
+		),
+		database.NewUpdate(ColumnLastError, publishErr.Error()),
+		database.NewUpdate(ColumnAvailableAt, availableAt),
+	}); err != nil {
+		return err
+	}
+	message.Attempts++
+	message.LastError = publishErr.Error()
+	message.AvailableAt = availableAt
+	return nil
+}
+
+// update updates the message.
+func (o *Outbox) update(
+	tx database.Tx, message *Message, updates []database.Update,
+) error {
+	_, err := database.NewMutateDBOps[*Message]().Update(
+		tx,
+		message,
+		[]database.Selector{
+			o.selector(ColumnID, database.Equal, message.ID),
+		},
+		updates,
+		o.queryBuilder,
+		o.errorChecker,
+	)
+	return err
+}
+
+// selector returns a selector on a column of the outbox table.
+func (o *Outbox) selector(
+	column string, predicate database.Predicate, value any,
+) database.Selector {
+	return database.Selector{
+		Table:     o.table,
+		Column:    column,
+		Predicate: predicate,
+		Value:     value,
+	}
+}
+
+// newKey returns a random idempotency key.
+func newKey() (string, error) {
+	key := make([]byte, 16)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(key), nil
+}
+
+// Publisher publishes outbox messages. Publishing must be idempotent from the
+// point of view of consumers, since messages are delivered at least once.
+type Publisher interface {
+	Publish(ctx context.Context, message *Message) error
+}
+
+// PublisherFunc is a function that implements Publisher.
+type PublisherFunc func(ctx context.Context, message *Message) error
+
+// Publish calls the function.
+func (f PublisherFunc) Publish(ctx context.Context, message *Message) error {
+	return f(ctx, message)
+}
+
+// EmitterPublisher publishes messages as events of an event emitter. The
+// event type is the topic of the message and the event data is the message.
+// The listeners are called synchronously with EmitSync, so a listener that
+// panics fails the publish and the message is retried.
+type EmitterPublisher struct {
+	eventEmitter *core.EventEmitter
+}
+
+// NewEmitterPublisher creates a new publisher for the event emitter.
+//
+// Parameters:
+//   - eventEmitter: The event emitter.
+//
+// Returns:
+//   - *EmitterPublisher: A new publisher.
+func NewEmitterPublisher(eventEmitter *core.EventEmitter) *EmitterPublisher {
+	return &EmitterPublisher{eventEmitter: eventEmitter}
+}
+
+// Publish emits the message as an event and waits for the listeners.
+func (p *EmitterPublisher) Publish(_ context.Context, message *Message) error {
+	return p.eventEmitter.EmitSync(
+		core.NewEvent(core.EventType(message.Topic), message.Key).
+			WithData(message),
+	)
+}
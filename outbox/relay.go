@@ -0,0 +1,124 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pakkasys/fluidapi/database"
+)
+
+// Relay polls the outbox and publishes unpublished messages in insertion
+// order, except that messages whose publish failed are retried after later
+// messages. Delivery is at least once: a message is marked as published only
+// after it has been published, so a crash in between publishes it again.
+type Relay struct {
+	outbox       *Outbox
+	db           database.DB
+	publisher    Publisher
+	batchSize    int
+	pollInterval time.Duration
+}
+
+// NewRelay creates a new relay.
+//
+// Parameters:
+//   - outbox: The outbox to relay.
+//   - db: The database connection.
+//   - publisher: The publisher of the messages.
+//   - batchSize: The max number of messages to relay at once.
+//   - pollInterval: The interval to wait when there are no messages.
+//
+// Returns:
+//   - *Relay: A new relay.
+func NewRelay(
+	outbox *Outbox,
+	db database.DB,
+	publisher Publisher,
+	batchSize int,
+	pollInterval time.Duration,
+) *Relay {
+	return &Relay{
+		outbox:       outbox,
+		db:           db,
+		publisher:    publisher,
+		batchSize:    batchSize,
+		pollInterval: pollInterval,
+	}
+}
+
+// Run relays messages until the context is done.
+//
+// Parameters:
+//   - ctx: The context of the relay.
+//
+// Returns:
+//   - error: An error if the outbox cannot be read or updated.
+func (r *Relay) Run(ctx context.Context) error {
+	for {
+		published, err := r.RelayBatch(ctx)
+		if err != nil {
+			return err
+		}
+		if published == r.batchSize {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(r.pollInterval):
+		}
+	}
+}
+
+// RelayBatch publishes a batch of unpublished messages in a transaction. The
+// messages are locked with SKIP LOCKED, so that concurrent relays publish
+// different messages. If publishing a message fails, the failure is recorded
+// on the message, which is retried after the backoff delay or dead-lettered
+// after the max attempts, and the rest of the batch is published.
+//
+// Parameters:
+//   - ctx: The context of the relay.
+//
+// Returns:
+//   - int: The number of published messages.
+//   - error: An error if the outbox cannot be read or updated.
+func (r *Relay) RelayBatch(ctx context.Context) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, nil
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("RelayBatch: %w", err)
+	}
+	published, err := database.Transaction(
+		ctx,
+		tx,
+		func(ctx context.Context, tx database.Tx) (int, error) {
+			messages, err := r.outbox.pending(tx, r.batchSize)
+			if err != nil {
+				return 0, err
+			}
+			published := 0
+			for _, message := range messages {
+				publishErr := r.publisher.Publish(ctx, message)
+				if publishErr != nil {
+					err := r.outbox.markFailed(tx, message, publishErr)
+					if err != nil {
+						return published, err
+					}
+					continue
+				}
+				if err := r.outbox.markPublished(tx, message); err != nil {
+					return published, err
+				}
+				published++
+			}
+			return published, nil
+		},
+	)
+	if err != nil {
+		return 0, fmt.Errorf("RelayBatch: %w", err)
+	}
+	return published, nil
+}
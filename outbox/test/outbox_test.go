@@ -0,0 +1,350 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pakkasys/fluidapi/core"
+	"github.com/pakkasys/fluidapi/database"
+	databasemock "github.com/pakkasys/fluidapi/database/mock"
+	"github.com/pakkasys/fluidapi/outbox"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// testNow is the fixed time used by the outbox tests.
+var testNow = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// newTestOutbox creates an outbox with a fixed clock and three attempts.
+func newTestOutbox(queryBuilder database.QueryBuilder) *outbox.Outbox {
+	return outbox.NewOutbox(
+		"outbox",
+		queryBuilder,
+		outbox.WithClock(func() time.Time { return testNow }),
+		outbox.WithMaxAttempts(3),
+		outbox.WithBackoff(func(attempt int) time.Duration {
+			return time.Duration(attempt) * time.Minute
+		}),
+	)
+}
+
+// expectPending sets up the selection of the given pending message IDs.
+func expectPending(
+	queryBuilder *databasemock.MockQueryBuilder,
+	db *databasemock.MockDB,
+	tx *databasemock.MockTx,
+	ids ...int64,
+) {
+	stmt := &databasemock.MockStmt{}
+	rows := &databasemock.MockRows{}
+	db.On("BeginTx", mock.Anything, mock.Anything).Return(tx, nil)
+	queryBuilder.On(
+		"SupportsLock", database.LockForUpdate, database.LockSkipLocked,
+	).Return(true)
+	queryBuilder.On("Get", "outbox", mock.MatchedBy(
+		func(options *database.GetOptions) bool {
+			return options.Locking.Wait == database.LockSkipLocked &&
+				assert.ObjectsAreEqual(database.Selectors{
+					{
+						Table:     "outbox",
+						Column:    outbox.ColumnPublishedAt,
+						Predicate: database.Equal,
+					},
+					{
+						Table:     "outbox",
+						Column:    outbox.ColumnAttempts,
+						Predicate: database.Less,
+						Value:     3,
+					},
+					{
+						Table:     "outbox",
+						Column:    outbox.ColumnAvailableAt,
+						Predicate: database.LessOrEqual,
+						Value:     testNow,
+					},
+				}, options.Selectors)
+		},
+	)).Return("SELECT", []any{})
+	tx.On("Prepare", "SELECT").Return(stmt, nil).Once()
+	stmt.On("Query", mock.Anything).Return(rows, nil)
+	stmt.On("Close").Return(nil)
+	for _, id := range ids {
+		rows.On("Next").Return(true).Once()
+		rows.On("Scan", mock.Anything).Run(func(args mock.Arguments) {
+			dest := args.Get(0).([]any)
+			*dest[0].(*int64) = id
+			*dest[2].(*string) = "user_created"
+		}).Return(nil).Once()
+	}
+	rows.On("Next").Return(false)
+	rows.On("Err").Return(nil)
+	rows.On("Close").Return(nil)
+}
+
+// TestAdd tests that a message is inserted with a generated key.
+func TestAdd(t *testing.T) {
+	queryBuilder := &databasemock.MockQueryBuilder{}
+	tx := &databasemock.MockTx{}
+	messages := newTestOutbox(queryBuilder)
+
+	queryBuilder.On("Insert", "outbox", mock.Anything).
+		Return("INSERT", []any{})
+	databasemock.ExpectExec(&tx.Mock, "INSERT", 1, 42)
+
+	message, err := messages.Add(tx, "user_created", "", []byte("{}"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), message.ID)
+	assert.Len(t, message.Key, 32)
+	assert.Equal(t, "user_created", message.Topic)
+	assert.Equal(t, testNow, message.CreatedAt)
+	assert.Equal(t, testNow, message.AvailableAt)
+	assert.Nil(t, message.PublishedAt)
+}
+
+// TestAdd_Key tests that a given idempotency key is kept.
+func TestAdd_Key(t *testing.T) {
+	queryBuilder := &databasemock.MockQueryBuilder{}
+	tx := &databasemock.MockTx{}
+	messages := newTestOutbox(queryBuilder)
+
+	queryBuilder.On("Insert", "outbox", mock.Anything).
+		Return("INSERT", []any{})
+	databasemock.ExpectExec(&tx.Mock, "INSERT", 1, 1)
+
+	message, err := messages.Add(tx, "user_created", "user-1", nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "user-1", message.Key)
+}
+
+// returningQueryBuilder is a query builder supporting RETURNING.
+type returningQueryBuilder struct {
+	*databasemock.MockQueryBuilder
+}
+
+func (b returningQueryBuilder) InsertReturning(
+	table string,
+	insertedValuesFunc database.InsertedValuesFn,
+	projections database.Projections,
+) (string, []any) {
+	args := b.Called(table, insertedValuesFunc, projections)
+	return args.String(0), args.Get(1).([]any)
+}
+
+func (b returningQueryBuilder) UpdateReturning(
+	table string,
+	updates []database.Update,
+	selectors []database.Selector,
+	projections database.Projections,
+) (string, []any) {
+	args := b.Called(table, updates, selectors, projections)
+	return args.String(0), args.Get(1).([]any)
+}
+
+func (b returningQueryBuilder) DeleteReturning(
+	table string,
+	selectors []database.Selector,
+	projections database.Projections,
+) (string, []any) {
+	args := b.Called(table, selectors, projections)
+	return args.String(0), args.Get(1).([]any)
+}
+
+// TestAdd_Returning tests that the message ID is read with RETURNING when the
+// query builder supports it, without relying on the last insert ID.
+func TestAdd_Returning(t *testing.T) {
+	queryBuilder := returningQueryBuilder{&databasemock.MockQueryBuilder{}}
+	tx := &databasemock.MockTx{}
+	stmt := &databasemock.MockStmt{}
+	row := &databasemock.MockRow{}
+	messages := newTestOutbox(queryBuilder)
+
+	queryBuilder.On(
+		"InsertReturning", "outbox", mock.Anything, mock.Anything,
+	).Return("INSERT RETURNING", []any{})
+	tx.On("Prepare", "INSERT RETURNING").Return(stmt, nil)
+	stmt.On("QueryRow", mock.Anything).Return(row)
+	stmt.On("Close").Return(nil)
+	row.On("Scan", mock.Anything).Run(func(args mock.Arguments) {
+		*args.Get(0).([]any)[0].(*int64) = 42
+	}).Return(nil)
+	row.On("Err").Return(nil)
+
+	message, err := messages.Add(tx, "user_created", "user-1", nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), message.ID)
+	assert.Equal(t, "user-1", message.Key)
+	queryBuilder.AssertNotCalled(t, "Insert", mock.Anything, mock.Anything)
+	stmt.AssertNotCalled(t, "Exec", mock.Anything)
+}
+
+// TestRelayBatch tests that pending messages are published in order and
+// marked as published.
+func TestRelayBatch(t *testing.T) {
+	queryBuilder := &databasemock.MockQueryBuilder{}
+	db := &databasemock.MockDB{}
+	tx := &databasemock.MockTx{}
+	messages := newTestOutbox(queryBuilder)
+
+	expectPending(queryBuilder, db, tx, 1, 2)
+	queryBuilder.On("UpdateQuery", "outbox", mock.Anything, mock.Anything).
+		Return("UPDATE", []any{})
+	databasemock.ExpectExec(&tx.Mock, "UPDATE", 1, 0)
+	databasemock.ExpectExec(&tx.Mock, "UPDATE", 1, 0)
+	tx.On("Commit").Return(nil)
+
+	var published []int64
+	relay := outbox.NewRelay(
+		messages,
+		db,
+		outbox.PublisherFunc(
+			func(_ context.Context, message *outbox.Message) error {
+				published = append(published, message.ID)
+				return nil
+			},
+		),
+		10,
+		time.Second,
+	)
+	count, err := relay.RelayBatch(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, []int64{1, 2}, published)
+	tx.AssertCalled(t, "Commit")
+}
+
+// TestRelayBatch_PublishError tests that a failed publish is recorded with
+// a backoff and the rest of the batch is published.
+func TestRelayBatch_PublishError(t *testing.T) {
+	queryBuilder := &databasemock.MockQueryBuilder{}
+	db := &databasemock.MockDB{}
+	tx := &databasemock.MockTx{}
+	messages := newTestOutbox(queryBuilder)
+
+	expectPending(queryBuilder, db, tx, 1, 2)
+	queryBuilder.On("QuoteColumn", "", "attempts").Return(`"attempts"`)
+	queryBuilder.On("Placeholder", 1).Return("$1")
+	queryBuilder.On("UpdateQuery", "outbox", mock.MatchedBy(
+		func(updates []database.Update) bool {
+			return len(updates) == 3 &&
+				updates[1].Field == outbox.ColumnLastError &&
+				updates[1].Value == "broker down" &&
+				updates[2].Field == outbox.ColumnAvailableAt &&
+				updates[2].Value == testNow.Add(time.Minute)
+		},
+	), mock.Anything).Return("FAILED", []any{}).Once()
+	queryBuilder.On("UpdateQuery", "outbox", mock.MatchedBy(
+		func(updates []database.Update) bool {
+			return len(updates) == 1 &&
+				updates[0].Field == outbox.ColumnPublishedAt
+		},
+	), mock.Anything).Return("PUBLISHED", []any{}).Once()
+	databasemock.ExpectExec(&tx.Mock, "FAILED", 1, 0)
+	databasemock.ExpectExec(&tx.Mock, "PUBLISHED", 1, 0)
+	tx.On("Commit").Return(nil)
+
+	var published []int64
+	relay := outbox.NewRelay(
+		messages,
+		db,
+		outbox.PublisherFunc(
+			func(_ context.Context, message *outbox.Message) error {
+				if message.ID == 1 {
+					return errors.New("broker down")
+				}
+				published = append(published, message.ID)
+				return nil
+			},
+		),
+		10,
+		time.Second,
+	)
+	count, err := relay.RelayBatch(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, []int64{2}, published)
+	queryBuilder.AssertExpectations(t)
+	tx.AssertCalled(t, "Commit")
+}
+
+// TestRequeue tests that dead-lettered messages are made available again.
+func TestRequeue(t *testing.T) {
+	queryBuilder := &databasemock.MockQueryBuilder{}
+	tx := &databasemock.MockTx{}
+	messages := newTestOutbox(queryBuilder)
+
+	queryBuilder.On("UpdateQuery", "outbox", []database.Update{
+		{Field: outbox.ColumnAttempts, Value: 0},
+		{Field: outbox.ColumnAvailableAt, Value: testNow},
+	}, mock.MatchedBy(func(selectors []database.Selector) bool {
+		return len(selectors) == 3 &&
+			selectors[2].Predicate == database.GreaterOrEqual &&
+			selectors[2].Value == 3
+	})).Return("UPDATE", []any{})
+	databasemock.ExpectExec(&tx.Mock, "UPDATE", 1, 0)
+
+	requeued, err := messages.Requeue(tx, 7)
+
+	assert.NoError(t, err)
+	assert.True(t, requeued)
+}
+
+// TestEmitterPublisher tests that messages are delivered synchronously to
+// the listeners of their topic.
+func TestEmitterPublisher(t *testing.T) {
+	emitter := core.NewEventEmitter()
+	var received []*core.Event
+	emitter.RegisterListener("user_created", func(event *core.Event) {
+		received = append(received, event)
+	})
+	message := &outbox.Message{ID: 1, Key: "user-1", Topic: "user_created"}
+
+	err := outbox.NewEmitterPublisher(emitter).Publish(
+		context.Background(), message,
+	)
+
+	assert.NoError(t, err)
+	assert.Len(t, received, 1)
+	assert.Equal(t, message, received[0].Data)
+}
+
+// TestEmitterPublisher_ListenerPanic tests that a panicking listener fails
+// the publish.
+func TestEmitterPublisher_ListenerPanic(t *testing.T) {
+	emitter := core.NewEventEmitter()
+	emitter.RegisterListener("user_created", func(*core.Event) {
+		panic("broker down")
+	})
+
+	err := outbox.NewEmitterPublisher(emitter).Publish(
+		context.Background(), &outbox.Message{Topic: "user_created"},
+	)
+
+	assert.EqualError(t, err, "event listener panicked: broker down")
+}
+
+// TestPurge tests that published messages are deleted.
+func TestPurge(t *testing.T) {
+	queryBuilder := &databasemock.MockQueryBuilder{}
+	tx := &databasemock.MockTx{}
+	messages := newTestOutbox(queryBuilder)
+
+	queryBuilder.On("Delete", "outbox", mock.MatchedBy(
+		func(selectors []database.Selector) bool {
+			return selectors[0].Predicate == database.Less &&
+				selectors[0].Value == testNow
+		},
+	), mock.Anything).Return("DELETE", []any{})
+	databasemock.ExpectExec(&tx.Mock, "DELETE", 3, 0)
+
+	count, err := messages.Purge(tx, testNow)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+}
@@ -0,0 +1,104 @@
+package queue
+
+import (
+	"time"
+
+	"github.com/pakkasys/fluidapi/database"
+)
+
+// Status is the status of a job.
+type Status string
+
+// Job statuses. A pending job is available when its AvailableAt has passed.
+// Claimed jobs stay pending with AvailableAt moved past the visibility
+// timeout, so they become available again if the worker does not finish
+// them in time.
+const (
+	StatusPending Status = "pending"
+	StatusDone    Status = "done"
+	StatusDead    Status = "dead" // Dead-lettered after the last attempt.
+)
+
+// Job columns in scan order.
+const (
+	ColumnID          = "id"
+	ColumnQueue       = "queue"
+	ColumnPayload     = "payload"
+	ColumnStatus      = "status"
+	ColumnAttempts    = "attempts"
+	ColumnMaxAttempts = "max_attempts"
+	ColumnAvailableAt = "available_at"
+	ColumnLastError   = "last_error"
+	ColumnCreatedAt   = "created_at"
+)
+
+// jobColumns are the columns of the job table in scan order.
+var jobColumns = []string{
+	ColumnID,
+	ColumnQueue,
+	ColumnPayload,
+	ColumnStatus,
+	ColumnAttempts,
+	ColumnMaxAttempts,
+	ColumnAvailableAt,
+	ColumnLastError,
+	ColumnCreatedAt,
+}
+
+// Job is a job stored in the job table.
+type Job struct {
+	ID          int64
+	Queue       string
+	Payload     []byte
+	Status      Status
+	Attempts    int
+	MaxAttempts int
+	AvailableAt time.Time
+	LastError   string
+	CreatedAt   time.Time
+
+	table string
+}
+
+// TableName returns the name of the job table.
+func (j *Job) TableName() string {
+	return j.table
+}
+
+// InsertedValues returns the columns and values of the job for insertion.
+func (j *Job) InsertedValues() ([]string, []any) {
+	return jobColumns[1:], []any{
+		j.Queue,
+		j.Payload,
+		j.Status,
+		j.Attempts,
+		j.MaxAttempts,
+		j.AvailableAt,
+		j.LastError,
+		j.CreatedAt,
+	}
+}
+
+// ScanRow scans a row of the job columns into the job.
+func (j *Job) ScanRow(row database.Row) error {
+	return row.Scan(
+		&j.ID,
+		&j.Queue,
+		&j.Payload,
+		&j.Status,
+		&j.Attempts,
+		&j.MaxAttempts,
+		&j.AvailableAt,
+		&j.LastError,
+		&j.CreatedAt,
+	)
+}
+
+// jobProjections returns the projections of the job columns of the table.
+func jobProjections(table string) database.Projections {
+	projections := make(database.Projections, len(jobColumns))
+	for i, column := range jobColumns {
+		projections[i] = database.Projection{Table: table, Column: column}
+	}
+	return projections
+}
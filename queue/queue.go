@@ -0,0 +1,568 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/pakkasys/fluidapi/core"
+	"github.com/pakkasys/fluidapi/database"
+)
+
+// Job lifecycle events. The data of the events is the *Job.
+const (
+	EventJobEnqueued     core.EventType = "job_enqueued"
+	EventJobClaimed      core.EventType = "job_claimed"
+	EventJobCompleted    core.EventType = "job_completed"
+	EventJobRetried      core.EventType = "job_retried"
+	EventJobDeadLettered core.EventType = "job_dead_lettered"
+)
+
+// EventWorkerFailed is emitted when a worker fails to claim or update jobs
+// and will retry. The data of the event is the error.
+const EventWorkerFailed core.EventType = "worker_failed"
+
+// ErrJobLost is returned when a job cannot be completed or failed because its
+// visibility timeout expired and it was claimed again.
+var ErrJobLost = errors.New("job lost: visibility timeout expired")
+
+// Default queue settings.
+const (
+	DefaultVisibilityTimeout = 5 * time.Minute
+	DefaultMaxAttempts       = 5
+)
+
+// Queue is a job queue stored in a database table. The table must have the
+// columns of Job:
+//
+//	id           auto-increment primary key
+//	queue        string
+//	payload      bytes
+//	status       string
+//	attempts     int
+//	max_attempts int
+//	available_at timestamp, indexed together with queue and status
+//	last_error   string
+//	created_at   timestamp
+//
+// Jobs are claimed with FOR UPDATE SKIP LOCKED, so the query builder must
+// implement database.LockDialect and database.ExprDialect. If it implements
+// database.ReturningDialect, e.g. for Postgres, the IDs of enqueued jobs are
+// read with RETURNING, otherwise they are the last insert IDs, e.g. for MySQL.
+type Queue struct {
+	table             string
+	queryBuilder      database.QueryBuilder
+	errorChecker      database.ErrorChecker
+	eventEmitter      *core.EventEmitter
+	visibilityTimeout time.Duration
+	maxAttempts       int
+	backoff           func(attempt int) time.Duration
+	now               func() time.Time
+}
+
+// Option is a function that configures a queue.
+type Option func(*Queue)
+
+// WithVisibilityTimeout sets how long a claimed job is hidden from other
+// workers. The default is DefaultVisibilityTimeout.
+//
+// Parameters:
+//   - timeout: The visibility timeout.
+//
+// Returns:
+//   - Option: The option.
+func WithVisibilityTimeout(timeout time.Duration) Option {
+	return func(q *Queue) {
+		q.visibilityTimeout = timeout
+	}
+}
+
+// WithMaxAttempts sets the default max attempts of enqueued jobs. The default
+// is DefaultMaxAttempts.
+//
+// Parameters:
+//   - maxAttempts: The max attempts.
+//
+// Returns:
+//   - Option: The option.
+func WithMaxAttempts(maxAttempts int) Option {
+	return func(q *Queue) {
+		q.maxAttempts = maxAttempts
+	}
+}
+
+// WithBackoff sets the function returning the delay before the next attempt
+// of a failed job. The default is ExponentialBackoff(time.Second, time.Hour).
+//
+// Parameters:
+//   - backoff: The backoff function, given the number of attempts made.
+//
+// Returns:
+//   - Option: The option.
+func WithBackoff(backoff func(attempt int) time.Duration) Option {
+	return func(q *Queue) {
+		q.backoff = backoff
+	}
+}
+
+// WithEventEmitter sets the event emitter for job lifecycle events.
+//
+// Parameters:
+//   - eventEmitter: The event emitter.
+//
+// Returns:
+//   - Option: The option.
+func WithEventEmitter(eventEmitter *core.EventEmitter) Option {
+	return func(q *Queue) {
+		q.eventEmitter = eventEmitter
+	}
+}
+
+// WithErrorChecker sets the error checker for database errors.
+//
+// Parameters:
+//   - errorChecker: The error checker.
+//
+// Returns:
+//   - Option: The option.
+func WithErrorChecker(errorChecker database.ErrorChecker) Option {
+	return func(q *Queue) {
+		q.errorChecker = errorChecker
+	}
+}
+
+// WithClock sets the function returning the current time.
+//
+// Parameters:
+//   - now: The clock function.
+//
+// Returns:
+//   - Option: The option.
+func WithClock(now func() time.Time) Option {
+	return func(q *Queue) {
+		q.now = now
+	}
+}
+
+// ExponentialBackoff returns a backoff function doubling the delay after
+// each attempt, starting from base and capped at max.
+//
+// Parameters:
+//   - base: The delay after the first attempt.
+//   - max: The max delay.
+//
+// Returns:
+//   - func(attempt int) time.Duration: The backoff function.
+func ExponentialBackoff(
+	base time.Duration, max time.Duration,
+) func(attempt int) time.Duration {
+	return func(attempt int) time.Duration {
+		delay := base
+		for i := 1; i < attempt && delay < max; i++ {
+			delay *= 2
+		}
+		return min(delay, max)
+	}
+}
+
+// NewQueue creates a new job queue.
+//
+// Parameters:
+//   - table: The name of the job table.
+//   - queryBuilder: The SQL query builder.
+//   - options: Options for the queue.
+//
+// Returns:
+//   - *Queue: A new job queue.
+func NewQueue(
+	table string, queryBuilder database.QueryBuilder, options ...Option,
+) *Queue {
+	queue := &Queue{
+		table:             table,
+		queryBuilder:      queryBuilder,
+		visibilityTimeout: DefaultVisibilityTimeout,
+		maxAttempts:       DefaultMaxAttempts,
+		backoff:           ExponentialBackoff(time.Second, time.Hour),
+		now:               time.Now,
+	}
+	for _, option := range options {
+		option(queue)
+	}
+	return queue
+}
+
+// EnqueueOption is a function that configures an enqueued job.
+type EnqueueOption func(*Job)
+
+// WithDelay delays the first attempt of the job.
+//
+// Parameters:
+//   - delay: The delay.
+//
+// Returns:
+//   - EnqueueOption: The option.
+func WithDelay(delay time.Duration) EnqueueOption {
+	return func(j *Job) {
+		j.AvailableAt = j.AvailableAt.Add(delay)
+	}
+}
+
+// WithJobMaxAttempts sets the max attempts of the job.
+//
+// Parameters:
+//   - maxAttempts: The max attempts.
+//
+// Returns:
+//   - EnqueueOption: The option.
+func WithJobMaxAttempts(maxAttempts int) EnqueueOption {
+	return func(j *Job) {
+		j.MaxAttempts = maxAttempts
+	}
+}
+
+// Enqueue adds a job to the queue. Pass a transaction as the preparer to
+// enqueue the job atomically with other changes.
+//
+// Parameters:
+//   - preparer: The database connection or transaction to use.
+//   - queueName: The name of the queue.
+//   - payload: The payload of the job.
+//   - options: Options for the job.
+//
+// Returns:
+//   - *Job: The enqueued job.
+//   - error: An error if the job cannot be inserted.
+func (q *Queue) Enqueue(
+	preparer database.Preparer,
+	queueName string,
+	payload []byte,
+	options ...EnqueueOption,
+) (*Job, error) {
+	now := q.now()
+	job := &Job{
+		Queue:       queueName,
+		Payload:     payload,
+		Status:      StatusPending,
+		MaxAttempts: q.maxAttempts,
+		AvailableAt: now,
+		CreatedAt:   now,
+		table:       q.table,
+	}
+	for _, option := range options {
+		option(job)
+	}
+
+	id, err := q.insert(preparer, job)
+	if err != nil {
+		return nil, fmt.Errorf("Enqueue: %w", err)
+	}
+	job.ID = id
+	q.emit(EventJobEnqueued, "Job enqueued", job)
+	return job, nil
+}
+
+// Claim claims up to limit available jobs of the queue in a transaction.
+// Jobs locked by other workers are skipped. The claimed jobs are hidden from
+// other workers for the visibility timeout and their attempts are
+// incremented. Jobs whose claim expired after their last attempt are
+// dead-lettered.
+//
+// Parameters:
+//   - ctx: The context for the transaction.
+//   - db: The database connection.
+//   - queueName: The name of the queue.
+//   - limit: The max number of jobs to claim.
+//
+// Returns:
+//   - []*Job: The claimed jobs.
+//   - error: An error if the jobs cannot be claimed.
+func (q *Queue) Claim(
+	ctx context.Context, db database.DB, queueName string, limit int,
+) ([]*Job, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Claim: %w", err)
+	}
+	jobs, err := database.Transaction(
+		ctx,
+		tx,
+		func(ctx context.Context, tx database.Tx) ([]*Job, error) {
+			return q.claim(tx, queueName, limit)
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("Claim: %w", err)
+	}
+	for _, job := range jobs {
+		q.emit(EventJobClaimed, "Job claimed", job)
+	}
+	return jobs, nil
+}
+
+// Complete marks a claimed job as done.
+//
+// Parameters:
+//   - preparer: The database connection or transaction to use.
+//   - job: The claimed job.
+//
+// Returns:
+//   - error: ErrJobLost if the job was claimed again, or another error if
+//     the job cannot be updated.
+func (q *Queue) Complete(preparer database.Preparer, job *Job) error {
+	if err := q.updateClaimed(preparer, job, []database.Update{
+		{Field: ColumnStatus, Value: StatusDone},
+	}); err != nil {
+		return fmt.Errorf("Complete: %w", err)
+	}
+	job.Status = StatusDone
+	q.emit(EventJobCompleted, "Job completed", job)
+	return nil
+}
+
+// Fail records a failed attempt of a claimed job. The job is retried after
+// the backoff delay, or dead-lettered if it has no attempts left.
+//
+// Parameters:
+//   - preparer: The database connection or transaction to use.
+//   - job: The claimed job.
+//   - jobErr: The error of the attempt.
+//
+// Returns:
+//   - error: ErrJobLost if the job was claimed again, or another error if
+//     the job cannot be updated.
+func (q *Queue) Fail(
+	preparer database.Preparer, job *Job, jobErr error,
+) error {
+	lastError := ""
+	if jobErr != nil {
+		lastError = jobErr.Error()
+	}
+	updates := []database.Update{
+		{Field: ColumnLastError, Value: lastError},
+	}
+	dead := job.Attempts >= job.MaxAttempts
+	availableAt := q.now().Add(q.backoff(job.Attempts))
+	if dead {
+		updates = append(updates, database.Update{
+			Field: ColumnStatus, Value: StatusDead,
+		})
+	} else {
+		updates = append(updates, database.Update{
+			Field: ColumnAvailableAt, Value: availableAt,
+		})
+	}
+	if err := q.updateClaimed(preparer, job, updates); err != nil {
+		return fmt.Errorf("Fail: %w", err)
+	}
+
+	job.LastError = lastError
+	if dead {
+		job.Status = StatusDead
+		q.emit(EventJobDeadLettered, "Job dead-lettered", job)
+	} else {
+		job.AvailableAt = availableAt
+		q.emit(EventJobRetried, "Job retried", job)
+	}
+	return nil
+}
+
+// Requeue makes a dead-lettered job available again with its attempts
+// reset.
+//
+// Parameters:
+//   - preparer: The database connection or transaction to use.
+//   - id: The ID of the job.
+//
+// Returns:
+//   - bool: Whether a dead job was requeued.
+//   - error: An error if the job cannot be updated.
+func (q *Queue) Requeue(preparer database.Preparer, id int64) (bool, error) {
+	count, err := database.NewMutateDBOps[*Job]().Update(
+		preparer,
+		&Job{table: q.table},
+		[]database.Selector{
+			q.selector(ColumnID, database.Equal, id),
+			q.selector(ColumnStatus, database.Equal, StatusDead),
+		},
+		[]database.Update{
+			{Field: ColumnStatus, Value: StatusPending},
+			{Field: ColumnAttempts, Value: 0},
+			{Field: ColumnAvailableAt, Value: q.now()},
+		},
+		q.queryBuilder,
+		q.errorChecker,
+	)
+	if err != nil {
+		return false, fmt.Errorf("Requeue: %w", err)
+	}
+	return count > 0, nil
+}
+
+// insert inserts the job and returns its ID.
+func (q *Queue) insert(preparer database.Preparer, job *Job) (int64, error) {
+	if _, ok := q.queryBuilder.(database.ReturningDialect); !ok {
+		return database.NewMutateDBOps[*Job]().Insert(
+			preparer, job, q.queryBuilder, q.errorChecker,
+		)
+	}
+	inserted, err := database.NewReturningDBOps[*Job]().Insert(
+		preparer,
+		job,
+		&database.Returning{Projections: jobProjections(q.table)},
+		func() *Job { return &Job{table: q.table} },
+		q.queryBuilder,
+		q.errorChecker,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return inserted.ID, nil
+}
+
+// claim dead-letters the expired jobs without attempts left and selects and
+// claims the available jobs in the transaction.
+func (q *Queue) claim(
+	tx database.Tx, queueName string, limit int,
+) ([]*Job, error) {
+	now := q.now()
+	available := []database.Selector{
+		q.selector(ColumnQueue, database.Equal, queueName),
+		q.selector(ColumnStatus, database.Equal, StatusPending),
+		q.selector(ColumnAvailableAt, database.LessOrEqual, now),
+	}
+	maxAttempts := database.ColumnExpr{
+		Table: q.table, Column: ColumnMaxAttempts,
+	}
+	_, err := database.NewMutateDBOps[*Job]().Update(
+		tx,
+		&Job{table: q.table},
+		append(slices.Clip(available), q.selector(
+			ColumnAttempts, database.GreaterOrEqual, maxAttempts,
+		)),
+		[]database.Update{
+			{Field: ColumnStatus, Value: StatusDead},
+			{Field: ColumnLastError, Value: ErrJobLost.Error()},
+		},
+		q.queryBuilder,
+		q.errorChecker,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	jobs, err := database.NewReadDBOps[*Job]().GetMany(
+		tx,
+		&database.GetOptions{
+			Selectors: append(slices.Clip(available), q.selector(
+				ColumnAttempts, database.Less, maxAttempts,
+			)),
+			Orders: database.Orders{
+				q.order(ColumnAvailableAt),
+				q.order(ColumnID),
+			},
+			Page:        &database.Page{Limit: limit},
+			Projections: jobProjections(q.table),
+			Locking: database.NewLocking(database.LockForUpdate).
+				WithWait(database.LockSkipLocked),
+		},
+		func() *Job { return &Job{table: q.table} },
+		q.queryBuilder,
+		q.errorChecker,
+	)
+	if err != nil || len(jobs) == 0 {
+		return nil, err
+	}
+
+	ids := make([]int64, len(jobs))
+	for i, job := range jobs {
+		ids[i] = job.ID
+	}
+	availableAt := now.Add(q.visibilityTimeout)
+	_, err = database.NewMutateDBOps[*Job]().Update(
+		tx,
+		&Job{table: q.table},
+		[]database.Selector{q.selector(ColumnID, database.In, ids)},
+		[]database.Update{
+			database.NewUpdate(
+				ColumnAttempts, database.Increment(ColumnAttempts, 1),
+			),
+			{Field: ColumnAvailableAt, Value: availableAt},
+		},
+		q.queryBuilder,
+		q.errorChecker,
+	)
+	if err != nil {
+		return nil, err
+	}
+	for _, job := range jobs {
+		job.Attempts++
+		job.AvailableAt = availableAt
+	}
+	return jobs, nil
+}
+
+// updateClaimed updates a claimed job. The update only matches if the job
+// has not been claimed again since.
+func (q *Queue) updateClaimed(
+	preparer database.Preparer, job *Job, updates []database.Update,
+) error {
+	count, err := database.NewMutateDBOps[*Job]().Update(
+		preparer,
+		job,
+		[]database.Selector{
+			q.selector(ColumnID, database.Equal, job.ID),
+			q.selector(ColumnStatus, database.Equal, StatusPending),
+			q.selector(ColumnAttempts, database.Equal, job.Attempts),
+		},
+		updates,
+		q.queryBuilder,
+		q.errorChecker,
+	)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrJobLost
+	}
+	return nil
+}
+
+// selector returns a selector on a column of the job table.
+func (q *Queue) selector(
+	column string, predicate database.Predicate, value any,
+) database.Selector {
+	return database.Selector{
+		Table:     q.table,
+		Column:    column,
+		Predicate: predicate,
+		Value:     value,
+	}
+}
+
+// order returns an ascending order on a column of the job table.
+func (q *Queue) order(column string) database.Order {
+	return database.Order{
+		Table:     q.table,
+		Field:     column,
+		Direction: database.OrderAsc,
+	}
+}
+
+// emit emits a job event if the queue has an event emitter.
+func (q *Queue) emit(eventType core.EventType, message string, job *Job) {
+	if q.eventEmitter != nil {
+		q.eventEmitter.Emit(core.NewEvent(eventType, message).WithData(job))
+	}
+}
+
+// emitWorkerFailed emits a worker failure event if the queue has an event
+// emitter.
+func (q *Queue) emitWorkerFailed(err error) {
+	if q.eventEmitter != nil {
+		q.eventEmitter.Emit(
+			core.NewEvent(EventWorkerFailed, "Worker failed").WithData(err),
+		)
+	}
+}
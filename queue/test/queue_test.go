@@ -0,0 +1,353 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pakkasys/fluidapi/core"
+	"github.com/pakkasys/fluidapi/database"
+	databasemock "github.com/pakkasys/fluidapi/database/mock"
+	"github.com/pakkasys/fluidapi/queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// testNow is the fixed time used by the queue tests.
+var testNow = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// newTestQueue creates a queue with a fixed clock and the given events
+// channel.
+func newTestQueue(
+	queryBuilder database.QueryBuilder, events chan *core.Event,
+) *queue.Queue {
+	emitter := core.NewEventEmitter()
+	for _, eventType := range []core.EventType{
+		queue.EventJobEnqueued,
+		queue.EventJobClaimed,
+		queue.EventJobCompleted,
+		queue.EventJobRetried,
+		queue.EventJobDeadLettered,
+		queue.EventWorkerFailed,
+	} {
+		emitter.RegisterListener(eventType, func(event *core.Event) {
+			events <- event
+		})
+	}
+	return queue.NewQueue(
+		"job",
+		queryBuilder,
+		queue.WithClock(func() time.Time { return testNow }),
+		queue.WithEventEmitter(emitter),
+		queue.WithVisibilityTimeout(time.Minute),
+		queue.WithBackoff(queue.ExponentialBackoff(time.Second, time.Minute)),
+	)
+}
+
+// receiveEvent waits for an event.
+func receiveEvent(t *testing.T, events chan *core.Event) *core.Event {
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("event not emitted")
+		return nil
+	}
+}
+
+// TestEnqueue tests that a pending job is inserted and an event emitted.
+func TestEnqueue(t *testing.T) {
+	queryBuilder := &databasemock.MockQueryBuilder{}
+	tx := &databasemock.MockTx{}
+	events := make(chan *core.Event, 1)
+	jobQueue := newTestQueue(queryBuilder, events)
+
+	queryBuilder.On("Insert", "job", mock.Anything).Return("INSERT", []any{})
+	databasemock.ExpectExec(&tx.Mock, "INSERT", 1, 42)
+
+	job, err := jobQueue.Enqueue(
+		tx, "emails", []byte("{}"), queue.WithDelay(time.Hour),
+	)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), job.ID)
+	assert.Equal(t, queue.StatusPending, job.Status)
+	assert.Equal(t, testNow.Add(time.Hour), job.AvailableAt)
+	assert.Equal(t, queue.EventJobEnqueued, receiveEvent(t, events).Type)
+}
+
+// returningQueryBuilder is a query builder supporting RETURNING.
+type returningQueryBuilder struct {
+	*databasemock.MockQueryBuilder
+}
+
+func (b returningQueryBuilder) InsertReturning(
+	table string,
+	insertedValuesFunc database.InsertedValuesFn,
+	projections database.Projections,
+) (string, []any) {
+	args := b.Called(table, insertedValuesFunc, projections)
+	return args.String(0), args.Get(1).([]any)
+}
+
+func (b returningQueryBuilder) UpdateReturning(
+	table string,
+	updates []database.Update,
+	selectors []database.Selector,
+	projections database.Projections,
+) (string, []any) {
+	args := b.Called(table, updates, selectors, projections)
+	return args.String(0), args.Get(1).([]any)
+}
+
+func (b returningQueryBuilder) DeleteReturning(
+	table string,
+	selectors []database.Selector,
+	projections database.Projections,
+) (string, []any) {
+	args := b.Called(table, selectors, projections)
+	return args.String(0), args.Get(1).([]any)
+}
+
+// TestEnqueue_Returning tests that the job ID is read with RETURNING when
+// the query builder supports it.
+func TestEnqueue_Returning(t *testing.T) {
+	queryBuilder := returningQueryBuilder{&databasemock.MockQueryBuilder{}}
+	tx := &databasemock.MockTx{}
+	stmt := &databasemock.MockStmt{}
+	row := &databasemock.MockRow{}
+	jobQueue := newTestQueue(queryBuilder, make(chan *core.Event, 1))
+
+	queryBuilder.On("InsertReturning", "job", mock.Anything, mock.Anything).
+		Return("INSERT RETURNING", []any{})
+	tx.On("Prepare", "INSERT RETURNING").Return(stmt, nil)
+	stmt.On("QueryRow", mock.Anything).Return(row)
+	stmt.On("Close").Return(nil)
+	row.On("Scan", mock.Anything).Run(func(args mock.Arguments) {
+		*args.Get(0).([]any)[0].(*int64) = 42
+	}).Return(nil)
+	row.On("Err").Return(nil)
+
+	job, err := jobQueue.Enqueue(tx, "emails", []byte("{}"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), job.ID)
+	queryBuilder.AssertNotCalled(t, "Insert", mock.Anything, mock.Anything)
+}
+
+// TestClaim tests that available jobs are selected with SKIP LOCKED and
+// hidden for the visibility timeout.
+func TestClaim(t *testing.T) {
+	queryBuilder := &databasemock.MockQueryBuilder{}
+	db := &databasemock.MockDB{}
+	tx := &databasemock.MockTx{}
+	stmt := &databasemock.MockStmt{}
+	rows := &databasemock.MockRows{}
+	events := make(chan *core.Event, 1)
+	jobQueue := newTestQueue(queryBuilder, events)
+
+	db.On("BeginTx", mock.Anything, mock.Anything).Return(tx, nil)
+	queryBuilder.On(
+		"SupportsLock", database.LockForUpdate, database.LockSkipLocked,
+	).Return(true)
+	queryBuilder.On("UpdateQuery", "job", mock.MatchedBy(
+		func(updates []database.Update) bool {
+			return updates[0].Value == queue.StatusDead
+		},
+	), mock.MatchedBy(func(selectors []database.Selector) bool {
+		return assert.ObjectsAreEqual(database.Selector{
+			Table:     "job",
+			Column:    queue.ColumnAttempts,
+			Predicate: database.GreaterOrEqual,
+			Value: database.ColumnExpr{
+				Table: "job", Column: queue.ColumnMaxAttempts,
+			},
+		}, selectors[len(selectors)-1])
+	})).Return("DEAD LETTER", []any{}).Once()
+	databasemock.ExpectExec(&tx.Mock, "DEAD LETTER", 0, 0)
+	queryBuilder.On("Get", "job", mock.MatchedBy(
+		func(options *database.GetOptions) bool {
+			attempts := options.Selectors[len(options.Selectors)-1]
+			return options.Locking.Wait == database.LockSkipLocked &&
+				options.Page.Limit == 10 &&
+				attempts.Column == queue.ColumnAttempts &&
+				attempts.Predicate == database.Less
+		},
+	)).Return("SELECT", []any{})
+	tx.On("Prepare", "SELECT").Return(stmt, nil).Once()
+	stmt.On("Query", mock.Anything).Return(rows, nil)
+	stmt.On("Close").Return(nil)
+	rows.On("Next").Return(true).Once()
+	rows.On("Next").Return(false)
+	rows.On("Scan", mock.Anything).Run(func(args mock.Arguments) {
+		dest := args.Get(0).([]any)
+		*dest[0].(*int64) = 7
+		*dest[3].(*queue.Status) = queue.StatusPending
+		*dest[4].(*int) = 1
+		*dest[5].(*int) = 3
+	}).Return(nil)
+	rows.On("Err").Return(nil)
+	rows.On("Close").Return(nil)
+	queryBuilder.On("QuoteColumn", "", "attempts").Return(`"attempts"`)
+	queryBuilder.On("Placeholder", 1).Return("$1")
+	queryBuilder.On("UpdateQuery", "job", mock.Anything, mock.Anything).
+		Return("UPDATE", []any{})
+	databasemock.ExpectExec(&tx.Mock, "UPDATE", 1, 0)
+	tx.On("Commit").Return(nil)
+
+	jobs, err := jobQueue.Claim(context.Background(), db, "emails", 10)
+
+	assert.NoError(t, err)
+	queryBuilder.AssertExpectations(t)
+	assert.Len(t, jobs, 1)
+	assert.Equal(t, int64(7), jobs[0].ID)
+	assert.Equal(t, 2, jobs[0].Attempts)
+	assert.Equal(t, testNow.Add(time.Minute), jobs[0].AvailableAt)
+	assert.Equal(t, queue.EventJobClaimed, receiveEvent(t, events).Type)
+	tx.AssertCalled(t, "Commit")
+}
+
+// TestFail_Retry tests that a failed job with attempts left is retried after
+// the backoff.
+func TestFail_Retry(t *testing.T) {
+	queryBuilder := &databasemock.MockQueryBuilder{}
+	db := &databasemock.MockDB{}
+	events := make(chan *core.Event, 1)
+	jobQueue := newTestQueue(queryBuilder, events)
+	job := &queue.Job{ID: 7, Attempts: 2, MaxAttempts: 3}
+
+	queryBuilder.On("UpdateQuery", "", mock.Anything, mock.Anything).
+		Return("UPDATE", []any{})
+	databasemock.ExpectExec(&db.Mock, "UPDATE", 1, 0)
+
+	err := jobQueue.Fail(db, job, errors.New("boom"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "boom", job.LastError)
+	assert.Equal(t, testNow.Add(2*time.Second), job.AvailableAt)
+	assert.Equal(t, queue.EventJobRetried, receiveEvent(t, events).Type)
+}
+
+// TestFail_DeadLetter tests that a failed job without attempts left is
+// dead-lettered.
+func TestFail_DeadLetter(t *testing.T) {
+	queryBuilder := &databasemock.MockQueryBuilder{}
+	db := &databasemock.MockDB{}
+	events := make(chan *core.Event, 1)
+	jobQueue := newTestQueue(queryBuilder, events)
+	job := &queue.Job{ID: 7, Attempts: 3, MaxAttempts: 3}
+
+	queryBuilder.On("UpdateQuery", "", mock.Anything, mock.Anything).
+		Return("UPDATE", []any{})
+	databasemock.ExpectExec(&db.Mock, "UPDATE", 1, 0)
+
+	err := jobQueue.Fail(db, job, errors.New("boom"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, queue.StatusDead, job.Status)
+	assert.Equal(t, queue.EventJobDeadLettered, receiveEvent(t, events).Type)
+}
+
+// TestComplete_Lost tests that completing a job that was claimed again
+// returns ErrJobLost.
+func TestComplete_Lost(t *testing.T) {
+	queryBuilder := &databasemock.MockQueryBuilder{}
+	db := &databasemock.MockDB{}
+	jobQueue := newTestQueue(queryBuilder, make(chan *core.Event, 1))
+
+	queryBuilder.On("UpdateQuery", "", mock.Anything, mock.Anything).
+		Return("UPDATE", []any{})
+	databasemock.ExpectExec(&db.Mock, "UPDATE", 0, 0)
+
+	err := jobQueue.Complete(db, &queue.Job{ID: 7, Attempts: 1})
+
+	assert.ErrorIs(t, err, queue.ErrJobLost)
+}
+
+// TestProcessBatch_ClaimsOneAtATime tests that the worker claims a single
+// job at a time and stops when the queue is empty.
+func TestProcessBatch_ClaimsOneAtATime(t *testing.T) {
+	queryBuilder := &databasemock.MockQueryBuilder{}
+	db := &databasemock.MockDB{}
+	tx := &databasemock.MockTx{}
+	stmt := &databasemock.MockStmt{}
+	rows := &databasemock.MockRows{}
+	jobQueue := newTestQueue(queryBuilder, make(chan *core.Event, 1))
+	worker := queue.NewWorker(
+		jobQueue,
+		db,
+		"emails",
+		func(ctx context.Context, job *queue.Job) error { return nil },
+		10,
+		time.Second,
+	)
+
+	db.On("BeginTx", mock.Anything, mock.Anything).Return(tx, nil)
+	queryBuilder.On(
+		"SupportsLock", database.LockForUpdate, database.LockSkipLocked,
+	).Return(true)
+	queryBuilder.On("UpdateQuery", "job", mock.Anything, mock.Anything).
+		Return("DEAD LETTER", []any{})
+	databasemock.ExpectExec(&tx.Mock, "DEAD LETTER", 0, 0)
+	queryBuilder.On("Get", "job", mock.MatchedBy(
+		func(options *database.GetOptions) bool {
+			return options.Page.Limit == 1
+		},
+	)).Return("SELECT", []any{})
+	tx.On("Prepare", "SELECT").Return(stmt, nil)
+	stmt.On("Query", mock.Anything).Return(rows, nil)
+	stmt.On("Close").Return(nil)
+	rows.On("Next").Return(false)
+	rows.On("Err").Return(nil)
+	rows.On("Close").Return(nil)
+	tx.On("Commit").Return(nil)
+
+	processed, err := worker.ProcessBatch(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, processed)
+	queryBuilder.AssertExpectations(t)
+}
+
+// TestWorkerRun_Retry tests that the worker retries after a failure and
+// stops cleanly when the context is canceled.
+func TestWorkerRun_Retry(t *testing.T) {
+	db := &databasemock.MockDB{}
+	events := make(chan *core.Event, 1)
+	jobQueue := newTestQueue(&databasemock.MockQueryBuilder{}, events)
+	worker := queue.NewWorker(
+		jobQueue,
+		db,
+		"emails",
+		func(ctx context.Context, job *queue.Job) error { return nil },
+		10,
+		time.Millisecond,
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db.On("BeginTx", mock.Anything, mock.Anything).
+		Return((*databasemock.MockTx)(nil), errors.New("connection lost")).
+		Once()
+	db.On("BeginTx", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { cancel() }).
+		Return((*databasemock.MockTx)(nil), context.Canceled).
+		Once()
+
+	err := worker.Run(ctx)
+
+	assert.NoError(t, err)
+	db.AssertNumberOfCalls(t, "BeginTx", 2)
+	assert.Equal(t, queue.EventWorkerFailed, receiveEvent(t, events).Type)
+}
+
+// TestExponentialBackoff tests that the delay doubles up to the max.
+func TestExponentialBackoff(t *testing.T) {
+	backoff := queue.ExponentialBackoff(time.Second, 5*time.Second)
+
+	assert.Equal(t, time.Second, backoff(1))
+	assert.Equal(t, 2*time.Second, backoff(2))
+	assert.Equal(t, 4*time.Second, backoff(3))
+	assert.Equal(t, 5*time.Second, backoff(4))
+}
@@ -0,0 +1,164 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pakkasys/fluidapi/database"
+)
+
+// Handler processes a job. Returning an error fails the attempt.
+type Handler func(ctx context.Context, job *Job) error
+
+// DefaultMaxRetryDelay is the max delay before a worker retries after
+// claiming or updating jobs failed.
+const DefaultMaxRetryDelay = time.Minute
+
+// Worker claims and processes the jobs of a queue.
+type Worker struct {
+	queue        *Queue
+	db           database.DB
+	queueName    string
+	handler      Handler
+	batchSize    int
+	pollInterval time.Duration
+	retryBackoff func(attempt int) time.Duration
+}
+
+// NewWorker creates a new worker. Failures to claim or update jobs are
+// retried with an exponential backoff starting from the poll interval and
+// capped at DefaultMaxRetryDelay.
+//
+// Parameters:
+//   - queue: The job queue.
+//   - db: The database connection.
+//   - queueName: The name of the queue to process.
+//   - handler: The job handler.
+//   - batchSize: The max number of jobs to process in a batch.
+//   - pollInterval: The interval to wait when no jobs are available.
+//
+// Returns:
+//   - *Worker: A new worker.
+func NewWorker(
+	queue *Queue,
+	db database.DB,
+	queueName string,
+	handler Handler,
+	batchSize int,
+	pollInterval time.Duration,
+) *Worker {
+	return &Worker{
+		queue:        queue,
+		db:           db,
+		queueName:    queueName,
+		handler:      handler,
+		batchSize:    batchSize,
+		pollInterval: pollInterval,
+		retryBackoff: ExponentialBackoff(pollInterval, DefaultMaxRetryDelay),
+	}
+}
+
+// Run processes jobs until the context is done. If claiming or updating jobs
+// fails, e.g. because the database is unavailable, an EventWorkerFailed
+// event is emitted and the batch is retried after the retry backoff.
+//
+// Parameters:
+//   - ctx: The context of the worker.
+//
+// Returns:
+//   - error: Nil once the context is done.
+func (w *Worker) Run(ctx context.Context) error {
+	failures := 0
+	for {
+		processed, err := w.ProcessBatch(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		wait := w.pollInterval
+		switch {
+		case err != nil:
+			failures++
+			wait = w.retryBackoff(failures)
+			w.queue.emitWorkerFailed(err)
+		case processed == w.batchSize:
+			failures = 0
+			continue
+		default:
+			failures = 0
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(wait):
+		}
+	}
+}
+
+// ProcessBatch claims and processes up to the batch size of jobs. Jobs are
+// claimed one at a time right before they are handled, so that no claim
+// expires while the job waits for the jobs before it. Each job is handled
+// with a context that expires after the visibility timeout, measured from
+// before the claim, so that the handler stops before the claim expires in
+// the database without comparing clocks. Handler panics fail the attempt.
+// Jobs lost to other workers are skipped.
+//
+// Parameters:
+//   - ctx: The context of the worker.
+//
+// Returns:
+//   - int: The number of processed jobs.
+//   - error: An error if claiming or updating jobs fails.
+func (w *Worker) ProcessBatch(ctx context.Context) (int, error) {
+	processed := 0
+	for processed < w.batchSize {
+		if err := ctx.Err(); err != nil {
+			return processed, nil
+		}
+		deadline := time.Now().Add(w.queue.visibilityTimeout)
+		jobs, err := w.queue.Claim(ctx, w.db, w.queueName, 1)
+		if err != nil {
+			return processed, fmt.Errorf("ProcessBatch: %w", err)
+		}
+		if len(jobs) == 0 {
+			return processed, nil
+		}
+		processed++
+		if err := w.process(ctx, jobs[0], deadline); err != nil {
+			return processed, fmt.Errorf("ProcessBatch: %w", err)
+		}
+	}
+	return processed, nil
+}
+
+// process handles a claimed job and completes or fails it.
+func (w *Worker) process(
+	ctx context.Context, job *Job, deadline time.Time,
+) error {
+	var err error
+	if jobErr := w.handle(ctx, job, deadline); jobErr != nil {
+		err = w.queue.Fail(w.db, job, jobErr)
+	} else {
+		err = w.queue.Complete(w.db, job)
+	}
+	if errors.Is(err, ErrJobLost) {
+		return nil
+	}
+	return err
+}
+
+// handle runs the handler for a job until the deadline of its claim and
+// recovers from panics.
+func (w *Worker) handle(
+	ctx context.Context, job *Job, deadline time.Time,
+) (err error) {
+	ctx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("job handler panicked: %v", recovered)
+		}
+	}()
+	return w.handler(ctx, job)
+}